@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+
+	"get.porter.sh/plugin/azure/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+func buildConfigCommand(p *azure.Plugin) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the plugin configuration",
+	}
+
+	cmd.AddCommand(buildConfigInitCommand(p))
+
+	return cmd
+}
+
+func buildConfigInitCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.ConfigInitOptions{}
+	var noPrompt bool
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Print a Porter configuration that uses the plugin",
+		Long: `Print a Porter configuration that uses the plugin, ready to paste into ~/.porter/config.toml, config.yaml or config.json.
+
+When the command is run in a terminal, it asks for the settings that weren't specified with flags. The questions are printed to stderr, so that only the configuration is printed to stdout. The configuration is validated, and with --test the plugin connects to the vault with it, without changing the vault, before it is printed.`,
+		Example: `  azure config init
+  azure config init --vault myvault --credential azure-cli --test >> ~/.porter/config.toml
+  azure config init --vault myvault --cloud usgovernment --credential managed-identity --client-id 00000000-0000-0000-0000-000000000000 -o yaml`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// Only ask questions when someone can answer them
+			if !noPrompt && isTerminal() {
+				opts.Prompt = true
+				p.In = os.Stdin
+			}
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.ConfigInit(cmd.Context(), opts)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Name, "name", "",
+		"Name of the plugin in the Porter configuration. Defaults to the plugin name, such as azure-keyvault")
+	f.StringVar(&opts.Vault, "vault", "",
+		"Name or URL of the vault")
+	f.StringVar(&opts.Cloud, "cloud", "",
+		"Azure cloud that the vault is in: public, china or usgovernment. Defaults to public")
+	f.StringVar(&opts.Credential, "credential", "",
+		"Type of credential used to authenticate: default, environment, workload-identity, client-assertion, managed-identity or azure-cli")
+	f.StringVar(&opts.TenantID, "tenant-id", "",
+		"Tenant to authenticate with")
+	f.StringVar(&opts.ClientID, "client-id", "",
+		"Client ID of the application or managed identity to authenticate as")
+	f.StringVar(&opts.FederatedTokenFile, "federated-token-file", "",
+		"File that contains a federated token, for the workload-identity and client-assertion credentials")
+	f.StringVar(&opts.FederatedTokenCommand, "federated-token-command", "",
+		"Command that prints a federated token, for the client-assertion credential")
+	f.BoolVar(&opts.Test, "test", false,
+		"Connect to the vault with the configuration before printing it")
+	f.BoolVar(&noPrompt, "no-prompt", false,
+		"Don't ask for the settings that weren't specified with flags")
+	f.StringVarP(&opts.RawFormat, "output", "o", "toml",
+		"Specify an output format.  Allowed values: json, toml, yaml")
+
+	return cmd
+}
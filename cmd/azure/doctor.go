@@ -0,0 +1,39 @@
+package main
+
+import (
+	"get.porter.sh/plugin/azure/pkg/azure"
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"github.com/spf13/cobra"
+)
+
+func buildDoctorCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.DoctorOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the plugin configuration and access to the vault",
+		Long: `Check the plugin configuration and access to the vault.
+
+The doctor reports which credential authenticated and the principal's object ID, checks that the vault's name resolves and that a TLS connection can be made through the configured proxy, and checks the Get, List and Set secret permissions. With --write, the Set permission is checked by writing a canary secret, which expires after an hour. Otherwise the vault isn't changed, and the Set permission isn't checked.`,
+		Example: `  azure doctor --config ~/.porter/config.toml
+  azure doctor --vault myvault --write -o json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.Doctor(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.CanarySecret, "canary", keyvault.DefaultCanarySecret,
+		"Name of the secret written to check the Set secret permission")
+	f.BoolVar(&opts.Write, "write", false,
+		"Write the canary secret to check the Set secret permission")
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
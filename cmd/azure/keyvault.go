@@ -0,0 +1,409 @@
+package main
+
+import (
+	"get.porter.sh/plugin/azure/pkg/azure"
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"github.com/spf13/cobra"
+)
+
+func buildKeyVaultCommand(p *azure.Plugin) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keyvault",
+		Short: "Work with secrets in Azure Key Vault the same way as the plugin",
+		Long: `Work with secrets in Azure Key Vault the same way as the plugin.
+
+The commands use the plugin configuration, secret naming and credentials, so they see exactly what the plugin sees. The configuration is read from a Porter configuration file, or a file that only contains the plugin configuration, and the flags override it.`,
+	}
+
+	cmd.AddCommand(buildKeyVaultGetCommand(p))
+	cmd.AddCommand(buildKeyVaultSetCommand(p))
+	cmd.AddCommand(buildKeyVaultListCommand(p))
+	cmd.AddCommand(buildKeyVaultNameCommand(p))
+	cmd.AddCommand(buildKeyVaultGCCommand(p))
+	cmd.AddCommand(buildKeyVaultMigrateCommand(p))
+	cmd.AddCommand(buildKeyVaultExportCommand(p))
+	cmd.AddCommand(buildKeyVaultImportCommand(p))
+	cmd.AddCommand(buildKeyVaultVersionsCommand(p))
+	cmd.AddCommand(buildKeyVaultRollbackCommand(p))
+	cmd.AddCommand(buildKeyVaultRotateCommand(p))
+
+	return cmd
+}
+
+func buildKeyVaultGetCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultGetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "get KEY",
+		Short: "Print the value that the plugin resolves for a secret key",
+		Example: `  azure keyvault get --config ~/.porter/config.toml mysecret
+  azure keyvault get --vault myvault "https://othervault.vault.azure.net/secrets/mysecret"`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultGet(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	return cmd
+}
+
+func buildKeyVaultSetCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultSetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "set KEY [VALUE]",
+		Short: "Save a secret key the same way as the plugin",
+		Long:  "Save a secret key the same way as the plugin. When VALUE isn't specified, the value is read from stdin, which keeps it out of your shell history. Empty values are rejected unless --allow-empty is set.",
+		Example: `  azure keyvault set --config ~/.porter/config.toml mysecret < value.txt
+  azure keyvault set --vault myvault mysecret myvalue
+  azure keyvault set --vault myvault --allow-empty mysecret ""`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			opts.StdinIsTerminal = isTerminal()
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultSet(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.BoolVar(&opts.AllowEmpty, "allow-empty", false,
+		"Save the secret when its value is empty")
+
+	return cmd
+}
+
+func buildKeyVaultListCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultListOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the secrets in the configured vault, without their values",
+		Example: `  azure keyvault list --config ~/.porter/config.toml --prefix myapp- -o json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultList(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Prefix, "prefix", "",
+		"Only list secrets whose names start with the prefix")
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+func buildKeyVaultNameCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultNameOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "name KEY...",
+		Short: "Print the secret names that the plugin uses for secret keys",
+		Long:  "Print the secret names that the plugin uses for secret keys, without connecting to the vault. The names are listed in the order that they are tried, and a warning is printed when keys use the same secret.",
+		Example: `  azure keyvault name MY_PARAM.value
+  azure keyvault name --config ~/.porter/config.toml --namespace dev password db_password -o json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultName(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+func buildKeyVaultGCCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultGCOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete the secrets of Porter runs that no longer exist",
+		Long: `Delete the secrets of Porter runs that no longer exist.
+
+Porter saves the sensitive parameters and outputs of a run in secrets named <runid>-<name>, and never deletes them. The command deletes those secrets when their run isn't one of the live runs, and the secret is older than --min-age. Only secrets with the porter-key tag, which the plugin adds when it saves a secret, are deleted, unless --include-untagged is set. The live runs are read from a file that contains the run IDs, such as an export of the runs collection of Porter's storage or a list of run IDs, and from --run-id. Any run ID in the file is treated as live.
+
+Run it with --dry-run first to see which secrets would be deleted.`,
+		Example: `  mongoexport --uri "$PORTER_STORAGE" --collection runs --fields _id --out runs.json
+  azure keyvault gc --config ~/.porter/config.toml --live-runs runs.json --dry-run
+  azure keyvault gc --config ~/.porter/config.toml --live-runs runs.json --min-age 168h --purge -o json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultGC(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.LiveRunsFile, "live-runs", "",
+		"File that contains the IDs of the runs that still exist, such as an export of Porter's runs. Use - to read stdin")
+	f.StringSliceVar(&opts.RunIDs, "run-id", nil,
+		"ID of a run that still exists. May be specified multiple times")
+	f.DurationVar(&opts.MinAge, "min-age", azure.DefaultGCMinAge,
+		"Only delete secrets that were last updated longer ago than this")
+	f.BoolVar(&opts.DryRun, "dry-run", false,
+		"Print the secrets that would be deleted, without deleting them")
+	f.BoolVar(&opts.Purge, "purge", false,
+		"Purge the deleted secrets, so that they can't be recovered")
+	f.BoolVar(&opts.IncludeUntagged, "include-untagged", false,
+		"Also delete secrets named like run secrets that don't have the porter-key tag, such as secrets saved by older versions of the plugin")
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+func buildKeyVaultMigrateCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultMigrateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy secrets from one vault to another",
+		Long: `Copy secrets from one vault to another, with their tags, content types and dates.
+
+Select the secrets with --prefix and --tag, for example the secrets of a Porter namespace with --tag porter-namespace=dev. By default only the current version of each secret is copied, and --history copies every enabled version, oldest first.
+
+Every copied version is tagged with the ID of the version that it was copied from, so when a migration is interrupted, run the same command again to copy the remaining versions. Secrets that exist in the target vault, and weren't copied from the source vault, are reported as conflicts and left alone unless --overwrite is set. Credentials for each vault are taken from the vaults section of the plugin configuration.`,
+		Example: `  azure keyvault migrate --config ~/.porter/config.toml --to newvault --tag porter-namespace=dev --dry-run
+  azure keyvault migrate --from oldvault --to https://newvault.vault.azure.net --prefix myapp- --history --verify -o json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultMigrate(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.From, "from", "",
+		"Name or URL of the vault to copy the secrets from. Defaults to the configured vault")
+	f.StringVar(&opts.To, "to", "",
+		"Name or URL of the vault to copy the secrets to")
+	f.StringVar(&opts.Prefix, "prefix", "",
+		"Only copy secrets whose names start with the prefix")
+	f.StringSliceVar(&opts.Tags, "tag", nil,
+		"Only copy secrets with the tag, formatted as NAME=VALUE. May be specified multiple times")
+	f.BoolVar(&opts.All, "all", false,
+		"Copy every secret in the vault")
+	f.BoolVar(&opts.History, "history", false,
+		"Copy every enabled version of the secrets, instead of only the current version")
+	f.BoolVar(&opts.DryRun, "dry-run", false,
+		"Print the secrets that would be copied, without copying them")
+	f.BoolVar(&opts.Verify, "verify", false,
+		"Check that the current value of every secret matches in both vaults")
+	f.BoolVar(&opts.Overwrite, "overwrite", false,
+		"Copy secrets that already exist in the target vault as new versions")
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+func buildKeyVaultExportCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultExportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export FILE",
+		Short: "Export the secrets created by the plugin to an encrypted file",
+		Long: `Export the secrets created by the plugin to a file, encrypted with a key derived from a passphrase.
+
+The file contains the name, Porter key and namespace, value, content type and tags of the current version of each secret. Secrets created by the plugin are recognized by their porter-key, porter-namespace or porter-content-hash tags, use --all to export every secret. The passphrase is read from --passphrase-file, or the ` + azure.PassphraseEnv + ` environment variable.`,
+		Example: `  azure keyvault export --config ~/.porter/config.toml --passphrase-file ~/passphrase.txt secrets.export
+  azure keyvault export --tag porter-namespace=dev --all secrets.export`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultExport(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.PassphraseFile, "passphrase-file", "",
+		"File that contains the passphrase used to encrypt the export")
+	f.StringVar(&opts.Prefix, "prefix", "",
+		"Only export secrets whose names start with the prefix")
+	f.StringSliceVar(&opts.Tags, "tag", nil,
+		"Only export secrets with the tag, formatted as NAME=VALUE. May be specified multiple times")
+	f.BoolVar(&opts.All, "all", false,
+		"Export secrets that weren't created by the plugin too")
+
+	return cmd
+}
+
+func buildKeyVaultImportCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultImportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import secrets from a file written by export",
+		Long: `Import secrets from a file written by export into the configured vault.
+
+The secrets are named from their Porter key and namespace with the plugin's naming strategy, the same way as when Porter saves them, so they can be imported into a vault with a different configuration. Secrets that already exist with a different value are left alone unless --overwrite is set. The passphrase is read from --passphrase-file, or the ` + azure.PassphraseEnv + ` environment variable.`,
+		Example: `  azure keyvault import --config ~/.porter/config.toml --passphrase-file ~/passphrase.txt secrets.export --dry-run
+  azure keyvault import --vault newvault --overwrite secrets.export`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultImport(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.PassphraseFile, "passphrase-file", "",
+		"File that contains the passphrase used to encrypt the export")
+	f.BoolVar(&opts.DryRun, "dry-run", false,
+		"Print the secrets that would be imported, without importing them")
+	f.BoolVar(&opts.Overwrite, "overwrite", false,
+		"Overwrite secrets that already exist with a different value")
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+func buildKeyVaultVersionsCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultVersionsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "versions KEY",
+		Short: "List the versions of the secret that the plugin resolves for a secret key",
+		Long:  "List the versions of the secret that the plugin resolves for a secret key, newest first, with their dates and tags. The values of the versions aren't printed.",
+		Example: `  azure keyvault versions --config ~/.porter/config.toml mysecret
+  azure keyvault versions --vault myvault --namespace dev MY_PARAM.value -o json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultVersions(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+func buildKeyVaultRollbackCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultRollbackOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rollback KEY --to VERSION",
+		Short: "Restore the value of an older version of a secret",
+		Long:  "Restore the value of an older version of the secret that the plugin resolves for a secret key. The value is saved as a new version, so the rollback can itself be rolled back, and the history of the secret is kept.",
+		Example: `  azure keyvault rollback --config ~/.porter/config.toml mysecret --to previous
+  azure keyvault rollback --vault myvault mysecret --to 2b7d1ac6e3d84bd59a1c0f4d4e2c9a31`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultRollback(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.To, "to", "",
+		"The version to restore, either its ID or previous for the enabled version before the current version")
+
+	return cmd
+}
+
+func buildKeyVaultRotateCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.KeyVaultRotateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate KEY",
+		Short: "Save a generated value as the new version of a secret",
+		Long: `Save a generated value as the new version of the secret that the plugin resolves for a secret key. The value isn't printed.
+
+The value is created by a generator: password for a random password, rsa for an RSA key pair saved as the PEM encoded private key followed by the public key, uuid for a random UUID, or command to use what a command prints. The new version is tagged with when it was rotated, the generator, and the version that it replaced.
+
+With --disable-older, the versions that were replaced longer than --grace-period ago are disabled, so that anything still using the previous value keeps working until it picks up the new one. Versions still in their grace period are disabled by the next rotation.`,
+		Example: `  azure keyvault rotate --config ~/.porter/config.toml db-password --generator password --length 40
+  azure keyvault rotate --config ~/.porter/config.toml signing-key --generator rsa --bits 4096
+  azure keyvault rotate --vault myvault api-token --command "openssl rand -base64 32" --disable-older --grace-period 1h`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.KeyVaultRotate(cmd.Context(), opts)
+		},
+	}
+
+	addConfigFlags(cmd, &opts.ConfigOptions)
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Generator, "generator", "",
+		"Generator that creates the new value.  Allowed values: password, rsa, uuid, command")
+	f.IntVar(&opts.Length, "length", keyvault.DefaultPasswordLength,
+		"Length of generated passwords")
+	f.BoolVar(&opts.Symbols, "symbols", false,
+		"Include symbols in generated passwords")
+	f.IntVar(&opts.Bits, "bits", keyvault.DefaultRSABits,
+		"Size of generated RSA keys.  Allowed values: 2048, 3072, 4096")
+	f.StringVar(&opts.Command, "command", "",
+		"Command that prints the new value, run with the system shell. Implies --generator command")
+	f.BoolVar(&opts.DisableOlder, "disable-older", false,
+		"Disable the versions that were replaced longer than the grace period ago")
+	f.DurationVar(&opts.GracePeriod, "grace-period", azure.DefaultRotateGracePeriod,
+		"How long replaced versions stay enabled, with --disable-older")
+	f.StringVarP(&opts.RawFormat, "output", "o", "plaintext",
+		"Specify an output format.  Allowed values: json, plaintext")
+
+	return cmd
+}
+
+// addConfigFlags adds the flags used to load the plugin configuration.
+func addConfigFlags(cmd *cobra.Command, opts *azure.ConfigOptions) {
+	f := cmd.Flags()
+	f.StringVarP(&opts.ConfigFile, "config", "c", "",
+		"Porter configuration file, or plugin configuration file, in json, yaml or toml")
+	f.StringVar(&opts.SecretsName, "secrets", "",
+		"Name of the secrets plugin to use from the Porter configuration file. Defaults to default-secrets")
+	f.StringVar(&opts.Vault, "vault", "",
+		"Name of the vault")
+	f.StringVar(&opts.VaultUrl, "vault-url", "",
+		"URL of the vault")
+	f.StringVar(&opts.Credential, "credential", "",
+		"Type of credential used to authenticate: default, environment, workload-identity, client-assertion, managed-identity or azure-cli")
+	f.StringVar(&opts.TenantID, "tenant-id", "",
+		"Tenant to authenticate with")
+	f.StringVar(&opts.ClientID, "client-id", "",
+		"Client ID of the application or managed identity to authenticate as")
+	f.StringVar(&opts.Namespace, "namespace", "",
+		"Porter namespace, used by the namespace naming strategies")
+}
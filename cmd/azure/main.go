@@ -63,14 +63,23 @@ func buildRootCommand(m *azure.Plugin, in io.Reader) *cobra.Command {
 
 	cmd.AddCommand(buildVersionCommand(m))
 	cmd.AddCommand(buildRunCommand(m))
+	cmd.AddCommand(buildKeyVaultCommand(m))
+	cmd.AddCommand(buildDoctorCommand(m))
+	cmd.AddCommand(buildConfigCommand(m))
+	cmd.AddCommand(buildSchemaCommand(m))
 
 	return cmd
 }
 func getInput() io.Reader {
-	s, _ := os.Stdin.Stat()
-	if (s.Mode() & os.ModeCharDevice) == 0 {
+	if !isTerminal() {
 		return os.Stdin
 	}
 
 	return &bytes.Buffer{}
 }
+
+// isTerminal returns true when stdin is a terminal, instead of a pipe or file.
+func isTerminal() bool {
+	s, _ := os.Stdin.Stat()
+	return (s.Mode() & os.ModeCharDevice) != 0
+}
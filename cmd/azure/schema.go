@@ -0,0 +1,28 @@
+package main
+
+import (
+	"get.porter.sh/plugin/azure/pkg/azure"
+	"github.com/spf13/cobra"
+)
+
+func buildSchemaCommand(p *azure.Plugin) *cobra.Command {
+	opts := azure.SchemaOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "schema [PLUGIN]",
+		Short: "Print the JSON Schema of the plugin configuration",
+		Long: `Print the JSON Schema of the config section of the plugins, which editors can use to check and complete the configuration. When PLUGIN isn't specified, an object with the schema of every plugin, keyed by plugin, is printed.
+
+The plugins check their configuration against the same schema when Porter starts them, and reject unknown settings.`,
+		Example: `  azure schema
+  azure schema secrets.azure.keyvault > azure-keyvault.schema.json`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Validate(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.PrintSchema(opts)
+		},
+	}
+
+	return cmd
+}
@@ -1,5 +1,40 @@
 package azureconfig
 
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// SecretNameTracingPlain records secret names in traces and logs as-is.
+	SecretNameTracingPlain = "plain"
+
+	// SecretNameTracingHash replaces secret names with a salted hash.
+	SecretNameTracingHash = "hash"
+
+	// SecretNameTracingOmit drops secret names from traces and logs.
+	SecretNameTracingOmit = "omit"
+
+	// SecretIDFallbackFallback looks up a secret ID as a secret name in the
+	// configured vault when the ID can't be resolved.
+	SecretIDFallbackFallback = "fallback"
+
+	// SecretIDFallbackWarn falls back like SecretIDFallbackFallback, and
+	// logs a warning with the reason that the ID could not be resolved.
+	SecretIDFallbackWarn = "warn"
+
+	// SecretIDFallbackStrict returns the error from resolving a secret ID
+	// without falling back.
+	SecretIDFallbackStrict = "strict"
+
+	// SkipUnchangedValue compares the new value with the current value of
+	// the secret, and skips the write when they are the same.
+	SkipUnchangedValue = "value"
+
+	// SkipUnchangedContentHash tags secrets with a keyed hash of their
+	// value, and skips the write when the hash of the new value is the same.
+	SkipUnchangedContentHash = "content-hash"
+)
+
 type Config struct {
 	// EnvAzurePrefix is the prefix applied to every azure
 	// environment variable For example, for a prefix of "DEV_AZURE_", the
@@ -7,8 +42,175 @@ type Config struct {
 	// "DEV_AZURE_CLIENT_SECRET". By default the prefix is "AZURE_".
 	EnvAzurePrefix string `json:"env-azure-prefix"`
 
+	// Credential is the type of credential used to authenticate with Azure.
+	// See CredentialTypes for the allowed values. Defaults to "default".
+	Credential string `json:"credential"`
+	// TenantID is the Microsoft Entra tenant to authenticate with. Defaults
+	// to the AZURE_TENANT_ID environment variable.
+	TenantID string `json:"tenant-id"`
+	// ClientID is the client ID of the application or user-assigned managed
+	// identity to authenticate as. Defaults to the AZURE_CLIENT_ID
+	// environment variable.
+	ClientID string `json:"client-id"`
+	// FederatedTokenFile is the path to a file containing a federated token,
+	// used by the workload-identity and client-assertion credentials. The
+	// file is read every time a token is requested, so it can be rotated.
+	FederatedTokenFile string `json:"federated-token-file"`
+	// FederatedTokenCommand is a command that prints a federated token, such
+	// as an OIDC token, to stdout. Used by the client-assertion credential.
+	FederatedTokenCommand string `json:"federated-token-command"`
+	// AdditionallyAllowedTenants are the tenants, in addition to TenantID,
+	// that the credential may request tokens for. This allows secret IDs to
+	// reference vaults in other tenants that the principal has access to.
+	// Use "*" to allow any tenant.
+	AdditionallyAllowedTenants []string `json:"additionally-allowed-tenants"`
+	// TokenCache reuses access tokens across plugin invocations.
+	TokenCache TokenCacheConfig `json:"token-cache"`
+
 	// Vault is the name of the vault containing bundle secrets.
 	Vault string `json:"vault"`
 	// VaultUrl is the full url of the vault containing bundle secrets.
 	VaultUrl string `json:"vault-url"`
+	// Vaults overrides the credential used for specific vaults, such as
+	// vaults in a partner tenant that are referenced by secret ID.
+	Vaults []VaultConfig `json:"vaults"`
+	// SecretIDFallback decides what happens when a secret is referenced by
+	// its ID and the ID can't be resolved. Allowed values are "fallback",
+	// "warn" and "strict". Defaults to "fallback", which looks up the whole ID
+	// as a secret name in the configured vault.
+	SecretIDFallback string `json:"secret-id-fallback"`
+	// SecretOptions treats the text after a "?" at the end of a secret key
+	// as options, such as "?optional" or "?default=value". Off by default,
+	// so that existing keys that contain a "?" keep their meaning.
+	SecretOptions bool `json:"secret-options"`
+
+	// NamingStrategy decides how the Porter namespace is used in secret
+	// names. Allowed values are "key", "namespace-prefix" and
+	// "namespace-tag". Defaults to "key", which ignores the namespace.
+	NamingStrategy string `json:"naming-strategy"`
+	// Namespace is the Porter namespace used when the request doesn't
+	// include one. Defaults to the PORTER_NAMESPACE environment variable.
+	Namespace string `json:"namespace"`
+	// NamespaceIsolation rejects secrets that the plugin created for a
+	// different Porter namespace.
+	NamespaceIsolation bool `json:"namespace-isolation"`
+	// SkipUnchanged avoids creating a new version of a secret when its value
+	// hasn't changed. Allowed values are "value" and "content-hash". By
+	// default every write creates a new version.
+	SkipUnchanged string `json:"skip-unchanged"`
+	// ContentHashKey is the key of the content hashes when SkipUnchanged is
+	// "content-hash", so that someone who can list the secrets, but doesn't
+	// have the key, can't test guesses of a value against its hash.
+	ContentHashKey string `json:"content-hash-key"`
+	// Expiration sets the expiration and activation dates of the secrets
+	// that the plugin creates.
+	Expiration ExpirationConfig `json:"expiration"`
+	// Prefetch fetches matching secrets when the plugin connects, and
+	// resolves secrets from that snapshot.
+	Prefetch PrefetchConfig `json:"prefetch"`
+
+	// SecretNameTracing controls how secret names are recorded in span
+	// attributes and log messages. Allowed values are "plain", "hash" and
+	// "omit". By default names are recorded as-is.
+	SecretNameTracing string `json:"secret-name-tracing"`
+	// SecretNameSalt is mixed into the secret name hashes when
+	// SecretNameTracing is "hash", so that the hashes cannot be reversed by
+	// hashing a list of likely names.
+	SecretNameSalt string `json:"secret-name-salt"`
+
+	// Logging configures the level and format of the plugin's logs.
+	Logging LoggingConfig `json:"logging"`
+
+	// Metrics configures how the plugin exports its OpenTelemetry metrics.
+	Metrics MetricsConfig `json:"metrics"`
+
+	// Retry configures how failed and throttled requests to Azure are retried.
+	Retry RetryConfig `json:"retry"`
+
+	// Transport configures the proxy and certificates used to connect to Azure.
+	Transport TransportConfig `json:"transport"`
+}
+
+// GetSecretIDFallback returns the policy used when a secret ID can't be resolved.
+func (c Config) GetSecretIDFallback() string {
+	if c.SecretIDFallback == "" {
+		return SecretIDFallbackFallback
+	}
+	return c.SecretIDFallback
+}
+
+// redactsSecretNames returns true when secret names are hashed or omitted from
+// traces and logs.
+func (c Config) redactsSecretNames() bool {
+	return c.SecretNameTracing == SecretNameTracingHash || c.SecretNameTracing == SecretNameTracingOmit
+}
+
+// Validate checks that the configuration is usable.
+func (c Config) Validate() error {
+	if err := c.validateCredential(); err != nil {
+		return err
+	}
+
+	if err := c.validateVaults(); err != nil {
+		return err
+	}
+
+	switch c.SecretIDFallback {
+	case "", SecretIDFallbackFallback, SecretIDFallbackWarn, SecretIDFallbackStrict:
+	default:
+		return errors.Errorf("invalid secret-id-fallback %q, allowed values are: %s, %s, %s",
+			c.SecretIDFallback, SecretIDFallbackFallback, SecretIDFallbackWarn, SecretIDFallbackStrict)
+	}
+
+	switch c.SkipUnchanged {
+	case "", SkipUnchangedValue:
+	case SkipUnchangedContentHash:
+		if c.ContentHashKey == "" {
+			return errors.New("content-hash-key is required when skip-unchanged is content-hash")
+		}
+	default:
+		return errors.Errorf("invalid skip-unchanged %q, allowed values are: %s, %s",
+			c.SkipUnchanged, SkipUnchangedValue, SkipUnchangedContentHash)
+	}
+
+	if err := c.validateNaming(); err != nil {
+		return err
+	}
+
+	switch c.SecretNameTracing {
+	case "", SecretNameTracingPlain, SecretNameTracingOmit:
+	case SecretNameTracingHash:
+		if c.SecretNameSalt == "" {
+			return errors.New("secret-name-salt is required when secret-name-tracing is hash")
+		}
+	default:
+		return errors.Errorf("invalid secret-name-tracing %q, allowed values are: %s, %s, %s",
+			c.SecretNameTracing, SecretNameTracingPlain, SecretNameTracingHash, SecretNameTracingOmit)
+	}
+	if c.Logging.AzureSDK && c.redactsSecretNames() {
+		// The SDK logs request urls, which end with the secret name
+		return errors.Errorf("logging.azure-sdk can't be used when secret-name-tracing is %s, because the Azure SDK logs the secret names", c.SecretNameTracing)
+	}
+
+	if err := c.Expiration.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Prefetch.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Metrics.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Retry.Validate(); err != nil {
+		return err
+	}
+
+	return c.Transport.Validate()
 }
@@ -0,0 +1,252 @@
+package azureconfig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ForVault(t *testing.T) {
+	cfg := Config{
+		Vault:    "myvault",
+		TenantID: "mytenant",
+		ClientID: "myclient",
+		Vaults: []VaultConfig{
+			{Vault: "partnervault", TenantID: "partnertenant"},
+			{VaultUrl: "https://govvault.vault.usgovcloudapi.net/", Credential: CredentialAzureCLI},
+		},
+	}
+
+	t.Run("no override", func(t *testing.T) {
+		got, override := cfg.ForVault("https://myvault.vault.azure.net")
+		assert.Empty(t, override)
+		assert.Equal(t, "mytenant", got.TenantID)
+		assert.Equal(t, "myclient", got.ClientID)
+	})
+
+	t.Run("override by name", func(t *testing.T) {
+		got, override := cfg.ForVault("https://PartnerVault.vault.azure.net/")
+		assert.Equal(t, "https://partnervault.vault.azure.net", override)
+		assert.Equal(t, "partnertenant", got.TenantID)
+		assert.Equal(t, "myclient", got.ClientID, "settings that aren't overridden should be inherited")
+	})
+
+	t.Run("override by url", func(t *testing.T) {
+		got, override := cfg.ForVault("https://govvault.vault.usgovcloudapi.net")
+		assert.Equal(t, "https://govvault.vault.usgovcloudapi.net", override)
+		assert.Equal(t, CredentialAzureCLI, got.GetCredential())
+		assert.Equal(t, "mytenant", got.TenantID)
+	})
+}
+
+func TestConfig_Validate_Vaults(t *testing.T) {
+	t.Run("missing vault", func(t *testing.T) {
+		cfg := Config{Vaults: []VaultConfig{{TenantID: "partnertenant"}}}
+		require.EqualError(t, cfg.Validate(), "vaults[0]: vault or vault-url is required")
+	})
+
+	t.Run("invalid credential", func(t *testing.T) {
+		cfg := Config{Vaults: []VaultConfig{{Vault: "partnervault", Credential: CredentialClientAssertion}}}
+		require.ErrorContains(t, cfg.Validate(), "vaults[0]: tenant-id and client-id are required")
+	})
+}
+
+func TestConfig_Validate_ClientID(t *testing.T) {
+	require.NoError(t, Config{Credential: CredentialManagedIdentity, ClientID: "myclient"}.Validate())
+
+	err := Config{ClientID: "myclient"}.Validate()
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "client-id", fieldErr.Field)
+	assert.EqualError(t, err, "client-id: not used by the default credential, set the AZURE_CLIENT_ID environment variable or use the managed-identity or workload-identity credential instead")
+
+	require.EqualError(t, Config{Credential: CredentialAzureCLI, ClientID: "myclient"}.Validate(),
+		"client-id: not used by the azure-cli credential, which authenticates as the user logged in with the Azure CLI")
+
+	cfg := Config{Vaults: []VaultConfig{{Vault: "partnervault", Credential: CredentialEnvironment, ClientID: "myclient"}}}
+	require.ErrorContains(t, cfg.Validate(), "vaults[0]: client-id: not used by the environment credential")
+}
+
+func TestConfig_Validate_Tenants(t *testing.T) {
+	require.NoError(t, Config{TenantID: "mytenant", AdditionallyAllowedTenants: []string{"*"}}.Validate())
+	require.NoError(t, Config{Credential: CredentialAzureCLI, TenantID: "mytenant"}.Validate())
+
+	err := Config{Credential: CredentialEnvironment, TenantID: "mytenant"}.Validate()
+	var fieldErr FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "tenant-id", fieldErr.Field)
+	assert.EqualError(t, err, "tenant-id: not used by the environment credential, set the AZURE_TENANT_ID environment variable instead")
+
+	require.EqualError(t, Config{Credential: CredentialEnvironment, AdditionallyAllowedTenants: []string{"*"}}.Validate(),
+		"additionally-allowed-tenants: not used by the environment credential, set the AZURE_ADDITIONALLY_ALLOWED_TENANTS environment variable instead")
+	require.EqualError(t, Config{Credential: CredentialManagedIdentity, TenantID: "mytenant"}.Validate(),
+		"tenant-id: not used by the managed-identity credential, which authenticates in the tenant of the managed identity")
+	require.EqualError(t, Config{Credential: CredentialManagedIdentity, AdditionallyAllowedTenants: []string{"*"}}.Validate(),
+		"additionally-allowed-tenants: not used by the managed-identity credential, which authenticates in the tenant of the managed identity")
+}
+
+func TestConfig_Validate_TokenCache(t *testing.T) {
+	tokenCache := TokenCacheConfig{Enabled: true}
+	require.NoError(t, Config{Credential: CredentialEnvironment, TokenCache: tokenCache}.Validate())
+
+	require.EqualError(t, Config{Credential: CredentialAzureCLI, TokenCache: tokenCache}.Validate(),
+		"token-cache.enabled: not supported by the azure-cli credential, because the identity that it authenticates as can change, so the time that the Azure CLI takes to start can't be saved, use the environment, workload-identity or client-assertion credential instead")
+	require.ErrorContains(t, Config{TokenCache: tokenCache}.Validate(), "not supported by the default credential")
+
+	cfg := Config{
+		Credential: CredentialEnvironment,
+		TokenCache: tokenCache,
+		Vaults:     []VaultConfig{{Vault: "partnervault", Credential: CredentialManagedIdentity}},
+	}
+	require.ErrorContains(t, cfg.Validate(), "vaults[0]: token-cache.enabled: not supported by the managed-identity credential")
+}
+
+func TestConfig_Validate_Naming(t *testing.T) {
+	t.Run("invalid strategy", func(t *testing.T) {
+		cfg := Config{NamingStrategy: "prefix"}
+		require.ErrorContains(t, cfg.Validate(), `invalid naming-strategy "prefix"`)
+	})
+
+	t.Run("isolation requires namespace naming", func(t *testing.T) {
+		cfg := Config{NamespaceIsolation: true}
+		require.ErrorContains(t, cfg.Validate(), "namespace-isolation requires")
+	})
+
+	t.Run("isolation with tags", func(t *testing.T) {
+		cfg := Config{NamingStrategy: NamingStrategyNamespaceTag, NamespaceIsolation: true}
+		require.NoError(t, cfg.Validate())
+	})
+}
+
+func TestConfig_GetNamespace(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "fromenv")
+	assert.Equal(t, "fromenv", Config{}.GetNamespace())
+	assert.Equal(t, "dev", Config{Namespace: "dev"}.GetNamespace())
+}
+
+func TestConfig_Validate_Prefetch(t *testing.T) {
+	t.Run("filter required", func(t *testing.T) {
+		cfg := Config{Prefetch: PrefetchConfig{Enabled: true}}
+		require.ErrorContains(t, cfg.Validate(), "prefetch requires a prefix or tags")
+	})
+
+	t.Run("invalid concurrency", func(t *testing.T) {
+		cfg := Config{Prefetch: PrefetchConfig{Enabled: true, Prefix: "app-", Concurrency: -1}}
+		require.ErrorContains(t, cfg.Validate(), "invalid prefetch concurrency -1")
+	})
+
+	t.Run("default concurrency", func(t *testing.T) {
+		cfg := Config{Prefetch: PrefetchConfig{Enabled: true, Tags: map[string]string{"app": "web"}}}
+		require.NoError(t, cfg.Validate())
+		assert.Equal(t, DefaultPrefetchConcurrency, cfg.Prefetch.GetConcurrency())
+	})
+}
+
+func TestConfig_Validate_SkipUnchanged(t *testing.T) {
+	require.NoError(t, Config{SkipUnchanged: SkipUnchangedContentHash, ContentHashKey: "a-long-random-value"}.Validate())
+	require.EqualError(t, Config{SkipUnchanged: SkipUnchangedContentHash}.Validate(), "content-hash-key is required when skip-unchanged is content-hash")
+	require.ErrorContains(t, Config{SkipUnchanged: "always"}.Validate(), `invalid skip-unchanged "always"`)
+}
+
+func TestConfig_Validate_AzureSDKLogging(t *testing.T) {
+	require.NoError(t, Config{Logging: LoggingConfig{AzureSDK: true}}.Validate())
+	cfg := Config{SecretNameTracing: SecretNameTracingOmit, Logging: LoggingConfig{AzureSDK: true}}
+	require.EqualError(t, cfg.Validate(), "logging.azure-sdk can't be used when secret-name-tracing is omit, because the Azure SDK logs the secret names")
+}
+
+func TestExpirationConfig_For(t *testing.T) {
+	cfg := ExpirationConfig{
+		TTL: Duration(24 * time.Hour),
+		Rules: []ExpirationRule{
+			{Pattern: "*-output-*", TTL: Duration(time.Hour), ActivationDelay: Duration(time.Minute)},
+			{Pattern: "shared-*"},
+		},
+	}
+
+	ttl, delay := cfg.For("01h2-output-token")
+	assert.Equal(t, time.Hour, ttl)
+	assert.Equal(t, time.Minute, delay)
+
+	ttl, _ = cfg.For("shared-password")
+	assert.Zero(t, ttl, "a rule without a ttl never expires")
+
+	ttl, delay = cfg.For("01h2-password")
+	assert.Equal(t, 24*time.Hour, ttl)
+	assert.Zero(t, delay)
+}
+
+func TestConfig_Validate_Expiration(t *testing.T) {
+	t.Run("invalid pattern", func(t *testing.T) {
+		cfg := Config{Expiration: ExpirationConfig{Rules: []ExpirationRule{{Pattern: "[", TTL: Duration(time.Hour)}}}}
+		require.EqualError(t, cfg.Validate(), `expiration rules[0]: invalid pattern "["`)
+	})
+
+	t.Run("activation after expiration", func(t *testing.T) {
+		cfg := Config{Expiration: ExpirationConfig{TTL: Duration(time.Hour), ActivationDelay: Duration(2 * time.Hour)}}
+		require.EqualError(t, cfg.Validate(), "expiration: activation-delay 2h0m0s must be less than ttl 1h0m0s")
+	})
+}
+
+func TestMetricsConfig_NewMeterProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		provider, err := MetricsConfig{Endpoint: "localhost:4317"}.NewMeterProvider(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, provider)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		provider, err := MetricsConfig{Enabled: true, Endpoint: "localhost:4317", Insecure: true}.NewMeterProvider(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, provider)
+	})
+
+	t.Run("http exports when shut down", func(t *testing.T) {
+		var exports []string
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exports = append(exports, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		}))
+		defer collector.Close()
+
+		cfg := MetricsConfig{
+			Enabled:  true,
+			Endpoint: collector.URL,
+			Protocol: MetricsProtocolHTTP,
+			Headers:  map[string]string{"Authorization": "Bearer abc123"},
+			Interval: Duration(time.Hour),
+		}
+		provider, err := cfg.NewMeterProvider(ctx)
+		require.NoError(t, err)
+		counter, err := provider.Meter("test").Int64Counter("requests")
+		require.NoError(t, err)
+		counter.Add(ctx, 1)
+
+		require.NoError(t, provider.Shutdown(ctx))
+		assert.Equal(t, []string{"POST /v1/metrics Bearer abc123"}, exports)
+	})
+
+	t.Run("invalid protocol", func(t *testing.T) {
+		require.EqualError(t, Config{Metrics: MetricsConfig{Protocol: "udp"}}.Validate(), `invalid metrics protocol "udp", allowed values are: grpc, http/protobuf`)
+	})
+}
+
+func TestLoggingConfig_SDKLogLevel(t *testing.T) {
+	for level, want := range map[string]hclog.Level{
+		"":      hclog.Debug,
+		"trace": hclog.Debug,
+		"info":  hclog.Info,
+		"warn":  hclog.Warn,
+		"off":   hclog.Debug,
+	} {
+		l := LoggingConfig{Level: level, AzureSDK: true}.NewLogger("test", io.Discard)
+		assert.Equal(t, want, sdkLogLevel(l), "level %q", level)
+	}
+}
@@ -0,0 +1,127 @@
+package azureconfig
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CredentialDefault tries the credentials in the Azure SDK's
+	// DefaultAzureCredential chain: environment variables, workload
+	// identity, managed identity and then the Azure CLI.
+	CredentialDefault = "default"
+
+	// CredentialEnvironment authenticates with a service principal or user
+	// from the AZURE_* environment variables.
+	CredentialEnvironment = "environment"
+
+	// CredentialWorkloadIdentity authenticates with a federated token file,
+	// as configured by the workload identity webhook in AKS.
+	CredentialWorkloadIdentity = "workload-identity"
+
+	// CredentialClientAssertion authenticates with a federated token that is
+	// read from a file or printed by a command, such as the OIDC token of a
+	// GitHub Actions job.
+	CredentialClientAssertion = "client-assertion"
+
+	// CredentialManagedIdentity authenticates with the managed identity of
+	// the machine that the plugin is running on.
+	CredentialManagedIdentity = "managed-identity"
+
+	// CredentialAzureCLI authenticates as the user logged in with the Azure
+	// CLI.
+	CredentialAzureCLI = "azure-cli"
+)
+
+// CredentialTypes lists the allowed values for Config.Credential.
+var CredentialTypes = []string{
+	CredentialDefault,
+	CredentialEnvironment,
+	CredentialWorkloadIdentity,
+	CredentialClientAssertion,
+	CredentialManagedIdentity,
+	CredentialAzureCLI,
+}
+
+// GetCredential returns the type of credential that is used to authenticate.
+func (c Config) GetCredential() string {
+	if c.Credential == "" {
+		return CredentialDefault
+	}
+	return c.Credential
+}
+
+func (c Config) validateCredential() error {
+	switch c.GetCredential() {
+	case CredentialManagedIdentity:
+		if c.FederatedTokenFile != "" || c.FederatedTokenCommand != "" {
+			return errors.Errorf("federated-token-file and federated-token-command are not used by the %s credential", c.GetCredential())
+		}
+		if err := c.unusedTenants(); err != nil {
+			return err
+		}
+	case CredentialDefault, CredentialEnvironment, CredentialAzureCLI:
+		if c.FederatedTokenFile != "" || c.FederatedTokenCommand != "" {
+			return errors.Errorf("federated-token-file and federated-token-command are not used by the %s credential", c.GetCredential())
+		}
+		if c.ClientID != "" {
+			return c.unusedClientID()
+		}
+		if c.GetCredential() == CredentialEnvironment {
+			if err := c.unusedTenants(); err != nil {
+				return err
+			}
+		}
+	case CredentialWorkloadIdentity:
+		if c.FederatedTokenCommand != "" {
+			return errors.New("federated-token-command is not supported by the workload-identity credential, use the client-assertion credential instead")
+		}
+	case CredentialClientAssertion:
+		if c.TenantID == "" || c.ClientID == "" {
+			return errors.New("tenant-id and client-id are required by the client-assertion credential")
+		}
+		if (c.FederatedTokenFile == "") == (c.FederatedTokenCommand == "") {
+			return errors.New("exactly one of federated-token-file or federated-token-command is required by the client-assertion credential")
+		}
+	default:
+		return errors.Errorf("invalid credential %q, allowed values are: %v", c.Credential, CredentialTypes)
+	}
+
+	return c.validateTokenCache()
+}
+
+// unusedClientID explains why client-id can't be used with the credential,
+// instead of silently ignoring it.
+func (c Config) unusedClientID() error {
+	msg := fmt.Sprintf("not used by the %s credential", c.GetCredential())
+	if c.GetCredential() == CredentialAzureCLI {
+		msg += ", which authenticates as the user logged in with the Azure CLI"
+	} else {
+		msg += ", set the AZURE_CLIENT_ID environment variable or use the managed-identity or workload-identity credential instead"
+	}
+	return FieldError{Field: "client-id", Message: msg}
+}
+
+// unusedTenants explains why tenant-id and additionally-allowed-tenants can't
+// be used with the credentials that don't accept them, instead of silently
+// authenticating in another tenant.
+func (c Config) unusedTenants() error {
+	field, env := "", ""
+	switch {
+	case c.TenantID != "":
+		field, env = "tenant-id", "AZURE_TENANT_ID"
+	case len(c.AdditionallyAllowedTenants) > 0:
+		field, env = "additionally-allowed-tenants", "AZURE_ADDITIONALLY_ALLOWED_TENANTS"
+	default:
+		return nil
+	}
+
+	msg := fmt.Sprintf("not used by the %s credential", c.GetCredential())
+	if c.GetCredential() == CredentialManagedIdentity {
+		msg += ", which authenticates in the tenant of the managed identity"
+	} else {
+		msg += fmt.Sprintf(", set the %s environment variable instead", env)
+	}
+	return FieldError{Field: field, Message: msg}
+}
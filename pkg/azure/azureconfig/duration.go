@@ -0,0 +1,36 @@
+package azureconfig
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Duration is a time.Duration that is configured with a string such as "30s"
+// or "1h30m".
+type Duration time.Duration
+
+// Duration returns the value as a time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err != nil {
+		return errors.Errorf("invalid duration %s, expected a string such as \"30s\"", string(b))
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.Errorf("invalid duration %q, expected a string such as \"30s\"", value)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,82 @@
+package azureconfig
+
+import (
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ExpirationConfig sets the expiration and activation dates of the secrets
+// that the plugin creates, so that run-scoped secrets don't accumulate in the
+// vault forever.
+type ExpirationConfig struct {
+	// TTL is how long after it is created that a secret expires. Defaults to
+	// 0, which never expires.
+	TTL Duration `json:"ttl"`
+
+	// ActivationDelay is how long after it is created that a secret becomes
+	// active. Defaults to 0, which is active immediately.
+	ActivationDelay Duration `json:"activation-delay"`
+
+	// Rules override TTL and ActivationDelay for the secret keys that match
+	// a pattern. The first matching rule is used.
+	Rules []ExpirationRule `json:"rules"`
+}
+
+// ExpirationRule sets the expiration and activation dates of the secrets whose
+// keys match a pattern.
+type ExpirationRule struct {
+	// Pattern is matched against the secret key with path.Match, for example
+	// "*-output-*".
+	Pattern string `json:"pattern"`
+
+	// TTL is how long after it is created that a matching secret expires.
+	// 0 never expires.
+	TTL Duration `json:"ttl"`
+
+	// ActivationDelay is how long after it is created that a matching secret
+	// becomes active.
+	ActivationDelay Duration `json:"activation-delay"`
+}
+
+// For returns the TTL and activation delay for the secret key. A TTL of 0
+// means that the secret never expires.
+func (c ExpirationConfig) For(key string) (ttl time.Duration, activationDelay time.Duration) {
+	for _, rule := range c.Rules {
+		if matched, _ := path.Match(rule.Pattern, key); matched {
+			return rule.TTL.Duration(), rule.ActivationDelay.Duration()
+		}
+	}
+	return c.TTL.Duration(), c.ActivationDelay.Duration()
+}
+
+// Validate checks that the expiration configuration is usable.
+func (c ExpirationConfig) Validate() error {
+	if err := validateExpiration(c.TTL, c.ActivationDelay); err != nil {
+		return errors.Wrap(err, "expiration")
+	}
+
+	for i, rule := range c.Rules {
+		if rule.Pattern == "" {
+			return errors.Errorf("expiration rules[%d]: pattern is required", i)
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return errors.Errorf("expiration rules[%d]: invalid pattern %q", i, rule.Pattern)
+		}
+		if err := validateExpiration(rule.TTL, rule.ActivationDelay); err != nil {
+			return errors.Wrapf(err, "expiration rules[%d]", i)
+		}
+	}
+	return nil
+}
+
+func validateExpiration(ttl Duration, activationDelay Duration) error {
+	if ttl < 0 || activationDelay < 0 {
+		return errors.New("ttl and activation-delay must not be negative")
+	}
+	if ttl > 0 && activationDelay >= ttl {
+		return errors.Errorf("activation-delay %s must be less than ttl %s", activationDelay.Duration(), ttl.Duration())
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package azureconfig
+
+import (
+	"io"
+
+	azlog "github.com/Azure/azure-sdk-for-go/sdk/azcore/log"
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// LogFormatJSON writes log messages as JSON, which is what Porter expects
+	// from a plugin.
+	LogFormatJSON = "json"
+
+	// LogFormatText writes log messages as plain text.
+	LogFormatText = "text"
+
+	// DefaultLogLevel is the log level used when one isn't configured.
+	DefaultLogLevel = "debug"
+)
+
+// LoggingConfig configures the loggers used by every plugin.
+type LoggingConfig struct {
+	// Level is the minimum level logged: trace, debug, info, warn, error or
+	// off. Defaults to debug.
+	Level string `json:"level"`
+
+	// Format of the log messages, either json or text. Defaults to json.
+	Format string `json:"format"`
+
+	// AzureSDK enables the Azure SDK's request, response and retry logging,
+	// at debug level, or at Level when it is higher. The SDK redacts request
+	// and response headers, and query parameters, unless they are known to be
+	// safe.
+	AzureSDK bool `json:"azure-sdk"`
+}
+
+// Validate checks that the logging configuration is usable.
+func (c LoggingConfig) Validate() error {
+	if c.Level != "" && hclog.LevelFromString(c.Level) == hclog.NoLevel {
+		return errors.Errorf("invalid logging level %q, allowed values are: trace, debug, info, warn, error, off", c.Level)
+	}
+
+	switch c.Format {
+	case "", LogFormatJSON, LogFormatText:
+	default:
+		return errors.Errorf("invalid logging format %q, allowed values are: %s, %s", c.Format, LogFormatJSON, LogFormatText)
+	}
+
+	return nil
+}
+
+// NewLogger creates a logger with the configured level and format.
+func (c LoggingConfig) NewLogger(name string, out io.Writer) hclog.Logger {
+	level := c.Level
+	if level == "" {
+		level = DefaultLogLevel
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       name,
+		Output:     out,
+		Level:      hclog.LevelFromString(level),
+		JSONFormat: c.Format != LogFormatText,
+	})
+}
+
+// ConfigureAzureSDK routes the Azure SDK's logging to the specified logger
+// when it is enabled, and turns it off otherwise. The SDK's logging is
+// global, so this applies to every Azure client in the process.
+func (c LoggingConfig) ConfigureAzureSDK(l hclog.Logger) {
+	if !c.AzureSDK {
+		azlog.SetListener(nil)
+		return
+	}
+
+	level := sdkLogLevel(l)
+	azlog.SetEvents(azlog.EventRequest, azlog.EventResponse, azlog.EventResponseError, azlog.EventRetryPolicy)
+	azlog.SetListener(func(event azlog.Event, msg string) {
+		l.Log(level, msg, "event", string(event))
+	})
+}
+
+// sdkLogLevel is the level that the Azure SDK's messages are logged at. They
+// are debug messages, but enabling azure-sdk asks for them, so they are
+// logged at the level of the logger when it is higher, instead of being
+// dropped.
+func sdkLogLevel(l hclog.Logger) hclog.Level {
+	if level := l.GetLevel(); level > hclog.Debug && level != hclog.Off {
+		return level
+	}
+	return hclog.Debug
+}
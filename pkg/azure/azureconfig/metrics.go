@@ -0,0 +1,118 @@
+package azureconfig
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	// MetricsProtocolGRPC exports metrics with OTLP over gRPC.
+	MetricsProtocolGRPC = "grpc"
+
+	// MetricsProtocolHTTP exports metrics with OTLP over HTTP.
+	MetricsProtocolHTTP = "http/protobuf"
+)
+
+// MetricsConfig configures how the plugin exports its OpenTelemetry metrics.
+// Porter's telemetry settings only set up the export of traces, and Porter
+// doesn't hand plugins a meter provider, so the plugin exports its metrics
+// itself. Unset values fall back to the standard OTEL_EXPORTER_OTLP_*
+// environment variables, which Porter's trace exporter reads too, so the
+// collector can be configured once for both.
+type MetricsConfig struct {
+	// Enabled turns on exporting metrics. Defaults to false.
+	Enabled bool `json:"enabled"`
+
+	// Endpoint is the address of the OpenTelemetry collector, either a
+	// host and port such as localhost:4317, or a url.
+	Endpoint string `json:"endpoint"`
+
+	// Protocol is the OTLP protocol, either grpc or http/protobuf. Defaults
+	// to grpc.
+	Protocol string `json:"protocol"`
+
+	// Insecure connects to the collector without TLS.
+	Insecure bool `json:"insecure"`
+
+	// Headers are sent with every export, for example to authenticate with
+	// the collector.
+	Headers map[string]string `json:"headers"`
+
+	// Interval is how often metrics are exported. Metrics are also exported
+	// when the plugin exits. Defaults to 60s.
+	Interval Duration `json:"interval"`
+}
+
+// Validate checks that the metrics configuration is usable.
+func (c MetricsConfig) Validate() error {
+	switch c.Protocol {
+	case "", MetricsProtocolGRPC, MetricsProtocolHTTP:
+	default:
+		return errors.Errorf("invalid metrics protocol %q, allowed values are: %s, %s", c.Protocol, MetricsProtocolGRPC, MetricsProtocolHTTP)
+	}
+	if c.Interval < 0 {
+		return errors.New("metrics interval must not be negative")
+	}
+	return nil
+}
+
+// NewMeterProvider creates a meter provider that exports metrics to the
+// configured collector. When metrics aren't enabled it returns nil, and the
+// instruments created by the plugin don't record anything. Shut the provider
+// down before the plugin exits, so that the last metrics are exported.
+func (c MetricsConfig) NewMeterProvider(ctx context.Context) (*sdkmetric.MeterProvider, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	exporter, err := c.newExporter(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the metrics exporter")
+	}
+
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	if c.Interval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(c.Interval.Duration()))
+	}
+	reader := sdkmetric.NewPeriodicReader(exporter, readerOpts...)
+	return sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil
+}
+
+func (c MetricsConfig) newExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	isURL := strings.Contains(c.Endpoint, "://")
+
+	if c.Protocol == MetricsProtocolHTTP {
+		var opts []otlpmetrichttp.Option
+		if isURL {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(c.Endpoint))
+		} else if c.Endpoint != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(c.Headers))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	var opts []otlpmetricgrpc.Option
+	if isURL {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(c.Endpoint))
+	} else if c.Endpoint != "" {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(c.Endpoint))
+	}
+	if c.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(c.Headers))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
@@ -0,0 +1,60 @@
+package azureconfig
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// NamingStrategyKey uses the secret key as the secret name.
+	NamingStrategyKey = "key"
+
+	// NamingStrategyNamespacePrefix prefixes the secret name with the Porter
+	// namespace, so that each namespace has its own copy of a secret.
+	NamingStrategyNamespacePrefix = "namespace-prefix"
+
+	// NamingStrategyNamespaceTag uses the secret key as the secret name, and
+	// tags the secret with the Porter namespace.
+	NamingStrategyNamespaceTag = "namespace-tag"
+)
+
+// NamingStrategies are the allowed values of the naming-strategy setting.
+var NamingStrategies = []string{
+	NamingStrategyKey,
+	NamingStrategyNamespacePrefix,
+	NamingStrategyNamespaceTag,
+}
+
+// GetNamingStrategy returns how secret names are generated, defaulting to
+// NamingStrategyKey.
+func (c Config) GetNamingStrategy() string {
+	if c.NamingStrategy == "" {
+		return NamingStrategyKey
+	}
+	return c.NamingStrategy
+}
+
+// GetNamespace returns the Porter namespace used when the request doesn't
+// specify one. Defaults to the PORTER_NAMESPACE environment variable.
+func (c Config) GetNamespace() string {
+	if c.Namespace != "" {
+		return c.Namespace
+	}
+	return os.Getenv("PORTER_NAMESPACE")
+}
+
+func (c Config) validateNaming() error {
+	switch c.NamingStrategy {
+	case "", NamingStrategyKey, NamingStrategyNamespacePrefix, NamingStrategyNamespaceTag:
+	default:
+		return errors.Errorf("invalid naming-strategy %q, allowed values are: %s, %s, %s",
+			c.NamingStrategy, NamingStrategyKey, NamingStrategyNamespacePrefix, NamingStrategyNamespaceTag)
+	}
+
+	if c.NamespaceIsolation && c.GetNamingStrategy() == NamingStrategyKey {
+		return errors.Errorf("namespace-isolation requires the %s or %s naming-strategy, so that secrets are tagged with their namespace",
+			NamingStrategyNamespacePrefix, NamingStrategyNamespaceTag)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package azureconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FieldError is a problem with a setting in the plugin configuration.
+type FieldError struct {
+	// Field is the path of the setting, such as vaults[0].credential. It is
+	// empty for problems with the whole configuration.
+	Field string
+
+	// Message describes the problem.
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors are the problems found in the plugin configuration.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Parse reads the plugin configuration from JSON. The configuration is checked
+// against the schema first, so that unknown settings, values of the wrong type
+// and missing settings are reported as FieldErrors, instead of being ignored.
+// The configuration is validated too.
+func Parse(data []byte) (Config, error) {
+	var cfg Config
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return cfg, errors.Wrap(err, "the configuration is not valid JSON")
+	}
+
+	var errs FieldErrors
+	NewSchema().validate("", value, &errs)
+	if len(errs) > 0 {
+		return cfg, errs
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// validate checks the value against the schema, and adds the problems to errs.
+func (s *Schema) validate(path string, value interface{}, errs *FieldErrors) {
+	if value == nil {
+		return
+	}
+	add := func(field string, format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && s.Type != jsonType(value) {
+		add(path, "expected %s, got %s", article(s.Type), article(jsonType(value)))
+		return
+	}
+	if s.Const != nil && !reflect.DeepEqual(s.Const, value) {
+		add(path, "must be %v", s.Const)
+	}
+	if str, ok := value.(string); ok {
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			add(path, "invalid value %q, allowed values are: %s", str, strings.Join(s.Enum, ", "))
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			add(path, "invalid value %q, expected %s", str, s.Description)
+		}
+	}
+	if n, ok := value.(float64); ok && s.Minimum != nil && n < float64(*s.Minimum) {
+		add(path, "%v is less than the minimum of %d", n, *s.Minimum)
+	}
+
+	if items, ok := value.([]interface{}); ok && s.Items != nil {
+		for i, item := range items {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	}
+
+	if obj, ok := value.(map[string]interface{}); ok {
+		s.validateObject(path, obj, errs)
+	}
+
+	for _, sub := range s.AllOf {
+		sub.validate(path, value, errs)
+	}
+	if s.If != nil && s.Then != nil && s.If.matches(value) {
+		var thenErrs FieldErrors
+		s.Then.validate(path, value, &thenErrs)
+		for _, err := range thenErrs {
+			err.Message += " when " + s.If.describe()
+			*errs = append(*errs, err)
+		}
+	}
+	if len(s.AnyOf) > 0 && countMatches(s.AnyOf, value) == 0 {
+		add(path, "%s is required", strings.Join(requiredOf(s.AnyOf), " or "))
+	}
+	if len(s.OneOf) > 0 {
+		switch countMatches(s.OneOf, value) {
+		case 0:
+			add(path, "%s is required", strings.Join(requiredOf(s.OneOf), " or "))
+		case 1:
+		default:
+			add(path, "only one of %s may be set", strings.Join(requiredOf(s.OneOf), " or "))
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}, errs *FieldErrors) {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := joinPath(path, name)
+		if prop, ok := s.Properties[name]; ok {
+			prop.validate(field, obj[name], errs)
+			continue
+		}
+		switch additional := s.AdditionalProperties.(type) {
+		case *Schema:
+			additional.validate(field, obj[name], errs)
+		case bool:
+			if additional {
+				continue
+			}
+			msg := "unknown setting"
+			if suggestion := suggestSetting(name, s.Properties); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %s?", suggestion)
+			}
+			*errs = append(*errs, FieldError{Field: field, Message: msg})
+		}
+	}
+
+	for _, name := range s.Required {
+		if obj[name] == nil {
+			*errs = append(*errs, FieldError{Field: joinPath(path, name), Message: "required"})
+		}
+	}
+
+	depNames := make([]string, 0, len(s.DependentRequired))
+	for name := range s.DependentRequired {
+		depNames = append(depNames, name)
+	}
+	sort.Strings(depNames)
+	for _, name := range depNames {
+		if obj[name] == nil {
+			continue
+		}
+		for _, dep := range s.DependentRequired[name] {
+			if obj[dep] == nil {
+				*errs = append(*errs, FieldError{Field: joinPath(path, dep), Message: fmt.Sprintf("required when %s is set", name)})
+			}
+		}
+	}
+}
+
+// matches returns true when the value is valid against the schema.
+func (s *Schema) matches(value interface{}) bool {
+	var errs FieldErrors
+	s.validate("", value, &errs)
+	return len(errs) == 0
+}
+
+// describe explains the condition of an if schema, for error messages.
+func (s *Schema) describe() string {
+	var conditions []string
+	for name, prop := range s.Properties {
+		conditions = append(conditions, fmt.Sprintf("%s is %v", name, prop.Const))
+	}
+	sort.Strings(conditions)
+	return strings.Join(conditions, " and ")
+}
+
+func countMatches(schemas []*Schema, value interface{}) int {
+	var matched int
+	for _, s := range schemas {
+		if s.matches(value) {
+			matched++
+		}
+	}
+	return matched
+}
+
+// requiredOf returns the settings required by the schemas.
+func requiredOf(schemas []*Schema) []string {
+	var required []string
+	for _, s := range schemas {
+		required = append(required, s.Required...)
+	}
+	return required
+}
+
+// suggestSetting returns the setting that the name is probably a misspelling
+// of, or an empty string.
+func suggestSetting(name string, properties map[string]*Schema) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(s))
+	}
+
+	best, bestDistance := "", 3
+	for candidate := range properties {
+		if normalize(candidate) == normalize(name) {
+			return candidate
+		}
+		if d := editDistance(name, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if bestDistance > 2 {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// jsonType returns the JSON Schema type of a value decoded from JSON.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+func article(jsonType string) string {
+	switch jsonType {
+	case "object", "array", "integer":
+		return "an " + jsonType
+	default:
+		return "a " + jsonType
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
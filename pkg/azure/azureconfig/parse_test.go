@@ -0,0 +1,119 @@
+package azureconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg, err := Parse([]byte(`{
+			"vault": "myvault",
+			"credential": "client-assertion", "tenant-id": "mytenant", "client-id": "myclient", "federated-token-file": "/token",
+			"naming-strategy": "namespace-prefix", "namespace-isolation": true,
+			"prefetch": {"enabled": true, "tags": {"team": "a"}, "concurrency": 4},
+			"expiration": {"ttl": "720h", "rules": [{"pattern": "*-output-*", "ttl": "24h"}]},
+			"retry": {"max-retries": -1, "respect-retry-after": null}
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, "myvault", cfg.Vault)
+		assert.Equal(t, map[string]string{"team": "a"}, cfg.Prefetch.Tags)
+		assert.Equal(t, 24*time.Hour, cfg.Expiration.Rules[0].TTL.Duration())
+	})
+
+	testcases := []struct {
+		name   string
+		config string
+		want   FieldErrors
+	}{
+		{
+			name:   "unknown setting",
+			config: `{"vault": "myvault", "logging": {"levle": "warn", "colour": true}}`,
+			want: FieldErrors{
+				{Field: "logging.colour", Message: "unknown setting"},
+				{Field: "logging.levle", Message: "unknown setting, did you mean level?"},
+			},
+		},
+		{
+			name:   "wrong type",
+			config: `{"vault": 1, "prefetch": {"concurrency": 1.5, "tags": {"team": false}}, "transport": {"ca-files": "ca.pem"}}`,
+			want: FieldErrors{
+				{Field: "prefetch.concurrency", Message: "expected an integer, got a number"},
+				{Field: "prefetch.tags.team", Message: "expected a string, got a boolean"},
+				{Field: "transport.ca-files", Message: "expected an array, got a string"},
+				{Field: "vault", Message: "expected a string, got an integer"},
+			},
+		},
+		{
+			name:   "invalid values",
+			config: `{"skip-unchanged": "hash", "expiration": {"ttl": "1 day"}, "retry": {"max-retries": -2}}`,
+			want: FieldErrors{
+				{Field: "expiration.ttl", Message: `invalid value "1 day", expected a duration such as "30s" or "1h30m"`},
+				{Field: "retry.max-retries", Message: "-2 is less than the minimum of -1"},
+				{Field: "skip-unchanged", Message: `invalid value "hash", allowed values are: value, content-hash`},
+			},
+		},
+		{
+			name:   "missing settings",
+			config: `{"credential": "client-assertion", "secret-name-tracing": "hash", "skip-unchanged": "content-hash", "namespace-isolation": true, "prefetch": {"enabled": true}}`,
+			want: FieldErrors{
+				{Field: "prefetch", Message: "prefix or tags is required when enabled is true"},
+				{Field: "tenant-id", Message: "required when credential is client-assertion"},
+				{Field: "client-id", Message: "required when credential is client-assertion"},
+				{Field: "", Message: "federated-token-file or federated-token-command is required when credential is client-assertion"},
+				{Field: "content-hash-key", Message: "required when skip-unchanged is content-hash"},
+				{Field: "secret-name-salt", Message: "required when secret-name-tracing is hash"},
+				{Field: "naming-strategy", Message: "required when namespace-isolation is true"},
+			},
+		},
+		{
+			name: "mutually exclusive settings",
+			config: `{"credential": "client-assertion", "tenant-id": "t", "client-id": "c", "federated-token-file": "/token", "federated-token-command": "get-token",
+				"naming-strategy": "key", "namespace-isolation": true, "transport": {"client-key": "key.pem", "no-proxy": "localhost"},
+				"secret-name-tracing": "omit", "logging": {"azure-sdk": true}}`,
+			want: FieldErrors{
+				{Field: "transport.client-certificate", Message: "required when client-key is set"},
+				{Field: "transport.proxy-url", Message: "required when no-proxy is set"},
+				{Field: "", Message: "only one of federated-token-file or federated-token-command may be set when credential is client-assertion"},
+				{Field: "logging.azure-sdk", Message: "must be false when secret-name-tracing is omit"},
+				{Field: "naming-strategy", Message: `invalid value "key", allowed values are: namespace-prefix, namespace-tag when namespace-isolation is true`},
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.config))
+			var errs FieldErrors
+			require.ErrorAs(t, err, &errs)
+			assert.Equal(t, tc.want, errs)
+		})
+	}
+
+	t.Run("validated", func(t *testing.T) {
+		_, err := Parse([]byte(`{"expiration": {"ttl": "1h", "activation-delay": "2h"}}`))
+		require.EqualError(t, err, "expiration: activation-delay 2h0m0s must be less than ttl 1h0m0s")
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		_, err := Parse([]byte(`vault = "myvault"`))
+		require.ErrorContains(t, err, "the configuration is not valid JSON")
+	})
+}
+
+func TestNewSchema(t *testing.T) {
+	s := NewSchema()
+	assert.Equal(t, SchemaDialect, s.Dialect)
+	assert.Equal(t, false, s.AdditionalProperties)
+	assert.Equal(t, CredentialTypes, s.Properties["credential"].Enum)
+	assert.Equal(t, CredentialTypes, s.Properties["vaults"].Items.Properties["credential"].Enum)
+	assert.Equal(t, "boolean", s.Properties["retry"].Properties["respect-retry-after"].Type)
+	assert.Equal(t, "string", s.Properties["prefetch"].Properties["tags"].AdditionalProperties.(*Schema).Type)
+	assert.Equal(t, durationPattern, s.Properties["expiration"].Properties["rules"].Items.Properties["ttl"].Pattern)
+
+	for _, d := range []string{"30s", "1h30m", "-1.5h", "0", ".5ms"} {
+		assert.True(t, s.Properties["expiration"].Properties["ttl"].matches(d), d)
+	}
+}
@@ -0,0 +1,46 @@
+package azureconfig
+
+import (
+	"github.com/pkg/errors"
+)
+
+// DefaultPrefetchConcurrency is the number of secrets fetched at the same time
+// when Concurrency isn't set.
+const DefaultPrefetchConcurrency = 8
+
+// PrefetchConfig configures fetching the secrets that a bundle is likely to
+// use when the plugin connects, instead of fetching them one at a time.
+type PrefetchConfig struct {
+	// Enabled turns on prefetching. Defaults to false.
+	Enabled bool `json:"enabled"`
+
+	// Prefix selects secrets whose names start with the prefix. The
+	// comparison ignores case, like Key Vault secret names.
+	Prefix string `json:"prefix"`
+
+	// Tags selects secrets that have every one of the tags.
+	Tags map[string]string `json:"tags"`
+
+	// Concurrency is the maximum number of secrets fetched at the same time.
+	// Defaults to DefaultPrefetchConcurrency.
+	Concurrency int `json:"concurrency"`
+}
+
+// GetConcurrency returns the maximum number of secrets fetched at the same time.
+func (c PrefetchConfig) GetConcurrency() int {
+	if c.Concurrency <= 0 {
+		return DefaultPrefetchConcurrency
+	}
+	return c.Concurrency
+}
+
+// Validate checks that the prefetch settings are usable.
+func (c PrefetchConfig) Validate() error {
+	if c.Concurrency < 0 {
+		return errors.Errorf("invalid prefetch concurrency %d, it must be at least 1", c.Concurrency)
+	}
+	if c.Enabled && c.Prefix == "" && len(c.Tags) == 0 {
+		return errors.New("prefetch requires a prefix or tags, so that only the secrets used by bundles are fetched")
+	}
+	return nil
+}
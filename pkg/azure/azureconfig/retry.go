@@ -0,0 +1,52 @@
+package azureconfig
+
+import (
+	"github.com/pkg/errors"
+)
+
+// RetryConfig tunes how requests to Azure are retried when they fail or are
+// throttled. It applies to both Key Vault requests and token requests. Unset
+// values use the Azure SDK defaults.
+type RetryConfig struct {
+	// MaxRetries is the number of times a failed request is retried. Use -1
+	// to disable retries. Defaults to 3.
+	MaxRetries int32 `json:"max-retries"`
+
+	// RetryDelay is the initial delay before a request is retried, which
+	// grows exponentially with each retry. Defaults to 800ms.
+	RetryDelay Duration `json:"retry-delay"`
+
+	// MaxRetryDelay is the longest that the plugin waits before retrying a
+	// request. Defaults to 60s.
+	MaxRetryDelay Duration `json:"max-retry-delay"`
+
+	// TryTimeout is how long a single attempt may take before it is
+	// cancelled and retried. Defaults to no timeout.
+	TryTimeout Duration `json:"try-timeout"`
+
+	// RespectRetryAfter waits for the delay requested by the Retry-After
+	// header of a throttled response before retrying. When the requested
+	// delay is longer than MaxRetryDelay, the request is not retried.
+	// Defaults to true.
+	RespectRetryAfter *bool `json:"respect-retry-after"`
+}
+
+// ShouldRespectRetryAfter reports whether the Retry-After header is honored.
+func (c RetryConfig) ShouldRespectRetryAfter() bool {
+	return c.RespectRetryAfter == nil || *c.RespectRetryAfter
+}
+
+// Validate checks that the retry configuration is usable.
+func (c RetryConfig) Validate() error {
+	if c.MaxRetries < -1 {
+		return errors.Errorf("invalid retry max-retries %d, use -1 to disable retries", c.MaxRetries)
+	}
+	if c.RetryDelay < 0 || c.MaxRetryDelay < 0 || c.TryTimeout < 0 {
+		return errors.New("retry delays and timeouts must not be negative")
+	}
+	if c.MaxRetryDelay > 0 && c.RetryDelay > c.MaxRetryDelay {
+		return errors.Errorf("retry retry-delay %s must not be greater than max-retry-delay %s",
+			c.RetryDelay.Duration(), c.MaxRetryDelay.Duration())
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package azureconfig
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaDialect is the JSON Schema version of the plugin configuration schema.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the durations accepted by time.ParseDuration.
+const durationPattern = `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$`
+
+// Schema is a JSON Schema. Only the keywords used by the plugin configuration
+// schema are supported.
+type Schema struct {
+	Dialect     string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type    string      `json:"type,omitempty"`
+	Enum    []string    `json:"enum,omitempty"`
+	Const   interface{} `json:"const,omitempty"`
+	Pattern string      `json:"pattern,omitempty"`
+	Minimum *int        `json:"minimum,omitempty"`
+
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is either false, which rejects properties that
+	// aren't in Properties, or the schema of their values.
+	AdditionalProperties interface{}         `json:"additionalProperties,omitempty"`
+	Items                *Schema             `json:"items,omitempty"`
+	Required             []string            `json:"required,omitempty"`
+	DependentRequired    map[string][]string `json:"dependentRequired,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	If    *Schema   `json:"if,omitempty"`
+	Then  *Schema   `json:"then,omitempty"`
+}
+
+// schemaConstraints are added to the schema of the setting at the path. Array
+// items are addressed with [], for example vaults[].credential.
+var schemaConstraints = map[string]Schema{
+	"credential":          {Enum: CredentialTypes},
+	"vaults[]":            {AnyOf: requireOneOf("vault", "vault-url")},
+	"vaults[].credential": {Enum: CredentialTypes},
+	"secret-id-fallback":  {Enum: []string{SecretIDFallbackFallback, SecretIDFallbackWarn, SecretIDFallbackStrict}},
+	"naming-strategy":     {Enum: NamingStrategies},
+	"skip-unchanged":      {Enum: []string{SkipUnchangedValue, SkipUnchangedContentHash}},
+	"secret-name-tracing": {Enum: []string{SecretNameTracingPlain, SecretNameTracingHash, SecretNameTracingOmit}},
+	"expiration.rules[]":  {Required: []string{"pattern"}},
+	"prefetch": {AllOf: []*Schema{
+		when("enabled", true, &Schema{AnyOf: requireOneOf("prefix", "tags")}),
+	}},
+	"prefetch.concurrency": {Minimum: intPtr(0)},
+	"logging.level":        {Enum: []string{"trace", "debug", "info", "warn", "error", "off"}},
+	"logging.format":       {Enum: []string{LogFormatJSON, LogFormatText}},
+	"metrics.protocol":     {Enum: []string{MetricsProtocolGRPC, MetricsProtocolHTTP}},
+	"retry.max-retries":    {Minimum: intPtr(-1)},
+	"transport": {DependentRequired: map[string][]string{
+		"no-proxy":           {"proxy-url"},
+		"client-certificate": {"client-key"},
+		"client-key":         {"client-certificate"},
+	}},
+	"": {AllOf: []*Schema{
+		when("credential", CredentialClientAssertion, &Schema{
+			Required: []string{"tenant-id", "client-id"},
+			OneOf:    requireOneOf("federated-token-file", "federated-token-command"),
+		}),
+		when("skip-unchanged", SkipUnchangedContentHash, &Schema{Required: []string{"content-hash-key"}}),
+		when("secret-name-tracing", SecretNameTracingHash, &Schema{
+			Required:   []string{"secret-name-salt"},
+			Properties: noAzureSDKLogging,
+		}),
+		when("secret-name-tracing", SecretNameTracingOmit, &Schema{Properties: noAzureSDKLogging}),
+		when("namespace-isolation", true, &Schema{
+			Required:   []string{"naming-strategy"},
+			Properties: map[string]*Schema{"naming-strategy": {Enum: []string{NamingStrategyNamespacePrefix, NamingStrategyNamespaceTag}}},
+		}),
+	}},
+}
+
+// noAzureSDKLogging turns off the Azure SDK's logging, which includes the
+// secret names in the request urls.
+var noAzureSDKLogging = map[string]*Schema{
+	"logging": {Properties: map[string]*Schema{"azure-sdk": {Const: false}}},
+}
+
+// NewSchema returns the JSON Schema of the plugin configuration. It is built
+// from the fields of Config, so that it always matches what the plugin reads.
+func NewSchema() *Schema {
+	s := schemaFor(reflect.TypeOf(Config{}), "")
+	s.Dialect = SchemaDialect
+	return s
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+func schemaFor(t reflect.Type, path string) *Schema {
+	s := &Schema{}
+	switch {
+	case t == durationType:
+		s.Type = "string"
+		s.Pattern = durationPattern
+		s.Description = `a duration such as "30s" or "1h30m"`
+	case t.Kind() == reflect.Ptr:
+		s = schemaFor(t.Elem(), path)
+	case t.Kind() == reflect.String:
+		s.Type = "string"
+	case t.Kind() == reflect.Bool:
+		s.Type = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		s.Type = "integer"
+	case t.Kind() == reflect.Slice:
+		s.Type = "array"
+		s.Items = schemaFor(t.Elem(), path+"[]")
+	case t.Kind() == reflect.Map:
+		s.Type = "object"
+		s.AdditionalProperties = schemaFor(t.Elem(), path+"{}")
+	case t.Kind() == reflect.Struct:
+		s.Type = "object"
+		s.AdditionalProperties = false
+		s.Properties = make(map[string]*Schema, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			s.Properties[name] = schemaFor(field.Type, joinPath(path, name))
+		}
+	}
+
+	if c, ok := schemaConstraints[path]; ok {
+		s.Enum = c.Enum
+		s.Minimum = c.Minimum
+		s.Required = c.Required
+		s.DependentRequired = c.DependentRequired
+		s.AllOf = c.AllOf
+		s.AnyOf = c.AnyOf
+		s.OneOf = c.OneOf
+	}
+	return s
+}
+
+// when returns a schema that applies then, when the setting has the value.
+func when(setting string, value interface{}, then *Schema) *Schema {
+	return &Schema{
+		If: &Schema{
+			Properties: map[string]*Schema{setting: {Const: value}},
+			Required:   []string{setting},
+		},
+		Then: then,
+	}
+}
+
+// requireOneOf returns schemas that each require one of the settings.
+func requireOneOf(settings ...string) []*Schema {
+	schemas := make([]*Schema, len(settings))
+	for i, setting := range settings {
+		schemas[i] = &Schema{Required: []string{setting}}
+	}
+	return schemas
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
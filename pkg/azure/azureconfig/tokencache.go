@@ -0,0 +1,50 @@
+package azureconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenCacheCredentials are the credentials whose tokens can be cached. The
+// other credentials authenticate as an identity that can change without the
+// configuration changing, such as the account logged in with the Azure CLI, so
+// a cached token could be returned for the wrong identity. This leaves out the
+// Azure CLI, even though it is the slowest credential: the logged in account
+// is only known after running the CLI, which is the time the cache would save.
+var TokenCacheCredentials = []string{CredentialEnvironment, CredentialWorkloadIdentity, CredentialClientAssertion}
+
+// TokenCacheConfig configures an encrypted, on-disk cache of access tokens.
+// Porter starts the plugin for every command, so without the cache a new token
+// is requested every time.
+type TokenCacheConfig struct {
+	// Enabled turns on the token cache. Defaults to false.
+	Enabled bool `json:"enabled"`
+
+	// Path to the cache file. Defaults to
+	// PORTER_HOME/plugins/azure/cache/tokens.json.
+	Path string `json:"path"`
+
+	// Key is used to encrypt the cache file. By default the key is derived
+	// from the machine ID and the current user, so that a copied cache file
+	// can't be read on another machine.
+	Key string `json:"key"`
+}
+
+func (c Config) validateTokenCache() error {
+	if !c.TokenCache.Enabled {
+		return nil
+	}
+	for _, cred := range TokenCacheCredentials {
+		if c.GetCredential() == cred {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("not supported by the %s credential, because the identity that it authenticates as can change", c.GetCredential())
+	if c.GetCredential() == CredentialAzureCLI || c.GetCredential() == CredentialDefault {
+		// The Azure CLI is the slowest credential, so say why it isn't sped up
+		msg += ", so the time that the Azure CLI takes to start can't be saved"
+	}
+	last := len(TokenCacheCredentials) - 1
+	msg += fmt.Sprintf(", use the %s or %s credential instead", strings.Join(TokenCacheCredentials[:last], ", "), TokenCacheCredentials[last])
+	return FieldError{Field: "token-cache.enabled", Message: msg}
+}
@@ -0,0 +1,99 @@
+package azureconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VaultConfig overrides how the plugin authenticates with a single vault, for
+// example a vault in a partner tenant. Settings that aren't set are inherited
+// from the plugin configuration.
+type VaultConfig struct {
+	// Vault is the name of the vault.
+	Vault string `json:"vault"`
+	// VaultUrl is the full url of the vault, and takes precedence over Vault.
+	VaultUrl string `json:"vault-url"`
+
+	// Credential is the type of credential used to authenticate with the
+	// vault. See CredentialTypes for the allowed values.
+	Credential string `json:"credential"`
+	// TenantID is the tenant that the vault belongs to.
+	TenantID string `json:"tenant-id"`
+	// ClientID is the client ID of the application or managed identity that
+	// has access to the vault.
+	ClientID string `json:"client-id"`
+	// FederatedTokenFile is the path to a file containing a federated token.
+	FederatedTokenFile string `json:"federated-token-file"`
+	// FederatedTokenCommand is a command that prints a federated token.
+	FederatedTokenCommand string `json:"federated-token-command"`
+}
+
+// GetVaultURL returns the url of the vault.
+func (v VaultConfig) GetVaultURL() string {
+	return vaultURL(v.Vault, v.VaultUrl)
+}
+
+// GetVaultURL returns the url of the vault that contains bundle secrets.
+func (c Config) GetVaultURL() string {
+	return vaultURL(c.Vault, c.VaultUrl)
+}
+
+// ForVault returns the configuration used to authenticate with the specified
+// vault, applying any overrides from Vaults. The second return value is the
+// normalized url of the matching override, or empty when the plugin's own
+// configuration is used.
+func (c Config) ForVault(vaultURL string) (Config, string) {
+	want := NormalizeVaultURL(vaultURL)
+	for _, v := range c.Vaults {
+		if NormalizeVaultURL(v.GetVaultURL()) != want {
+			continue
+		}
+
+		cfg := c
+		if v.Credential != "" {
+			cfg.Credential = v.Credential
+		}
+		if v.TenantID != "" {
+			cfg.TenantID = v.TenantID
+		}
+		if v.ClientID != "" {
+			cfg.ClientID = v.ClientID
+		}
+		if v.FederatedTokenFile != "" || v.FederatedTokenCommand != "" {
+			cfg.FederatedTokenFile = v.FederatedTokenFile
+			cfg.FederatedTokenCommand = v.FederatedTokenCommand
+		}
+		return cfg, want
+	}
+
+	return c, ""
+}
+
+// NormalizeVaultURL returns a vault url that can be compared with other vault
+// urls.
+func NormalizeVaultURL(vaultURL string) string {
+	return strings.TrimSuffix(strings.ToLower(vaultURL), "/")
+}
+
+func vaultURL(vault string, vaultURL string) string {
+	if vaultURL != "" {
+		return vaultURL
+	}
+	return fmt.Sprintf("https://%s.vault.azure.net", vault)
+}
+
+func (c Config) validateVaults() error {
+	for i, v := range c.Vaults {
+		if v.Vault == "" && v.VaultUrl == "" {
+			return errors.Errorf("vaults[%d]: vault or vault-url is required", i)
+		}
+
+		cfg, _ := c.ForVault(v.GetVaultURL())
+		if err := cfg.validateCredential(); err != nil {
+			return errors.Wrapf(err, "vaults[%d]", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+)
+
+// ConfigOptions are the flags used by the plugin's commands to load the
+// plugin configuration, so that the commands see the same configuration as
+// the plugin.
+type ConfigOptions struct {
+	// ConfigFile is either a Porter configuration file, or a file that only
+	// contains the plugin configuration. The format is determined by the
+	// file extension: .json, .yaml, .yml or .toml.
+	ConfigFile string
+
+	// SecretsName selects the secrets plugin from the Porter configuration
+	// file. Defaults to the default-secrets plugin.
+	SecretsName string
+
+	// Settings that override the configuration file.
+	Vault      string
+	VaultUrl   string
+	Credential string
+	TenantID   string
+	ClientID   string
+	Namespace  string
+}
+
+// LoadConfigFrom loads the plugin configuration from the configuration file,
+// with the flags applied on top of it, and validates it.
+func (p *Plugin) LoadConfigFrom(opts ConfigOptions) error {
+	if opts.ConfigFile != "" {
+		cfg, err := readConfigFile(opts.ConfigFile, opts.SecretsName)
+		if err != nil {
+			return err
+		}
+		p.Config = cfg
+	}
+
+	override(&p.Config.Vault, opts.Vault)
+	override(&p.Config.VaultUrl, opts.VaultUrl)
+	override(&p.Config.Credential, opts.Credential)
+	override(&p.Config.TenantID, opts.TenantID)
+	override(&p.Config.ClientID, opts.ClientID)
+	override(&p.Config.Namespace, opts.Namespace)
+
+	return errors.Wrap(p.Config.Validate(), "invalid azure plugin configuration")
+}
+
+// override replaces the setting with the flag, when the flag is set.
+func override(setting *string, flag string) {
+	if flag != "" {
+		*setting = flag
+	}
+}
+
+// readConfigFile reads the plugin configuration from the file. When the file
+// is a Porter configuration file, the configuration of the selected
+// azure.keyvault secrets plugin is used.
+func readConfigFile(path string, secretsName string) (azureconfig.Config, error) {
+	var cfg azureconfig.Config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not read the configuration file %s", path)
+	}
+
+	var data map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, &data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &data)
+	case ".toml":
+		err = toml.Unmarshal(b, &data)
+	default:
+		return cfg, errors.Errorf("unsupported configuration file %s, the file extension must be .json, .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not parse the configuration file %s", path)
+	}
+
+	if _, ok := data["secrets"]; ok {
+		data, err = selectSecretsConfig(data, secretsName)
+		if err != nil {
+			return cfg, errors.Wrapf(err, "invalid Porter configuration file %s", path)
+		}
+	}
+
+	// The configuration is passed to the plugin as JSON, so convert it the same way
+	b, err = json.Marshal(data)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not convert the configuration file %s", path)
+	}
+	if len(data) == 0 {
+		return cfg, nil
+	}
+	cfg, err = azureconfig.Parse(b)
+	return cfg, errors.Wrapf(err, "invalid plugin configuration in %s", path)
+}
+
+// keyVaultPluginName is the name of the secrets plugin in the Porter
+// configuration file.
+const keyVaultPluginName = "azure.keyvault"
+
+// selectSecretsConfig returns the configuration of an azure.keyvault secrets
+// plugin from a Porter configuration file. The plugin is selected by name, or
+// defaults to the default-secrets plugin, or the only azure.keyvault plugin.
+func selectSecretsConfig(porterConfig map[string]interface{}, name string) (map[string]interface{}, error) {
+	var plugins []map[string]interface{}
+	entries, _ := porterConfig["secrets"].([]interface{})
+	for _, e := range entries {
+		if entry, ok := e.(map[string]interface{}); ok && entry["plugin"] == keyVaultPluginName {
+			plugins = append(plugins, entry)
+		}
+	}
+
+	selected := func(name string) map[string]interface{} {
+		for _, entry := range plugins {
+			if entry["name"] == name {
+				return entry
+			}
+		}
+		return nil
+	}
+
+	var entry map[string]interface{}
+	switch {
+	case name != "":
+		if entry = selected(name); entry == nil {
+			return nil, errors.Errorf("no azure.keyvault secrets plugin named %q was found", name)
+		}
+	case selected(fmt.Sprint(porterConfig["default-secrets"])) != nil:
+		entry = selected(fmt.Sprint(porterConfig["default-secrets"]))
+	case len(plugins) == 1:
+		entry = plugins[0]
+	case len(plugins) == 0:
+		return nil, errors.New("no azure.keyvault secrets plugin was found")
+	default:
+		return nil, errors.New("more than one azure.keyvault secrets plugin was found, select one with --secrets")
+	}
+
+	cfg, _ := entry["config"].(map[string]interface{})
+	return cfg, nil
+}
@@ -0,0 +1,111 @@
+package azure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestPlugin_LoadConfigFrom(t *testing.T) {
+	const porterConfig = `
+default-secrets = "prod"
+
+[[secrets]]
+name = "dev"
+plugin = "azure.keyvault"
+
+[secrets.config]
+vault = "devvault"
+
+[[secrets]]
+name = "prod"
+plugin = "azure.keyvault"
+
+[secrets.config]
+vault = "prodvault"
+secret-id-fallback = "strict"
+
+[secrets.config.retry]
+retry-delay = "2s"
+`
+
+	t.Run("porter config default-secrets", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		require.NoError(t, p.LoadConfigFrom(ConfigOptions{ConfigFile: writeConfigFile(t, "config.toml", porterConfig)}))
+		assert.Equal(t, "prodvault", p.Config.Vault)
+		assert.Equal(t, "strict", p.Config.SecretIDFallback)
+		assert.Equal(t, "2s", p.Config.Retry.RetryDelay.Duration().String())
+	})
+
+	t.Run("porter config by name", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		opts := ConfigOptions{ConfigFile: writeConfigFile(t, "config.toml", porterConfig), SecretsName: "dev"}
+		require.NoError(t, p.LoadConfigFrom(opts))
+		assert.Equal(t, "devvault", p.Config.Vault)
+	})
+
+	t.Run("porter config unknown name", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		opts := ConfigOptions{ConfigFile: writeConfigFile(t, "config.toml", porterConfig), SecretsName: "test"}
+		require.ErrorContains(t, p.LoadConfigFrom(opts), `no azure.keyvault secrets plugin named "test" was found`)
+	})
+
+	t.Run("plugin config in yaml", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		path := writeConfigFile(t, "azure.yaml", "vault: myvault\nlogging:\n  level: info\n")
+		require.NoError(t, p.LoadConfigFrom(ConfigOptions{ConfigFile: path}))
+		assert.Equal(t, "myvault", p.Config.Vault)
+		assert.Equal(t, "info", p.Config.Logging.Level)
+	})
+
+	t.Run("flags override the file", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		path := writeConfigFile(t, "azure.json", `{"vault": "myvault", "tenant-id": "mytenant"}`)
+		require.NoError(t, p.LoadConfigFrom(ConfigOptions{ConfigFile: path, Vault: "othervault", Credential: "azure-cli"}))
+		assert.Equal(t, "othervault", p.Config.Vault)
+		assert.Equal(t, "mytenant", p.Config.TenantID)
+		assert.Equal(t, "azure-cli", p.Config.Credential)
+	})
+
+	t.Run("flags only", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		require.NoError(t, p.LoadConfigFrom(ConfigOptions{VaultUrl: "https://myvault.vault.azure.net"}))
+		assert.Equal(t, "https://myvault.vault.azure.net", p.Config.VaultUrl)
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		require.ErrorContains(t, p.LoadConfigFrom(ConfigOptions{Credential: "password"}), "invalid azure plugin configuration")
+	})
+
+	t.Run("unsupported file", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		path := writeConfigFile(t, "azure.ini", "vault=myvault")
+		require.ErrorContains(t, p.LoadConfigFrom(ConfigOptions{ConfigFile: path}), "unsupported configuration file")
+	})
+}
+
+func TestKeyVaultSetOptions_Validate(t *testing.T) {
+	var opts KeyVaultSetOptions
+	require.NoError(t, opts.Validate([]string{"mysecret"}))
+	assert.False(t, opts.hasValue, "the value should be read from stdin")
+
+	opts = KeyVaultSetOptions{}
+	require.NoError(t, opts.Validate([]string{"mysecret", "myvalue"}))
+	assert.Equal(t, "myvalue", opts.Value)
+
+	require.Error(t, opts.Validate(nil))
+
+	opts = KeyVaultSetOptions{StdinIsTerminal: true}
+	require.ErrorContains(t, opts.Validate([]string{"mysecret"}), "the value can't be read from stdin because it is a terminal")
+	require.NoError(t, opts.Validate([]string{"mysecret", "myvalue"}))
+}
@@ -0,0 +1,348 @@
+package azure
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+)
+
+// FormatToml prints the Porter configuration as TOML.
+const FormatToml printer.Format = "toml"
+
+// The Azure clouds that config init can generate a configuration for.
+const (
+	CloudPublic       = "public"
+	CloudChina        = "china"
+	CloudUSGovernment = "usgovernment"
+)
+
+// Clouds lists the allowed values for ConfigInitOptions.Cloud.
+var Clouds = []string{CloudPublic, CloudChina, CloudUSGovernment}
+
+// cloudSettings are the vault domain and Microsoft Entra authority of a cloud.
+type cloudSettings struct {
+	VaultDomain   string
+	AuthorityHost string
+}
+
+var clouds = map[string]cloudSettings{
+	CloudPublic:       {VaultDomain: "vault.azure.net", AuthorityHost: "https://login.microsoftonline.com/"},
+	CloudChina:        {VaultDomain: "vault.azure.cn", AuthorityHost: "https://login.chinacloudapi.cn/"},
+	CloudUSGovernment: {VaultDomain: "vault.usgovcloudapi.net", AuthorityHost: "https://login.microsoftonline.us/"},
+}
+
+// ConfigInitOptions are the arguments of the config init command.
+type ConfigInitOptions struct {
+	printer.PrintOptions
+
+	// Name of the plugins in the Porter configuration. Defaults to the name
+	// of each plugin, such as azure-keyvault.
+	Name string
+
+	// Vault is the name or url of the vault.
+	Vault string
+
+	// Cloud is the Azure cloud that the vault is in. Defaults to public.
+	Cloud string
+
+	// Settings of the credential, see azureconfig.Config.
+	Credential            string
+	TenantID              string
+	ClientID              string
+	FederatedTokenFile    string
+	FederatedTokenCommand string
+
+	// Test connects to the vault with the configuration before printing it.
+	Test bool
+
+	// Prompt asks for the settings that weren't specified with flags.
+	Prompt bool
+}
+
+func (o *ConfigInitOptions) Validate() error {
+	if err := validateCloud(o.Cloud); err != nil {
+		return err
+	}
+	return o.PrintOptions.Validate(FormatToml, []printer.Format{FormatToml, printer.FormatYaml, printer.FormatJson})
+}
+
+// validateCloud returns an error when the cloud is set, and isn't one of
+// Clouds.
+func validateCloud(cloud string) error {
+	if _, ok := clouds[cloud]; cloud != "" && !ok {
+		return errors.Errorf("invalid cloud %q, allowed values are: %s", cloud, strings.Join(Clouds, ", "))
+	}
+	return nil
+}
+
+// ConfigInit prints a Porter configuration that uses the plugins, with the
+// plugin configuration built from the options. Settings that weren't
+// specified are asked for when Prompt is set. The configuration is validated,
+// and when Test is set the plugin connects to the vault with it first, and an
+// error is returned when the connection fails.
+func (p *Plugin) ConfigInit(ctx context.Context, opts ConfigInitOptions) error {
+	if opts.Prompt {
+		if err := p.promptConfigInit(&opts); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := opts.config()
+	if err != nil {
+		return err
+	}
+
+	if opts.Cloud != "" && opts.Cloud != CloudPublic {
+		fmt.Fprintf(p.Err, "Set AZURE_AUTHORITY_HOST=%s where Porter runs, so that the credential authenticates with the %s cloud.\n",
+			clouds[opts.Cloud].AuthorityHost, opts.Cloud)
+	}
+
+	if opts.Test {
+		p.Config = cfg
+		checks := p.newStore().Doctor(ctx, keyvault.DoctorOptions{IdentifyCredential: true})
+		// The checks go to stderr, so that stdout only has the configuration
+		if err := p.printChecks(p.Err, checks, printer.FormatPlaintext); err != nil {
+			return err
+		}
+		for _, check := range checks {
+			if check.Status == keyvault.CheckFail {
+				return errors.New("the connection test failed, fix the settings or run without --test to print the configuration anyway")
+			}
+		}
+	}
+
+	snippet, err := porterConfig(opts.Name, cfg)
+	if err != nil {
+		return err
+	}
+	return p.printPorterConfig(snippet, opts.Format)
+}
+
+// config returns the plugin configuration for the options, and validates it.
+func (o ConfigInitOptions) config() (azureconfig.Config, error) {
+	cfg := azureconfig.Config{
+		Credential:            o.Credential,
+		TenantID:              o.TenantID,
+		ClientID:              o.ClientID,
+		FederatedTokenFile:    o.FederatedTokenFile,
+		FederatedTokenCommand: o.FederatedTokenCommand,
+	}
+
+	// The cloud may have been typed at the prompt, after Validate
+	if err := validateCloud(o.Cloud); err != nil {
+		return cfg, err
+	}
+
+	switch {
+	case o.Vault == "":
+		return cfg, errors.New("the vault must be specified with --vault")
+	case strings.Contains(o.Vault, "://"):
+		cfg.VaultUrl = o.Vault
+	case o.Cloud == "" || o.Cloud == CloudPublic:
+		cfg.Vault = o.Vault
+	default:
+		// Only the url of the vault has the domain of the cloud
+		cfg.VaultUrl = fmt.Sprintf("https://%s.%s", o.Vault, clouds[o.Cloud].VaultDomain)
+	}
+
+	// The default credential is left out, so that the configuration is short
+	if cfg.Credential == azureconfig.CredentialDefault {
+		cfg.Credential = ""
+	}
+
+	return cfg, errors.Wrap(cfg.Validate(), "invalid azure plugin configuration")
+}
+
+// promptConfigInit asks for the settings that weren't specified with flags.
+// The questions are written to stderr, so that stdout only has the
+// configuration.
+func (p *Plugin) promptConfigInit(opts *ConfigInitOptions) error {
+	pr := prompter{in: bufio.NewReader(p.In), out: p.Err}
+
+	var err error
+	ask := func(setting *string, question string, defaultValue string) {
+		if err == nil && *setting == "" {
+			*setting, err = pr.ask(question, defaultValue)
+		}
+	}
+
+	ask(&opts.Vault, "Vault name or url", "")
+	askCloud := func() {
+		ask(&opts.Cloud, fmt.Sprintf("Azure cloud (%s)", strings.Join(Clouds, ", ")), CloudPublic)
+	}
+	askCloud()
+	// Ask again until the answer is a known cloud, instead of printing a
+	// vault url without a domain
+	for err == nil {
+		cloudErr := validateCloud(opts.Cloud)
+		if cloudErr == nil {
+			break
+		}
+		fmt.Fprintln(pr.out, cloudErr)
+		opts.Cloud = ""
+		askCloud()
+	}
+	ask(&opts.Credential, fmt.Sprintf("Credential (%s)", strings.Join(azureconfig.CredentialTypes, ", ")), azureconfig.CredentialDefault)
+	if err != nil {
+		return err
+	}
+
+	if opts.Credential == azureconfig.CredentialClientAssertion {
+		ask(&opts.TenantID, "Tenant ID", "")
+		ask(&opts.ClientID, "Client ID", "")
+		if opts.FederatedTokenCommand == "" {
+			ask(&opts.FederatedTokenFile, "Federated token file, or leave empty to use a command", "")
+		}
+		if opts.FederatedTokenFile == "" {
+			ask(&opts.FederatedTokenCommand, "Command that prints the federated token", "")
+		}
+	} else if opts.Credential != azureconfig.CredentialAzureCLI {
+		ask(&opts.TenantID, "Tenant ID, or leave empty to use AZURE_TENANT_ID", "")
+		ask(&opts.ClientID, "Client ID, or leave empty to use AZURE_CLIENT_ID", "")
+	}
+	if err != nil {
+		return err
+	}
+
+	if !opts.Test {
+		answer, err := pr.ask("Test the connection to the vault now? (y/n)", "y")
+		if err != nil {
+			return err
+		}
+		opts.Test = strings.HasPrefix(strings.ToLower(answer), "y")
+	}
+	return nil
+}
+
+// prompter asks questions and reads the answers, one per line.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask returns the answer to the question, or the default value when the
+// answer is empty.
+func (pr prompter) ask(question string, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(pr.out, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(pr.out, "%s: ", question)
+	}
+
+	line, err := pr.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "could not read the answer")
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		if err == io.EOF && defaultValue == "" {
+			fmt.Fprintln(pr.out)
+		}
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// porterConfig returns a Porter configuration that uses every plugin in the
+// binary, with the plugin configuration. Each plugin is made the default for
+// its interface, such as default-secrets.
+func porterConfig(name string, cfg azureconfig.Config) (map[string]interface{}, error) {
+	pluginConfig, err := configValues(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	snippet := make(map[string]interface{})
+	for _, key := range pluginKeys() {
+		// Plugin keys are <interface>.<plugin>, for example secrets.azure.keyvault
+		iface, plugin, _ := strings.Cut(key, ".")
+		entryName := name
+		if entryName == "" {
+			entryName = strings.ReplaceAll(plugin, ".", "-")
+		}
+
+		entries, _ := snippet[iface].([]map[string]interface{})
+		snippet[iface] = append(entries, map[string]interface{}{
+			"name":   entryName,
+			"plugin": plugin,
+			"config": pluginConfig,
+		})
+		snippet["default-"+iface] = entryName
+	}
+	return snippet, nil
+}
+
+// configValues converts the plugin configuration to the settings that are
+// set, named the same way as in the configuration file.
+func configValues(cfg azureconfig.Config) (map[string]interface{}, error) {
+	values, err := toValues(cfg)
+	if err != nil {
+		return nil, err
+	}
+	zero, err := toValues(azureconfig.Config{})
+	if err != nil {
+		return nil, err
+	}
+	pruneZero(values, zero)
+	return values, nil
+}
+
+func toValues(cfg azureconfig.Config) (map[string]interface{}, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert the plugin configuration")
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, errors.Wrap(err, "could not convert the plugin configuration")
+	}
+	return values, nil
+}
+
+// pruneZero removes the settings that have the same value as in the zero
+// configuration, which the plugin treats as not set.
+func pruneZero(values map[string]interface{}, zero map[string]interface{}) {
+	for k, v := range values {
+		if nested, ok := v.(map[string]interface{}); ok {
+			zeroNested, _ := zero[k].(map[string]interface{})
+			pruneZero(nested, zeroNested)
+			if len(nested) == 0 {
+				delete(values, k)
+			}
+			continue
+		}
+		if reflect.DeepEqual(v, zero[k]) {
+			delete(values, k)
+		}
+	}
+}
+
+func (p *Plugin) printPorterConfig(snippet map[string]interface{}, format printer.Format) error {
+	var b []byte
+	var err error
+	switch format {
+	case printer.FormatJson:
+		b, err = json.MarshalIndent(snippet, "", "  ")
+		b = append(b, '\n')
+	case printer.FormatYaml:
+		b, err = yaml.Marshal(snippet)
+	default:
+		b, err = toml.Marshal(snippet)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not format the configuration as %s", format)
+	}
+	_, err = p.Out.Write(b)
+	return err
+}
@@ -0,0 +1,129 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runConfigInit(t *testing.T, opts ConfigInitOptions, in string) (string, string, error) {
+	p := NewTestPlugin(t)
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	p.In, p.Out, p.Err = strings.NewReader(in), out, errOut
+
+	require.NoError(t, opts.Validate())
+	err := p.ConfigInit(context.Background(), opts)
+	return out.String(), errOut.String(), err
+}
+
+func TestPlugin_ConfigInit(t *testing.T) {
+	t.Run("toml", func(t *testing.T) {
+		out, _, err := runConfigInit(t, ConfigInitOptions{Vault: "myvault", Credential: "azure-cli"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, `default-secrets = 'azure-keyvault'
+
+[[secrets]]
+name = 'azure-keyvault'
+plugin = 'azure.keyvault'
+
+[secrets.config]
+credential = 'azure-cli'
+vault = 'myvault'
+`, out)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		opts := ConfigInitOptions{Name: "mysecrets", Vault: "https://myvault.vault.azure.net", Credential: "default"}
+		opts.RawFormat = string(printer.FormatYaml)
+		out, _, err := runConfigInit(t, opts, "")
+		require.NoError(t, err)
+		assert.Equal(t, `default-secrets: mysecrets
+secrets:
+- config:
+    vault-url: https://myvault.vault.azure.net
+  name: mysecrets
+  plugin: azure.keyvault
+`, out)
+	})
+
+	t.Run("cloud", func(t *testing.T) {
+		opts := ConfigInitOptions{Vault: "govvault", Cloud: CloudUSGovernment}
+		opts.RawFormat = string(printer.FormatJson)
+		out, errOut, err := runConfigInit(t, opts, "")
+		require.NoError(t, err)
+		assert.Contains(t, out, `"vault-url": "https://govvault.vault.usgovcloudapi.net"`)
+		assert.Contains(t, errOut, "AZURE_AUTHORITY_HOST=https://login.microsoftonline.us/")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := runConfigInit(t, ConfigInitOptions{Vault: "myvault", Credential: "client-assertion"}, "")
+		require.ErrorContains(t, err, "tenant-id and client-id are required by the client-assertion credential")
+
+		_, _, err = runConfigInit(t, ConfigInitOptions{}, "")
+		require.EqualError(t, err, "the vault must be specified with --vault")
+	})
+
+	t.Run("prompt", func(t *testing.T) {
+		answers := strings.Join([]string{
+			"myvault",          // vault
+			"",                 // cloud
+			"client-assertion", // credential
+			"mytenant",         // tenant
+			"myclient",         // client
+			"",                 // federated token file
+			"gh-oidc-token",    // federated token command
+			"n",                // test
+		}, "\n") + "\n"
+		out, errOut, err := runConfigInit(t, ConfigInitOptions{Prompt: true}, answers)
+		require.NoError(t, err)
+		assert.Contains(t, errOut, "Vault name or url: ")
+		assert.Contains(t, errOut, "Azure cloud (public, china, usgovernment) [public]: ")
+
+		path := filepath.Join(t.TempDir(), "config.toml")
+		require.NoError(t, os.WriteFile(path, []byte(out), 0600))
+		cfg, err := readConfigFile(path, "")
+		require.NoError(t, err)
+		assert.Equal(t, azureconfig.Config{
+			Credential:            "client-assertion",
+			TenantID:              "mytenant",
+			ClientID:              "myclient",
+			FederatedTokenCommand: "gh-oidc-token",
+			Vault:                 "myvault",
+		}, cfg)
+	})
+
+	t.Run("prompt asks again for an unknown cloud", func(t *testing.T) {
+		opts := ConfigInitOptions{Prompt: true, Vault: "myvault", Credential: "azure-cli"}
+		opts.RawFormat = string(printer.FormatJson)
+		out, errOut, err := runConfigInit(t, opts, "gov\nusgovernment\nn\n")
+		require.NoError(t, err)
+		assert.Contains(t, errOut, `invalid cloud "gov", allowed values are: public, china, usgovernment`)
+		assert.Contains(t, out, `"vault-url": "https://myvault.vault.usgovcloudapi.net"`)
+	})
+
+	t.Run("unknown cloud without a prompt", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.Out, p.Err = &bytes.Buffer{}, &bytes.Buffer{}
+		err := p.ConfigInit(context.Background(), ConfigInitOptions{Vault: "myvault", Cloud: "gov"})
+		require.EqualError(t, err, `invalid cloud "gov", allowed values are: public, china, usgovernment`)
+	})
+
+	t.Run("prompt skips flags", func(t *testing.T) {
+		_, errOut, err := runConfigInit(t, ConfigInitOptions{Prompt: true, Vault: "myvault", Cloud: "public", Credential: "azure-cli"}, "n\n")
+		require.NoError(t, err)
+		assert.Equal(t, "Test the connection to the vault now? (y/n) [y]: ", errOut)
+	})
+}
+
+func TestConfigInitOptions_Validate(t *testing.T) {
+	opts := ConfigInitOptions{Cloud: "germany"}
+	require.EqualError(t, opts.Validate(), `invalid cloud "germany", allowed values are: public, china, usgovernment`)
+}
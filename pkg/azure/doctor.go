@@ -0,0 +1,115 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/pkg/errors"
+)
+
+// DoctorOptions are the arguments of the doctor command.
+type DoctorOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// CanarySecret is the secret written to check the Set secret permission.
+	CanarySecret string
+
+	// Write writes the canary secret to check the Set secret permission.
+	Write bool
+}
+
+func (o *DoctorOptions) Validate() error {
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// Doctor checks the plugin configuration, and that the plugin can authenticate
+// and use the configured vault, and prints a report. An error is returned when
+// a check fails.
+func (p *Plugin) Doctor(ctx context.Context, opts DoctorOptions) error {
+	var checks []keyvault.Check
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		checks = append(checks, keyvault.Check{Name: "config", Status: keyvault.CheckFail, Message: err.Error()})
+	} else {
+		checks = append(checks, p.checkConfig()...)
+		checks = append(checks, store.Doctor(ctx, keyvault.DoctorOptions{
+			CanarySecret:       opts.CanarySecret,
+			Write:              opts.Write,
+			IdentifyCredential: true,
+		})...)
+	}
+
+	if err := p.printChecks(p.Out, checks, opts.Format); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, check := range checks {
+		if check.Status == keyvault.CheckFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// checkConfig reports the vault and credential that the configuration selects,
+// and warns when env-azure-prefix doesn't match any environment variables.
+func (p *Plugin) checkConfig() []keyvault.Check {
+	checks := []keyvault.Check{{
+		Name:    "config",
+		Status:  keyvault.CheckPass,
+		Message: fmt.Sprintf("using the %s credential with %s", p.Config.GetCredential(), p.Config.GetVaultURL()),
+	}}
+
+	prefix := p.Config.EnvAzurePrefix
+	if prefix == "" || prefix == "AZURE_" {
+		return checks
+	}
+
+	var found []string
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, prefix) {
+			found = append(found, name)
+		}
+	}
+	check := keyvault.Check{Name: "env-azure-prefix", Status: keyvault.CheckPass}
+	if len(found) == 0 {
+		check.Status = keyvault.CheckWarn
+		check.Message = fmt.Sprintf("no environment variables start with %s", prefix)
+		check.Hint = fmt.Sprintf("set %sTENANT_ID, %sCLIENT_ID and %sCLIENT_SECRET, or fix env-azure-prefix in the plugin configuration", prefix, prefix, prefix)
+	} else {
+		check.Message = fmt.Sprintf("found %s", strings.Join(found, ", "))
+	}
+	return append(checks, check)
+}
+
+func (p *Plugin) printChecks(out io.Writer, checks []keyvault.Check, format printer.Format) error {
+	if format == printer.FormatJson {
+		b, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the checks as json")
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(string(check.Status)), check.Name, check.Message)
+		if check.Hint != "" {
+			fmt.Fprintf(w, "\t\thint: %s\n", check.Hint)
+		}
+	}
+	return w.Flush()
+}
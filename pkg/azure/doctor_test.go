@@ -0,0 +1,54 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_Doctor_InvalidConfig(t *testing.T) {
+	p := NewTestPlugin(t)
+	opts := DoctorOptions{ConfigOptions: ConfigOptions{Credential: "password"}}
+	opts.RawFormat = string(printer.FormatJson)
+	require.NoError(t, opts.Validate())
+
+	err := p.Doctor(context.Background(), opts)
+	require.EqualError(t, err, "1 of 1 checks failed")
+
+	var checks []keyvault.Check
+	require.NoError(t, json.Unmarshal([]byte(p.TestContext.GetOutput()), &checks))
+	require.Len(t, checks, 1)
+	assert.Equal(t, "config", checks[0].Name)
+	assert.Equal(t, keyvault.CheckFail, checks[0].Status)
+	assert.Contains(t, checks[0].Message, "invalid azure plugin configuration")
+}
+
+func TestPlugin_CheckConfig(t *testing.T) {
+	t.Run("prefix without variables", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.Config.Vault = "myvault"
+		p.Config.EnvAzurePrefix = "NOTSET_AZURE_"
+
+		checks := p.checkConfig()
+		require.Len(t, checks, 2)
+		assert.Contains(t, checks[0].Message, "https://myvault.vault.azure.net")
+		assert.Equal(t, keyvault.CheckWarn, checks[1].Status)
+		assert.Contains(t, checks[1].Hint, "NOTSET_AZURE_TENANT_ID")
+	})
+
+	t.Run("prefix with variables", func(t *testing.T) {
+		t.Setenv("DEV_AZURE_CLIENT_ID", "myclient")
+		p := NewTestPlugin(t)
+		p.Config.EnvAzurePrefix = "DEV_AZURE_"
+
+		checks := p.checkConfig()
+		require.Len(t, checks, 2)
+		assert.Equal(t, keyvault.CheckPass, checks[1].Status)
+		assert.Contains(t, checks[1].Message, "DEV_AZURE_CLIENT_ID")
+	})
+}
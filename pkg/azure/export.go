@@ -0,0 +1,205 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PassphraseEnv is the environment variable that contains the passphrase
+	// used to encrypt and decrypt exports, when --passphrase-file isn't set.
+	PassphraseEnv = "PORTER_AZURE_EXPORT_PASSPHRASE"
+
+	// minPassphraseLength is the shortest passphrase allowed for exports.
+	minPassphraseLength = 12
+)
+
+// KeyVaultExportOptions are the arguments of the keyvault export command.
+type KeyVaultExportOptions struct {
+	ConfigOptions
+
+	// File is where the encrypted archive is written.
+	File string
+
+	// PassphraseFile contains the passphrase that encrypts the archive.
+	PassphraseFile string
+
+	// Prefix selects the secrets whose names start with the prefix.
+	Prefix string
+
+	// Tags select the secrets that have every tag, formatted as NAME=VALUE.
+	Tags []string
+
+	// All exports secrets that weren't created by the plugin too.
+	All bool
+}
+
+func (o *KeyVaultExportOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one positional argument, FILE, is expected")
+	}
+	o.File = args[0]
+	_, err := parseTags(o.Tags)
+	return err
+}
+
+// KeyVaultImportOptions are the arguments of the keyvault import command.
+type KeyVaultImportOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// File is the encrypted archive written by export.
+	File string
+
+	// PassphraseFile contains the passphrase that decrypts the archive.
+	PassphraseFile string
+
+	// DryRun reports the secrets that would be written, without writing them.
+	DryRun bool
+
+	// Overwrite writes secrets that already exist with a different value.
+	Overwrite bool
+}
+
+func (o *KeyVaultImportOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one positional argument, FILE, is expected")
+	}
+	o.File = args[0]
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultExport writes the secrets created by the plugin to an archive that
+// is encrypted with a passphrase.
+func (p *Plugin) KeyVaultExport(ctx context.Context, opts KeyVaultExportOptions) error {
+	passphrase, err := readPassphrase(opts.PassphraseFile)
+	if err != nil {
+		return err
+	}
+	tags, err := parseTags(opts.Tags)
+	if err != nil {
+		return err
+	}
+
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+	export, err := store.Export(ctx, keyvault.ExportOptions{Prefix: opts.Prefix, Tags: tags, All: opts.All})
+	if err != nil {
+		return err
+	}
+
+	data, err := keyvault.EncryptExport(export, passphrase)
+	if err != nil {
+		return errors.Wrap(err, "could not encrypt the export")
+	}
+	if err := os.WriteFile(opts.File, data, 0600); err != nil {
+		return errors.Wrapf(err, "could not write the export to %s", opts.File)
+	}
+
+	fmt.Fprintf(p.Out, "Exported %d secrets from %s to %s.\n", len(export.Secrets), export.Vault, opts.File)
+	return nil
+}
+
+// KeyVaultImport writes the secrets from an export archive to the configured
+// vault, and prints a report. An error is returned when a secret could not be
+// written.
+func (p *Plugin) KeyVaultImport(ctx context.Context, opts KeyVaultImportOptions) error {
+	passphrase, err := readPassphrase(opts.PassphraseFile)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(opts.File)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the export from %s", opts.File)
+	}
+	export, err := keyvault.DecryptExport(data, passphrase)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the export from %s", opts.File)
+	}
+
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+	report, err := store.Import(ctx, export, keyvault.ImportOptions{DryRun: opts.DryRun, Overwrite: opts.Overwrite})
+	if err != nil {
+		return err
+	}
+
+	if err := p.printImportReport(report, opts.Format); err != nil {
+		return err
+	}
+	if failed := report.Failed(); failed > 0 {
+		return errors.Errorf("%d of %d secrets could not be imported", failed, len(report.Secrets))
+	}
+	return nil
+}
+
+// readPassphrase reads the passphrase from the file, or from the
+// PORTER_AZURE_EXPORT_PASSPHRASE environment variable.
+func readPassphrase(file string) ([]byte, error) {
+	passphrase := os.Getenv(PassphraseEnv)
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read the passphrase from %s", file)
+		}
+		passphrase = strings.TrimRight(string(b), "\r\n")
+	}
+
+	if passphrase == "" {
+		return nil, errors.Errorf("a passphrase is required, set it with --passphrase-file or the %s environment variable", PassphraseEnv)
+	}
+	if len(passphrase) < minPassphraseLength {
+		return nil, errors.Errorf("the passphrase must be at least %d characters", minPassphraseLength)
+	}
+	return []byte(passphrase), nil
+}
+
+func (p *Plugin) printImportReport(report keyvault.ImportReport, format printer.Format) error {
+	if format == printer.FormatJson {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the report as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	if len(report.Secrets) > 0 {
+		w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tNAME\tSTATUS")
+		for _, secret := range report.Secrets {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", secret.Key, secret.Name, secret.Status)
+			if secret.Error != "" {
+				fmt.Fprintf(w, "\t\terror: %s\n", secret.Error)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var imported int
+	for _, secret := range report.Secrets {
+		if secret.Status == keyvault.ImportImported || secret.Status == keyvault.ImportWouldImport {
+			imported++
+		}
+	}
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(p.Out, "%s %d of %d secrets to %s.\n", verb, imported, len(report.Secrets), report.Vault)
+	return nil
+}
@@ -0,0 +1,60 @@
+package azure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPassphrase(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		t.Setenv(PassphraseEnv, "from the environment")
+		path := writeConfigFile(t, "passphrase", "correct horse battery staple\n")
+
+		passphrase, err := readPassphrase(path)
+		require.NoError(t, err)
+		assert.Equal(t, "correct horse battery staple", string(passphrase))
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv(PassphraseEnv, "from the environment")
+
+		passphrase, err := readPassphrase("")
+		require.NoError(t, err)
+		assert.Equal(t, "from the environment", string(passphrase))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		t.Setenv(PassphraseEnv, "")
+
+		_, err := readPassphrase("")
+		require.ErrorContains(t, err, "a passphrase is required")
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		t.Setenv(PassphraseEnv, "secret")
+
+		_, err := readPassphrase("")
+		require.EqualError(t, err, "the passphrase must be at least 12 characters")
+	})
+}
+
+func TestPlugin_KeyVaultImport_WrongPassphrase(t *testing.T) {
+	data, err := keyvault.EncryptExport(keyvault.Export{}, []byte("correct horse battery staple"))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "secrets.export")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	t.Setenv(PassphraseEnv, "the wrong passphrase")
+
+	p := NewTestPlugin(t)
+	opts := KeyVaultImportOptions{ConfigOptions: ConfigOptions{Vault: "myvault"}}
+	require.NoError(t, opts.Validate([]string{path}))
+
+	err = p.KeyVaultImport(context.Background(), opts)
+	require.ErrorIs(t, err, keyvault.ErrDecryptFailed)
+}
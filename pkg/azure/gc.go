@@ -0,0 +1,155 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/pkg/errors"
+)
+
+// DefaultGCMinAge is how old a secret must be before gc deletes it.
+const DefaultGCMinAge = 24 * time.Hour
+
+// KeyVaultGCOptions are the arguments of the keyvault gc command.
+type KeyVaultGCOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// LiveRunsFile contains the IDs of the runs that still exist, such as an
+	// export of Porter's storage or a list of run IDs. Use - to read stdin.
+	LiveRunsFile string
+
+	// RunIDs are the IDs of runs that still exist.
+	RunIDs []string
+
+	// MinAge keeps secrets that were updated more recently.
+	MinAge time.Duration
+
+	// DryRun reports the orphaned secrets without deleting them.
+	DryRun bool
+
+	// Purge permanently deletes the orphaned secrets.
+	Purge bool
+
+	// IncludeUntagged also deletes secrets without the porter-key tag.
+	IncludeUntagged bool
+}
+
+func (o *KeyVaultGCOptions) Validate() error {
+	if o.LiveRunsFile == "" && len(o.RunIDs) == 0 {
+		return errors.New("the live runs must be specified with --live-runs or --run-id")
+	}
+	if o.MinAge < 0 {
+		return errors.Errorf("invalid --min-age %s, it must not be negative", o.MinAge)
+	}
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultGC deletes the secrets of runs that no longer exist, and prints a
+// report. An error is returned when a secret could not be deleted.
+func (p *Plugin) KeyVaultGC(ctx context.Context, opts KeyVaultGCOptions) error {
+	liveRuns, err := p.readLiveRuns(opts)
+	if err != nil {
+		return err
+	}
+
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	report, err := store.GC(ctx, keyvault.GCOptions{
+		LiveRuns:        liveRuns,
+		MinAge:          opts.MinAge,
+		DryRun:          opts.DryRun,
+		Purge:           opts.Purge,
+		IncludeUntagged: opts.IncludeUntagged,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := p.printGCReport(report, opts.Format); err != nil {
+		return err
+	}
+	if failed := report.Failed(); failed > 0 {
+		return errors.Errorf("%d of %d orphaned secrets could not be deleted", failed, len(report.Orphaned))
+	}
+	return nil
+}
+
+// readLiveRuns returns the run IDs from the flags and the live runs file.
+func (p *Plugin) readLiveRuns(opts KeyVaultGCOptions) ([]string, error) {
+	var liveRuns []string
+	for _, id := range opts.RunIDs {
+		ids := keyvault.FindRunIDs(id)
+		if len(ids) != 1 || !strings.EqualFold(ids[0], id) {
+			return nil, errors.Errorf("invalid --run-id %s, run IDs are ULIDs such as 01HQ3Z8J6V6Y0W9N4GZ5D2T7KA", id)
+		}
+		liveRuns = append(liveRuns, ids[0])
+	}
+	if opts.LiveRunsFile == "" {
+		return liveRuns, nil
+	}
+
+	var b []byte
+	var err error
+	if opts.LiveRunsFile == "-" {
+		b, err = io.ReadAll(p.In)
+	} else {
+		b, err = os.ReadFile(opts.LiveRunsFile)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the live runs from %s", opts.LiveRunsFile)
+	}
+
+	found := keyvault.FindRunIDs(string(b))
+	if len(found) == 0 {
+		return nil, errors.Errorf("no run IDs were found in %s", opts.LiveRunsFile)
+	}
+	return append(liveRuns, found...), nil
+}
+
+func (p *Plugin) printGCReport(report keyvault.GCReport, format printer.Format) error {
+	if format == printer.FormatJson {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the report as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	if len(report.Orphaned) > 0 {
+		w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tRUN\tUPDATED\tSTATUS")
+		for _, secret := range report.Orphaned {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", secret.Name, secret.RunID, formatTime(secret.Updated), secret.Status)
+			if secret.Error != "" {
+				fmt.Fprintf(w, "\t\t\terror: %s\n", secret.Error)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	verb := "Deleted"
+	if report.DryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(p.Out, "%s %d orphaned secrets from %s. Kept %d secrets of live runs, and %d secrets newer than the minimum age.\n",
+		verb, len(report.Orphaned)-report.Failed(), report.Vault, report.Live, report.Recent)
+	if report.Untagged > 0 {
+		fmt.Fprintf(p.Out, "Kept %d secrets that are named like run secrets, but don't have the porter-key tag, use --include-untagged to delete them too.\n", report.Untagged)
+	}
+	return nil
+}
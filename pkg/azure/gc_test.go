@@ -0,0 +1,47 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyVaultGCOptions_Validate(t *testing.T) {
+	opts := KeyVaultGCOptions{}
+	require.EqualError(t, opts.Validate(), "the live runs must be specified with --live-runs or --run-id")
+
+	opts = KeyVaultGCOptions{RunIDs: []string{"01HQ3Z8J6V6Y0W9N4GZ5D2T7KA"}, MinAge: DefaultGCMinAge}
+	require.NoError(t, opts.Validate())
+}
+
+func TestPlugin_ReadLiveRuns(t *testing.T) {
+	const export = `[
+  {"id": "01HQ3Z8J6V6Y0W9N4GZ5D2T7KA", "installation": "mysql", "bundleReference": "ghcr.io/getporter/mysql:v0.1.0"},
+  {"id": "01HQ3Z8J6V6Y0W9N4GZ5D2T7KB", "installation": "wordpress"}
+]`
+
+	t.Run("export", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		opts := KeyVaultGCOptions{LiveRunsFile: writeConfigFile(t, "runs.json", export), RunIDs: []string{"01hq3z8j6v6y0w9n4gz5d2t7kc"}}
+
+		runs, err := p.readLiveRuns(opts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"01HQ3Z8J6V6Y0W9N4GZ5D2T7KC", "01HQ3Z8J6V6Y0W9N4GZ5D2T7KA", "01HQ3Z8J6V6Y0W9N4GZ5D2T7KB"}, runs)
+	})
+
+	t.Run("empty export", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		path := writeConfigFile(t, "runs.json", "[]")
+
+		_, err := p.readLiveRuns(KeyVaultGCOptions{LiveRunsFile: path})
+		require.EqualError(t, err, "no run IDs were found in "+path)
+	})
+
+	t.Run("invalid run ID", func(t *testing.T) {
+		p := NewTestPlugin(t)
+
+		_, err := p.readLiveRuns(KeyVaultGCOptions{RunIDs: []string{"mysql"}})
+		require.ErrorContains(t, err, "invalid --run-id mysql")
+	})
+}
@@ -0,0 +1,290 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+)
+
+// KeyVaultGetOptions are the arguments of the keyvault get command.
+type KeyVaultGetOptions struct {
+	ConfigOptions
+
+	// Key is the secret key, as it is used by Porter.
+	Key string
+}
+
+func (o *KeyVaultGetOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one positional argument, KEY, is expected")
+	}
+	o.Key = args[0]
+	return nil
+}
+
+// KeyVaultSetOptions are the arguments of the keyvault set command.
+type KeyVaultSetOptions struct {
+	ConfigOptions
+
+	// Key is the secret key, as it is used by Porter.
+	Key string
+
+	// Value of the secret. When it isn't set, the value is read from stdin.
+	Value    string
+	hasValue bool
+
+	// AllowEmpty saves an empty value, which is otherwise rejected because
+	// it is usually a mistake, such as piping an empty file.
+	AllowEmpty bool
+
+	// StdinIsTerminal is set when stdin is a terminal, so the value can't be
+	// piped to it.
+	StdinIsTerminal bool
+}
+
+func (o *KeyVaultSetOptions) Validate(args []string) error {
+	switch len(args) {
+	case 1:
+		o.Key = args[0]
+	case 2:
+		o.Key = args[0]
+		o.Value = args[1]
+		o.hasValue = true
+	default:
+		return errors.New("the positional arguments KEY and optionally VALUE are expected")
+	}
+
+	if !o.hasValue && o.StdinIsTerminal {
+		return errors.New("no VALUE was specified, and the value can't be read from stdin because it is a terminal, pipe the value to stdin or specify VALUE")
+	}
+	return nil
+}
+
+// KeyVaultListOptions are the arguments of the keyvault list command.
+type KeyVaultListOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// Prefix selects the secrets whose names start with the prefix.
+	Prefix string
+}
+
+func (o *KeyVaultListOptions) Validate() error {
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultNameOptions are the arguments of the keyvault name command.
+type KeyVaultNameOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// Keys are the secret keys, as they are used by Porter.
+	Keys []string
+}
+
+func (o *KeyVaultNameOptions) Validate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one positional argument, KEY, is expected")
+	}
+	o.Keys = args
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultGet prints the value that the plugin resolves for the secret key.
+func (p *Plugin) KeyVaultGet(ctx context.Context, opts KeyVaultGetOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	value, err := store.Resolve(ctx, keyvault.SecretKeyName, opts.Key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(p.Out, value)
+	return nil
+}
+
+// KeyVaultSet saves the secret key in the same way as the plugin.
+func (p *Plugin) KeyVaultSet(ctx context.Context, opts KeyVaultSetOptions) error {
+	value := opts.Value
+	if !opts.hasValue {
+		b, err := io.ReadAll(p.In)
+		if err != nil {
+			return errors.Wrap(err, "could not read the secret value from stdin")
+		}
+		// Drop the newline added by echo or a here-doc
+		value = strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r")
+	}
+	if value == "" && !opts.AllowEmpty {
+		return errors.New("the secret value is empty, use --allow-empty to save an empty value")
+	}
+
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	return store.Create(ctx, keyvault.SecretKeyName, opts.Key, value)
+}
+
+// KeyVaultList prints the secrets in the configured vault, without their values.
+func (p *Plugin) KeyVaultList(ctx context.Context, opts KeyVaultListOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := store.List(ctx, opts.Prefix)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == printer.FormatJson {
+		if secrets == nil {
+			secrets = []keyvault.SecretInfo{}
+		}
+		b, err := json.MarshalIndent(secrets, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the secrets as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tENABLED\tUPDATED\tEXPIRES\tTAGS")
+	for _, secret := range secrets {
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\n", secret.Name, secret.Enabled,
+			formatTime(secret.Updated), formatTime(secret.Expires), formatTags(secret.Tags))
+	}
+	return w.Flush()
+}
+
+// KeyVaultName prints the secret names that the plugin uses for the secret
+// keys, and warns when keys use the same secret.
+func (p *Plugin) KeyVaultName(ctx context.Context, opts KeyVaultNameOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	names := make([]keyvault.SecretName, 0, len(opts.Keys))
+	for _, key := range opts.Keys {
+		names = append(names, store.DescribeName(ctx, key))
+	}
+	collisions := keyvault.FindNameCollisions(names)
+
+	if opts.Format == printer.FormatJson {
+		if collisions == nil {
+			collisions = []keyvault.NameCollision{}
+		}
+		b, err := json.MarshalIndent(struct {
+			Names      []keyvault.SecretName    `json:"names"`
+			Collisions []keyvault.NameCollision `json:"collisions"`
+		}{names, collisions}, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the secret names as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSECRET\tVAULT\tPREFIX\tNOTES")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name.Key, strings.Join(name.Names, ", "),
+			name.Vault, name.Prefix, strings.Join(nameNotes(name), "; "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, collision := range collisions {
+		fmt.Fprintf(p.Out, "warning: the keys %s all use the secret %s\n", strings.Join(collision.Keys, ", "), collision.Name)
+	}
+	return nil
+}
+
+// nameNotes explains the parts of the secret name that aren't in the table.
+func nameNotes(name keyvault.SecretName) []string {
+	if name.Error != "" {
+		return []string{name.Error}
+	}
+
+	var notes []string
+	if name.ID != nil {
+		id := fmt.Sprintf("secret ID %s in %s", name.ID.Name, name.ID.Vault)
+		if name.ID.Version != "" {
+			id += " version " + name.ID.Version
+		}
+		notes = append(notes, id)
+	}
+	if len(name.Names) == 0 || name.Create != name.Names[0] {
+		notes = append(notes, "created as "+name.Create)
+	}
+	if name.Hashed {
+		notes = append(notes, "shortened with a hash")
+	}
+	if name.HasDefault {
+		notes = append(notes, "has a default")
+	} else if name.Optional {
+		notes = append(notes, "optional")
+	}
+	return notes
+}
+
+// newKeyVaultStore loads the plugin configuration and creates the same store
+// that the plugin uses.
+func (p *Plugin) newKeyVaultStore(opts ConfigOptions) (*keyvault.Store, error) {
+	if err := p.LoadConfigFrom(opts); err != nil {
+		return nil, err
+	}
+	return p.newStore(), nil
+}
+
+// newStore returns a store for the configured vault, that logs to stderr.
+func (p *Plugin) newStore() *keyvault.Store {
+	logger := commandLogger(p.Config.Logging).NewLogger("azure", p.Err)
+	p.Config.Logging.ConfigureAzureSDK(logger.Named("sdk"))
+	return keyvault.NewStore(p.Config, logger)
+}
+
+// commandLogger defaults the logging of commands run by a person to warnings
+// in text, instead of the debug logs in json that are sent to Porter.
+func commandLogger(cfg azureconfig.LoggingConfig) azureconfig.LoggingConfig {
+	if cfg.Level == "" {
+		cfg.Level = hclog.Warn.String()
+	}
+	if cfg.Format == "" {
+		cfg.Format = azureconfig.LogFormatText
+	}
+	return cfg
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,157 @@
+package keyvault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	archiveFormat  = "porter-azure-keyvault-export"
+	archiveVersion = 1
+	archiveCipher  = "aes-256-gcm"
+	archiveKDF     = "scrypt"
+
+	// The scrypt parameters recommended for interactive use. The maximums
+	// limit the memory and time used to decrypt an archive from an untrusted
+	// source. scrypt uses 128 * N * r bytes of memory, and p times the work.
+	scryptN         = 1 << 15
+	scryptR         = 8
+	scryptP         = 1
+	scryptMaxN      = 1 << 20
+	scryptMaxR      = 32
+	scryptMaxP      = 16
+	scryptMaxMemory = 256 << 20
+)
+
+// ErrDecryptFailed is returned when an export archive can't be decrypted,
+// because the passphrase is wrong or the archive was modified.
+var ErrDecryptFailed = errors.New("the archive could not be decrypted, check the passphrase")
+
+// archiveHeader describes how an export archive is encrypted. It is
+// authenticated along with the encrypted export, so that it can't be changed.
+type archiveHeader struct {
+	Format  string           `json:"format"`
+	Version int              `json:"version"`
+	Cipher  string           `json:"cipher"`
+	KDF     archiveKDFParams `json:"kdf"`
+}
+
+type archiveKDFParams struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// valid returns true when the scrypt parameters are within the limits that
+// an archive may use.
+func (p archiveKDFParams) valid() bool {
+	if p.N <= 1 || p.N > scryptMaxN || p.R <= 0 || p.R > scryptMaxR || p.P <= 0 || p.P > scryptMaxP {
+		return false
+	}
+	return 128*p.N*p.R <= scryptMaxMemory
+}
+
+// archive is the file written by EncryptExport.
+type archive struct {
+	archiveHeader
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptExport encrypts the export with a key derived from the passphrase,
+// and returns the archive.
+func EncryptExport(export Export, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("a passphrase is required to encrypt the export")
+	}
+
+	plaintext, err := json.Marshal(export)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the export: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not generate a salt: %w", err)
+	}
+	a := archive{archiveHeader: archiveHeader{
+		Format:  archiveFormat,
+		Version: archiveVersion,
+		Cipher:  archiveCipher,
+		KDF:     archiveKDFParams{Name: archiveKDF, Salt: salt, N: scryptN, R: scryptR, P: scryptP},
+	}}
+
+	aead, additionalData, err := a.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	a.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(a.Nonce); err != nil {
+		return nil, fmt.Errorf("could not generate a nonce: %w", err)
+	}
+	a.Ciphertext = aead.Seal(nil, a.Nonce, plaintext, additionalData)
+
+	return json.MarshalIndent(a, "", "  ")
+}
+
+// DecryptExport decrypts an archive written by EncryptExport.
+func DecryptExport(data []byte, passphrase []byte) (Export, error) {
+	var a archive
+	if err := json.Unmarshal(data, &a); err != nil || a.Format != archiveFormat {
+		return Export{}, errors.New("the file is not an export archive")
+	}
+	if a.Version != archiveVersion || a.Cipher != archiveCipher || a.KDF.Name != archiveKDF {
+		return Export{}, fmt.Errorf("unsupported export archive version %d, encrypted with %s and %s", a.Version, a.Cipher, a.KDF.Name)
+	}
+	if !a.KDF.valid() {
+		return Export{}, errors.New("the export archive has invalid scrypt parameters")
+	}
+
+	aead, additionalData, err := a.aead(passphrase)
+	if err != nil {
+		return Export{}, err
+	}
+	if len(a.Nonce) != aead.NonceSize() {
+		return Export{}, ErrDecryptFailed
+	}
+	plaintext, err := aead.Open(nil, a.Nonce, a.Ciphertext, additionalData)
+	if err != nil {
+		return Export{}, ErrDecryptFailed
+	}
+
+	var export Export
+	if err := json.Unmarshal(plaintext, &export); err != nil {
+		return Export{}, fmt.Errorf("could not decode the export: %w", err)
+	}
+	return export, nil
+}
+
+// aead returns the cipher for the archive, and the additional data that
+// authenticates its header.
+func (a archive) aead(passphrase []byte) (cipher.AEAD, []byte, error) {
+	key, err := scrypt.Key(passphrase, a.KDF.Salt, a.KDF.N, a.KDF.R, a.KDF.P, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not derive the key from the passphrase: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	additionalData, err := json.Marshal(a.archiveHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, additionalData, nil
+}
@@ -0,0 +1,74 @@
+package keyvault
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptExport(t *testing.T) {
+	export := Export{
+		Vault:    "https://myvault.vault.azure.net",
+		Exported: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Secrets:  []ExportedSecret{{Name: "MY-PARAM", Key: "MY_PARAM", Value: "top secret", Tags: map[string]string{keyTag: "MY_PARAM"}}},
+	}
+	passphrase := []byte("correct horse battery staple")
+
+	data, err := EncryptExport(export, passphrase)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "top secret")
+	assert.NotContains(t, string(data), "MY_PARAM")
+
+	t.Run("decrypt", func(t *testing.T) {
+		got, err := DecryptExport(data, passphrase)
+		require.NoError(t, err)
+		assert.Equal(t, export, got)
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, err := DecryptExport(data, []byte("wrong passphrase"))
+		require.ErrorIs(t, err, ErrDecryptFailed)
+	})
+
+	t.Run("modified header", func(t *testing.T) {
+		var a archive
+		require.NoError(t, json.Unmarshal(data, &a))
+		a.KDF.N = scryptN * 2
+		modified, err := json.Marshal(a)
+		require.NoError(t, err)
+
+		_, err = DecryptExport(modified, passphrase)
+		require.ErrorIs(t, err, ErrDecryptFailed)
+	})
+
+	t.Run("scrypt parameters too large", func(t *testing.T) {
+		for _, kdf := range []archiveKDFParams{
+			{N: scryptN, R: scryptMaxR + 1, P: scryptP},
+			{N: scryptN, R: scryptR, P: scryptMaxP + 1},
+			{N: scryptMaxN, R: scryptMaxR, P: scryptP},
+			{N: scryptMaxN * 2, R: 1, P: 1},
+		} {
+			var a archive
+			require.NoError(t, json.Unmarshal(data, &a))
+			a.KDF.N, a.KDF.R, a.KDF.P = kdf.N, kdf.R, kdf.P
+			modified, err := json.Marshal(a)
+			require.NoError(t, err)
+
+			_, err = DecryptExport(modified, passphrase)
+			require.EqualError(t, err, "the export archive has invalid scrypt parameters", "N=%d r=%d p=%d", kdf.N, kdf.R, kdf.P)
+		}
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		_, err := DecryptExport([]byte(`{"secrets": []}`), passphrase)
+		require.EqualError(t, err, "the file is not an export archive")
+	})
+
+	t.Run("no passphrase", func(t *testing.T) {
+		_, err := EncryptExport(export, nil)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,250 @@
+package keyvault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// testEpoch is when the first version in a testClient was created.
+var testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testClient is an in-memory vault that implements secretsClient.
+type testClient struct {
+	vaultURL string
+	secrets  map[string]string
+	tags     map[string]map[string]*string
+	attrs    map[string]*azsecrets.SecretAttributes
+	// versions are the versions created with SetSecret, oldest first
+	versions map[string][]azsecrets.Secret
+	// deleted are the soft-deleted secrets
+	deleted map[string]string
+	// errors are returned instead of the secret when it is requested
+	errors map[string]error
+	// listErr is returned when the secrets are listed
+	listErr error
+	gets    []string
+	sets    []string
+	purges  []string
+	// updates are the versions whose properties were updated, as name/version
+	updates []string
+
+	mu sync.Mutex
+}
+
+func newTestClient() *testClient {
+	return &testClient{
+		vaultURL: "https://test.vault.azure.net",
+		secrets:  make(map[string]string),
+		versions: make(map[string][]azsecrets.Secret),
+		tags:     make(map[string]map[string]*string),
+		attrs:    make(map[string]*azsecrets.SecretAttributes),
+		deleted:  make(map[string]string),
+		errors:   make(map[string]error),
+	}
+}
+
+func (c *testClient) GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gets = append(c.gets, name)
+	if err, ok := c.errors[name]; ok {
+		return azsecrets.GetSecretResponse{}, err
+	}
+
+	if version != "" {
+		for _, v := range c.versions[name] {
+			if v.ID.Version() == version {
+				return azsecrets.GetSecretResponse{Secret: v}, nil
+			}
+		}
+		return azsecrets.GetSecretResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+	}
+
+	value, ok := c.secrets[name]
+	if !ok {
+		return azsecrets.GetSecretResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+	}
+	result := azsecrets.Secret{Value: &value, Tags: c.tags[name], Attributes: c.attrs[name]}
+	if versions := c.versions[name]; len(versions) > 0 {
+		current := versions[len(versions)-1]
+		result.ID = current.ID
+		result.ContentType = current.ContentType
+	}
+	return azsecrets.GetSecretResponse{Secret: result}, nil
+}
+
+func (c *testClient) SetSecret(ctx context.Context, name string, parameters azsecrets.SetSecretParameters, options *azsecrets.SetSecretOptions) (azsecrets.SetSecretResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sets = append(c.sets, name)
+	if err, ok := c.errors[name]; ok {
+		return azsecrets.SetSecretResponse{}, err
+	}
+
+	c.secrets[name] = *parameters.Value
+	c.tags[name] = parameters.Tags
+	c.attrs[name] = parameters.SecretAttributes
+
+	// Every version is created a second after the previous one, so that they
+	// are sorted by their creation date
+	id := azsecrets.ID(fmt.Sprintf("%s/secrets/%s/v%d", c.vaultURL, name, len(c.versions[name])+1))
+	created := testEpoch.Add(time.Duration(len(c.sets)) * time.Second)
+	attrs := azsecrets.SecretAttributes{Created: &created}
+	if parameters.SecretAttributes != nil {
+		attrs.Expires = parameters.SecretAttributes.Expires
+		attrs.NotBefore = parameters.SecretAttributes.NotBefore
+		attrs.Enabled = parameters.SecretAttributes.Enabled
+	}
+	c.versions[name] = append(c.versions[name], azsecrets.Secret{
+		ID: &id, Value: parameters.Value, ContentType: parameters.ContentType, Tags: parameters.Tags, Attributes: &attrs,
+	})
+	return azsecrets.SetSecretResponse{Secret: azsecrets.Secret{ID: &id, Value: parameters.Value, Tags: parameters.Tags}}, nil
+}
+
+// NewListSecretPropertiesPager returns every secret in a single page, sorted by name.
+func (c *testClient) NewListSecretPropertiesPager(options *azsecrets.ListSecretPropertiesOptions) *runtime.Pager[azsecrets.ListSecretPropertiesResponse] {
+	return runtime.NewPager(runtime.PagingHandler[azsecrets.ListSecretPropertiesResponse]{
+		More: func(page azsecrets.ListSecretPropertiesResponse) bool {
+			return false
+		},
+		Fetcher: func(ctx context.Context, page *azsecrets.ListSecretPropertiesResponse) (azsecrets.ListSecretPropertiesResponse, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if c.listErr != nil {
+				return azsecrets.ListSecretPropertiesResponse{}, c.listErr
+			}
+
+			names := make([]string, 0, len(c.secrets))
+			for name := range c.secrets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			var result azsecrets.ListSecretPropertiesResponse
+			for _, name := range names {
+				id := azsecrets.ID(c.vaultURL + "/secrets/" + name)
+				result.Value = append(result.Value, &azsecrets.SecretProperties{ID: &id, Tags: c.tags[name], Attributes: c.attrs[name]})
+			}
+			return result, nil
+		},
+	})
+}
+
+// NewListSecretPropertiesVersionsPager returns the versions created with
+// SetSecret in a single page, newest first.
+func (c *testClient) NewListSecretPropertiesVersionsPager(name string, options *azsecrets.ListSecretPropertiesVersionsOptions) *runtime.Pager[azsecrets.ListSecretPropertiesVersionsResponse] {
+	return runtime.NewPager(runtime.PagingHandler[azsecrets.ListSecretPropertiesVersionsResponse]{
+		More: func(page azsecrets.ListSecretPropertiesVersionsResponse) bool {
+			return false
+		},
+		Fetcher: func(ctx context.Context, page *azsecrets.ListSecretPropertiesVersionsResponse) (azsecrets.ListSecretPropertiesVersionsResponse, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if _, ok := c.secrets[name]; !ok {
+				return azsecrets.ListSecretPropertiesVersionsResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+			}
+
+			var result azsecrets.ListSecretPropertiesVersionsResponse
+			versions := c.versions[name]
+			for i := len(versions) - 1; i >= 0; i-- {
+				v := versions[i]
+				result.Value = append(result.Value, &azsecrets.SecretProperties{ID: v.ID, ContentType: v.ContentType, Tags: v.Tags, Attributes: v.Attributes})
+			}
+			return result, nil
+		},
+	})
+}
+
+func (c *testClient) DeleteSecret(ctx context.Context, name string, options *azsecrets.DeleteSecretOptions) (azsecrets.DeleteSecretResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err, ok := c.errors[name]; ok {
+		return azsecrets.DeleteSecretResponse{}, err
+	}
+	value, ok := c.secrets[name]
+	if !ok {
+		return azsecrets.DeleteSecretResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+	}
+
+	c.deleted[name] = value
+	delete(c.secrets, name)
+	delete(c.tags, name)
+	delete(c.attrs, name)
+	return azsecrets.DeleteSecretResponse{}, nil
+}
+
+func (c *testClient) GetDeletedSecret(ctx context.Context, name string, options *azsecrets.GetDeletedSecretOptions) (azsecrets.GetDeletedSecretResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.deleted[name]
+	if !ok {
+		return azsecrets.GetDeletedSecretResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+	}
+	return azsecrets.GetDeletedSecretResponse{DeletedSecret: azsecrets.DeletedSecret{Value: &value}}, nil
+}
+
+func (c *testClient) PurgeDeletedSecret(ctx context.Context, name string, options *azsecrets.PurgeDeletedSecretOptions) (azsecrets.PurgeDeletedSecretResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purges = append(c.purges, name)
+	if _, ok := c.deleted[name]; !ok {
+		return azsecrets.PurgeDeletedSecretResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+	}
+	delete(c.deleted, name)
+	return azsecrets.PurgeDeletedSecretResponse{}, nil
+}
+
+func (c *testClient) UpdateSecretProperties(ctx context.Context, name string, version string, parameters azsecrets.UpdateSecretPropertiesParameters, options *azsecrets.UpdateSecretPropertiesOptions) (azsecrets.UpdateSecretPropertiesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.updates = append(c.updates, name+"/"+version)
+	if err, ok := c.errors[name+"/"+version]; ok {
+		return azsecrets.UpdateSecretPropertiesResponse{}, err
+	}
+	for _, v := range c.versions[name] {
+		if v.ID.Version() == version {
+			if parameters.SecretAttributes != nil && parameters.SecretAttributes.Enabled != nil {
+				v.Attributes.Enabled = parameters.SecretAttributes.Enabled
+			}
+			if parameters.SecretAttributes != nil && parameters.SecretAttributes.Expires != nil {
+				v.Attributes.Expires = parameters.SecretAttributes.Expires
+				if current := c.versions[name][len(c.versions[name])-1]; current.ID.Version() == version {
+					c.attrs[name] = v.Attributes
+				}
+			}
+			return azsecrets.UpdateSecretPropertiesResponse{}, nil
+		}
+	}
+	return azsecrets.UpdateSecretPropertiesResponse{}, newResponseError(http.StatusNotFound, "SecretNotFound")
+}
+
+func newResponseError(statusCode int, errorCode string) error {
+	return &azcore.ResponseError{StatusCode: statusCode, ErrorCode: errorCode}
+}
+
+// withTestClients connects the store to in-memory vaults, keyed by vault url.
+func withTestClients(s *Store, clients map[string]*testClient) {
+	for vaultURL, client := range clients {
+		client.vaultURL = vaultURL
+		s.clients[vaultURL] = client
+	}
+	if client, ok := clients[s.vaultUrl]; ok {
+		s.client = client
+	}
+}
@@ -1,16 +1,22 @@
 package keyvault
 
 import (
+	"bytes"
+	"context"
 	"os"
+	"os/exec"
+	"runtime"
 	"strings"
 
 	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
 )
 
 // GetCredentials gets an authorizer for Azure
-func GetCredentials(cfg azureconfig.Config, l hclog.Logger) (*azidentity.DefaultAzureCredential, error) {
+func GetCredentials(cfg azureconfig.Config, l hclog.Logger) (azcore.TokenCredential, error) {
 
 	azureAuthEnvVarNames := []string{
 		"AZURE_TENANT_ID",
@@ -31,10 +37,141 @@ func GetCredentials(cfg azureconfig.Config, l hclog.Logger) (*azidentity.Default
 		}
 	}
 
-	creds, err := azidentity.NewDefaultAzureCredential(nil)
+	clientOpts, err := newClientOptions(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	l.Debug("creating azure credential", "credential", cfg.GetCredential())
+	creds, err := newCredential(cfg, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TokenCache.Enabled {
+		return asTokenCredential(newTokenCache(cfg, creds, credentialPartition(cfg), l))
+	}
 	return creds, nil
 }
+
+// newCredential creates the configured type of credential.
+func newCredential(cfg azureconfig.Config, clientOpts azcore.ClientOptions) (azcore.TokenCredential, error) {
+	switch cfg.GetCredential() {
+	case azureconfig.CredentialEnvironment:
+		return asTokenCredential(azidentity.NewEnvironmentCredential(&azidentity.EnvironmentCredentialOptions{
+			ClientOptions: clientOpts,
+		}))
+	case azureconfig.CredentialWorkloadIdentity:
+		return asTokenCredential(azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions:              clientOpts,
+			TenantID:                   cfg.TenantID,
+			ClientID:                   cfg.ClientID,
+			TokenFilePath:              cfg.FederatedTokenFile,
+			AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		}))
+	case azureconfig.CredentialClientAssertion:
+		return asTokenCredential(azidentity.NewClientAssertionCredential(cfg.TenantID, cfg.ClientID, federatedTokenSource(cfg),
+			&azidentity.ClientAssertionCredentialOptions{
+				ClientOptions:              clientOpts,
+				AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+			}))
+	case azureconfig.CredentialManagedIdentity:
+		opts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOpts}
+		if cfg.ClientID != "" {
+			opts.ID = azidentity.ClientID(cfg.ClientID)
+		}
+		return asTokenCredential(azidentity.NewManagedIdentityCredential(opts))
+	case azureconfig.CredentialAzureCLI:
+		return asTokenCredential(azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{
+			TenantID:                   cfg.TenantID,
+			AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		}))
+	default:
+		return asTokenCredential(azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions:              clientOpts,
+			TenantID:                   cfg.TenantID,
+			AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		}))
+	}
+}
+
+// credentialPartition identifies the identity that a credential authenticates
+// as, so that cached tokens are only shared by credentials for the same
+// identity. Only the credentials in azureconfig.TokenCacheCredentials are
+// cached, because their identity is set by the configuration and environment.
+func credentialPartition(cfg azureconfig.Config) string {
+	return strings.Join([]string{
+		cfg.GetCredential(),
+		cfg.TenantID,
+		cfg.ClientID,
+		cfg.FederatedTokenFile,
+		cfg.FederatedTokenCommand,
+		os.Getenv("AZURE_TENANT_ID"),
+		os.Getenv("AZURE_CLIENT_ID"),
+		os.Getenv("AZURE_USERNAME"),
+		os.Getenv("AZURE_CERTIFICATE_PATH"),
+		os.Getenv("AZURE_CLIENT_CERTIFICATE_PATH"),
+		os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+	}, "\n")
+}
+
+// asTokenCredential avoids returning a nil credential pointer wrapped in a
+// non-nil interface when a credential could not be created.
+func asTokenCredential[T azcore.TokenCredential](cred T, err error) (azcore.TokenCredential, error) {
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// federatedTokenSource returns a function that gets the federated token used
+// by the client-assertion credential. The token is read again every time that
+// the credential needs a new access token, because federated tokens are short
+// lived.
+func federatedTokenSource(cfg azureconfig.Config) func(context.Context) (string, error) {
+	if cfg.FederatedTokenFile != "" {
+		return func(ctx context.Context) (string, error) {
+			token, err := os.ReadFile(cfg.FederatedTokenFile)
+			if err != nil {
+				return "", errors.Wrapf(err, "could not read the federated token file %s", cfg.FederatedTokenFile)
+			}
+			return strings.TrimSpace(string(token)), nil
+		}
+	}
+
+	return func(ctx context.Context) (string, error) {
+		return runFederatedTokenCommand(ctx, cfg.FederatedTokenCommand)
+	}
+}
+
+// runFederatedTokenCommand runs the command with the system shell and returns
+// the token that it printed.
+func runFederatedTokenCommand(ctx context.Context, command string) (string, error) {
+	stdout, stderr, err := runShellCommand(ctx, command)
+	if err != nil {
+		return "", errors.Wrapf(err, "the federated-token-command failed: %s", strings.TrimSpace(stderr))
+	}
+
+	token := strings.TrimSpace(stdout)
+	if token == "" {
+		return "", errors.New("the federated-token-command did not print a token")
+	}
+	return token, nil
+}
+
+// runShellCommand runs the command with the system shell, and returns what it
+// printed to stdout and stderr.
+func runShellCommand(ctx context.Context, command string) (string, string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
@@ -0,0 +1,96 @@
+package keyvault
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCredentials_Type(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("federated-token\n"), 0600))
+
+	logger := hclog.New(&loggerOpts)
+
+	t.Run("workload identity", func(t *testing.T) {
+		cfg := azureconfig.Config{
+			Credential:         azureconfig.CredentialWorkloadIdentity,
+			TenantID:           "mytenant",
+			ClientID:           "myclient",
+			FederatedTokenFile: tokenFile,
+		}
+		cred, err := GetCredentials(cfg, logger)
+		require.NoError(t, err)
+		assert.IsType(t, &azidentity.WorkloadIdentityCredential{}, cred)
+	})
+
+	t.Run("client assertion", func(t *testing.T) {
+		cfg := azureconfig.Config{
+			Credential:            azureconfig.CredentialClientAssertion,
+			TenantID:              "mytenant",
+			ClientID:              "myclient",
+			FederatedTokenCommand: "echo federated-token",
+		}
+		cred, err := GetCredentials(cfg, logger)
+		require.NoError(t, err)
+		assert.IsType(t, &azidentity.ClientAssertionCredential{}, cred)
+	})
+
+}
+
+func TestFederatedTokenSource(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("file", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("token1\n"), 0600))
+		getToken := federatedTokenSource(azureconfig.Config{FederatedTokenFile: tokenFile})
+
+		token, err := getToken(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "token1", token)
+
+		// The file is read every time so that rotated tokens are picked up
+		require.NoError(t, os.WriteFile(tokenFile, []byte("token2"), 0600))
+		token, err = getToken(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "token2", token)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		getToken := federatedTokenSource(azureconfig.Config{FederatedTokenFile: filepath.Join(t.TempDir(), "missing")})
+		_, err := getToken(ctx)
+		require.ErrorContains(t, err, "could not read the federated token file")
+	})
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	t.Run("command", func(t *testing.T) {
+		getToken := federatedTokenSource(azureconfig.Config{FederatedTokenCommand: "echo my-oidc-token"})
+		token, err := getToken(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "my-oidc-token", token)
+	})
+
+	t.Run("failed command", func(t *testing.T) {
+		getToken := federatedTokenSource(azureconfig.Config{FederatedTokenCommand: "echo oops >&2; exit 1"})
+		_, err := getToken(ctx)
+		require.ErrorContains(t, err, "the federated-token-command failed: oops")
+	})
+
+	t.Run("command without output", func(t *testing.T) {
+		getToken := federatedTokenSource(azureconfig.Config{FederatedTokenCommand: "true"})
+		_, err := getToken(ctx)
+		require.ErrorContains(t, err, "did not print a token")
+	})
+}
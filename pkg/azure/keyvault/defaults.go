@@ -0,0 +1,87 @@
+package keyvault
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"get.porter.sh/porter/pkg/tracing"
+)
+
+// secretOptions are set after a "?" at the end of a secret key, with the same
+// syntax as a URL query, for example "name?default=value" or "name?optional".
+type secretOptions struct {
+	// optional resolves a missing secret to an empty string.
+	optional bool
+
+	// defaultValue is resolved when the secret is missing and hasDefault is
+	// set.
+	defaultValue string
+	hasDefault   bool
+}
+
+// secretOptions splits the options from the secret key, when secret-options
+// is set in the configuration. Otherwise the whole key is the secret name.
+func (s *Store) secretOptions(keyValue string) (string, secretOptions, error) {
+	if !s.config.SecretOptions {
+		return keyValue, secretOptions{}, nil
+	}
+
+	name, opts, err := parseSecretOptions(keyValue)
+	if err != nil {
+		return "", secretOptions{}, fmt.Errorf("invalid secret key %s: %w", s.names.redact(keyValue), err)
+	}
+	return name, opts, nil
+}
+
+// parseSecretOptions splits the options from the secret key. Unknown options
+// are an error, rather than part of the key, so that a typo doesn't look up
+// a different secret.
+func parseSecretOptions(keyValue string) (string, secretOptions, error) {
+	name, query, found := strings.Cut(keyValue, "?")
+	if !found {
+		return keyValue, secretOptions{}, nil
+	}
+	if name == "" {
+		return "", secretOptions{}, errors.New("the secret name is missing before the options")
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", secretOptions{}, fmt.Errorf("invalid options: %w", err)
+	}
+
+	var opts secretOptions
+	for key, value := range values {
+		switch key {
+		case "optional":
+			opts.optional = true
+		case "default":
+			opts.hasDefault = true
+			opts.defaultValue = value[len(value)-1]
+		default:
+			return "", secretOptions{}, fmt.Errorf("unknown option %q, allowed options are: optional, default", key)
+		}
+	}
+	return name, opts, nil
+}
+
+// orDefault returns the default value when the secret is optional or has a
+// default, and every error is because the secret was not found. Any other
+// error, such as a missing permission, is returned.
+func (o secretOptions) orDefault(log tracing.TraceLogger, errs ...error) (string, error) {
+	err := errors.Join(errs...)
+	if !o.optional && !o.hasDefault {
+		return "", log.Error(err)
+	}
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrSecretNotFound) {
+			return "", log.Error(err)
+		}
+	}
+
+	log.Debug("the secret was not found, using its default value")
+	return o.defaultValue, nil
+}
@@ -0,0 +1,136 @@
+package keyvault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSecretOptions(t *testing.T) {
+	testcases := []struct {
+		keyValue string
+		wantName string
+		wantOpts secretOptions
+		wantErr  string
+	}{
+		{keyValue: "name", wantName: "name"},
+		{keyValue: "name?optional", wantName: "name", wantOpts: secretOptions{optional: true}},
+		{keyValue: "name?default=", wantName: "name", wantOpts: secretOptions{hasDefault: true}},
+		{keyValue: "name?default=a%20b%26c", wantName: "name", wantOpts: secretOptions{hasDefault: true, defaultValue: "a b&c"}},
+		{keyValue: "name?unknown=1", wantErr: `unknown option "unknown", allowed options are: optional, default`},
+		{keyValue: "name?default=%zz", wantErr: `invalid options: invalid URL escape "%zz"`},
+		{keyValue: "?optional", wantErr: "the secret name is missing before the options"},
+		{keyValue: "https://myvault.vault.azure.net/secrets/name?optional", wantName: "https://myvault.vault.azure.net/secrets/name", wantOpts: secretOptions{optional: true}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.keyValue, func(t *testing.T) {
+			name, opts, err := parseSecretOptions(tc.keyValue)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantName, name)
+			assert.Equal(t, tc.wantOpts, opts)
+		})
+	}
+}
+
+func TestStore_Resolve_Defaults(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(azureconfig.Config{Vault: "myvault", SecretOptions: true}, hclog.New(&loggerOpts))
+	client := newTestClient()
+	client.secrets["toggle"] = "on"
+	client.errors["forbidden"] = newResponseError(http.StatusForbidden, "Forbidden")
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+	t.Run("existing secret", func(t *testing.T) {
+		resolved, err := store.Resolve(ctx, SecretKeyName, "toggle?default=off")
+		require.NoError(t, err)
+		assert.Equal(t, "on", resolved)
+	})
+
+	t.Run("missing secret with default", func(t *testing.T) {
+		resolved, err := store.Resolve(ctx, SecretKeyName, "missing?default=off")
+		require.NoError(t, err)
+		assert.Equal(t, "off", resolved)
+	})
+
+	t.Run("missing optional secret", func(t *testing.T) {
+		resolved, err := store.Resolve(ctx, SecretKeyName, "missing?optional")
+		require.NoError(t, err)
+		assert.Empty(t, resolved)
+	})
+
+	t.Run("missing required secret", func(t *testing.T) {
+		_, err := store.Resolve(ctx, SecretKeyName, "missing")
+		require.ErrorIs(t, err, ErrSecretNotFound)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		_, err := store.Resolve(ctx, SecretKeyName, "forbidden?default=off")
+		require.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("secret ID forbidden", func(t *testing.T) {
+		other := newTestClient()
+		other.errors["missing"] = newResponseError(http.StatusForbidden, "Forbidden")
+		withTestClients(store, map[string]*testClient{"https://othervault.vault.azure.net": other})
+
+		_, err := store.Resolve(ctx, SecretKeyName, "https://othervault.vault.azure.net/secrets/missing?optional")
+		require.ErrorIs(t, err, ErrForbidden, "the ID lookup failure should not be hidden by the default")
+	})
+}
+
+func TestStore_SecretOptions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		store := NewStore(azureconfig.Config{Vault: "myvault"}, hclog.New(&loggerOpts))
+		client := newTestClient()
+		withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "toggle?optional", "on"))
+		assert.Equal(t, []string{"toggle-optional"}, client.sets, "the options should be part of the key")
+
+		resolved, err := store.Resolve(ctx, SecretKeyName, "toggle?optional")
+		require.NoError(t, err)
+		assert.Equal(t, "on", resolved)
+
+		_, err = store.Resolve(ctx, SecretKeyName, "missing?optional")
+		require.ErrorIs(t, err, ErrSecretNotFound)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		store := NewStore(azureconfig.Config{Vault: "myvault", SecretOptions: true}, hclog.New(&loggerOpts))
+		client := newTestClient()
+		withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "toggle?default=off", "on"))
+		assert.Equal(t, []string{"toggle"}, client.sets, "the secret should be saved where it is resolved from")
+
+		resolved, err := store.Resolve(ctx, SecretKeyName, "toggle?default=off")
+		require.NoError(t, err)
+		assert.Equal(t, "on", resolved)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		store := NewStore(azureconfig.Config{Vault: "myvault", SecretOptions: true}, hclog.New(&loggerOpts))
+		client := newTestClient()
+		withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+		err := store.Create(ctx, SecretKeyName, "toggle?optinal", "on")
+		require.EqualError(t, err, `invalid secret key toggle?optinal: unknown option "optinal", allowed options are: optional, default`)
+		assert.Empty(t, client.sets)
+
+		_, err = store.Resolve(ctx, SecretKeyName, "toggle?optinal")
+		require.ErrorContains(t, err, `unknown option "optinal"`)
+		assert.Empty(t, client.gets)
+	})
+}
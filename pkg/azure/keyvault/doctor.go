@@ -0,0 +1,412 @@
+package keyvault
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// CheckStatus is the result of a doctor check.
+type CheckStatus string
+
+const (
+	// CheckPass means that the check succeeded.
+	CheckPass CheckStatus = "pass"
+
+	// CheckWarn means that the check found a problem that may not stop the
+	// plugin from working.
+	CheckWarn CheckStatus = "warn"
+
+	// CheckFail means that the check found a problem that stops the plugin
+	// from working.
+	CheckFail CheckStatus = "fail"
+
+	// CheckSkip means that the check was not run.
+	CheckSkip CheckStatus = "skip"
+)
+
+// DefaultCanarySecret is the secret written by the doctor to check the Set
+// secret permission, when writes are enabled.
+const DefaultCanarySecret = "porter-doctor-canary"
+
+// doctorTimeout limits how long the network checks, and each credential
+// probe, may take.
+const doctorTimeout = 15 * time.Second
+
+// Check is the result of one of the doctor's checks.
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+	Hint    string      `json:"hint,omitempty"`
+}
+
+// DoctorOptions configures the doctor's checks.
+type DoctorOptions struct {
+	// CanarySecret is the secret written to check the Set secret permission.
+	// Defaults to DefaultCanarySecret.
+	CanarySecret string
+
+	// Write checks the Set secret permission by writing the canary secret.
+	// Off by default, so that the vault isn't changed.
+	Write bool
+
+	// IdentifyCredential tries each credential of the default chain on its
+	// own to report which one authenticated, when the credential is default.
+	IdentifyCredential bool
+}
+
+// defaultCredentialChain is the credentials that the plugin can be configured
+// with, in the order that the default credential tries them.
+var defaultCredentialChain = []string{
+	azureconfig.CredentialEnvironment,
+	azureconfig.CredentialWorkloadIdentity,
+	azureconfig.CredentialManagedIdentity,
+	azureconfig.CredentialAzureCLI,
+}
+
+// Doctor checks that the plugin can authenticate, reach the configured vault,
+// and has permission to get, list and set secrets in it.
+func (s *Store) Doctor(ctx context.Context, opts DoctorOptions) []Check {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	if s.config.Vault == "" && s.config.VaultUrl == "" {
+		return []Check{{
+			Name:    "vault",
+			Status:  CheckFail,
+			Message: "no vault is configured",
+			Hint:    "set vault or vault-url in the plugin configuration",
+		}}
+	}
+
+	checks := []Check{s.checkCredential(ctx, opts)}
+	dns := s.checkDNS(ctx)
+	tlsCheck := s.checkTLS(ctx, dns)
+	checks = append(checks, dns, tlsCheck)
+
+	if checks[0].Status == CheckFail || tlsCheck.Status == CheckFail {
+		for _, name := range []string{"set", "get", "list"} {
+			checks = append(checks, Check{Name: name, Status: CheckSkip, Message: "skipped because the vault can't be used"})
+		}
+		return checks
+	}
+
+	if err := s.Connect(ctx); err != nil {
+		checks = append(checks, Check{Name: "connect", Status: CheckFail, Message: err.Error()})
+		return checks
+	}
+
+	canary := opts.CanarySecret
+	if canary == "" {
+		canary = DefaultCanarySecret
+	}
+	set, canaryValue := s.checkSet(ctx, canary, opts.Write)
+	checks = append(checks, set, s.checkGet(ctx, canary, canaryValue), s.checkList(ctx))
+	return checks
+}
+
+// checkCredential gets a token for the vault, and reports the principal that
+// it was issued to.
+func (s *Store) checkCredential(ctx context.Context, opts DoctorOptions) Check {
+	check := Check{Name: "credential"}
+
+	s.clientsMu.Lock()
+	cfg, creds, err := s.credentialFor(s.vaultUrl)
+	s.clientsMu.Unlock()
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("could not create the %s credential: %s", cfg.GetCredential(), err)
+		check.Hint = authFailedHint
+		return check
+	}
+
+	tokenOpts := policy.TokenRequestOptions{Scopes: []string{vaultScope(s.vaultUrl)}, TenantID: cfg.TenantID}
+	tokenCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	token, err := creds.GetToken(tokenCtx, tokenOpts)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("the %s credential could not authenticate: %s", cfg.GetCredential(), err)
+		check.Hint = authFailedHint
+		return check
+	}
+
+	credential := cfg.GetCredential()
+	if credential == azureconfig.CredentialDefault && opts.IdentifyCredential {
+		if identified := identifyCredential(ctx, cfg, tokenOpts); identified != "" {
+			credential = fmt.Sprintf("%s (%s)", credential, identified)
+		}
+	}
+
+	check.Status = CheckPass
+	check.Message = fmt.Sprintf("authenticated with the %s credential as %s", credential, describePrincipal(token.Token))
+	return check
+}
+
+// identifyCredential returns the first type of credential in the default
+// chain that authenticates, which is the one used by the default credential.
+func identifyCredential(ctx context.Context, cfg azureconfig.Config, tokenOpts policy.TokenRequestOptions) string {
+	clientOpts, err := newClientOptions(cfg)
+	if err != nil {
+		return ""
+	}
+
+	for _, credential := range defaultCredentialChain {
+		probeCfg := cfg
+		probeCfg.Credential = credential
+		creds, err := newCredential(probeCfg, clientOpts)
+		if err != nil {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+		_, err = creds.GetToken(probeCtx, tokenOpts)
+		cancel()
+		if err == nil {
+			return credential
+		}
+	}
+	return ""
+}
+
+// vaultScope returns the token scope for the vault's cloud, for example
+// https://vault.azure.net/.default.
+func vaultScope(vaultURL string) string {
+	host := vaultHost(vaultURL)
+	if _, domain, ok := strings.Cut(host, "."); ok {
+		return "https://" + domain + "/.default"
+	}
+	return "https://vault.azure.net/.default"
+}
+
+// describePrincipal describes who the access token was issued to, from its
+// claims. The token's signature isn't checked, the claims are only reported.
+func describePrincipal(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "an unknown principal"
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "an unknown principal"
+	}
+
+	var claims struct {
+		ObjectID string `json:"oid"`
+		TenantID string `json:"tid"`
+		AppID    string `json:"appid"`
+		UPN      string `json:"upn"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ObjectID == "" {
+		return "an unknown principal"
+	}
+
+	details := []string{"tenant " + claims.TenantID}
+	if claims.UPN != "" {
+		details = append(details, "user "+claims.UPN)
+	}
+	if claims.AppID != "" {
+		details = append(details, "application "+claims.AppID)
+	}
+	return fmt.Sprintf("object ID %s (%s)", claims.ObjectID, strings.Join(details, ", "))
+}
+
+// checkDNS resolves the vault's host name. When a proxy is configured, the
+// proxy resolves the name, so a failure is only a warning.
+func (s *Store) checkDNS(ctx context.Context) Check {
+	check := Check{Name: "dns"}
+	host := vaultHost(s.vaultUrl)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("could not resolve %s: %s", host, err)
+		check.Hint = "check the vault or vault-url setting, and the DNS settings of this machine, including private DNS zones for private endpoints"
+		if s.config.Transport.ProxyURL != "" {
+			check.Status = CheckWarn
+			check.Hint = "the configured proxy resolves the vault's name, so this may not be a problem"
+		}
+		return check
+	}
+
+	check.Status = CheckPass
+	check.Message = fmt.Sprintf("%s resolved to %s", host, strings.Join(addrs, ", "))
+	return check
+}
+
+// checkTLS connects to the vault with the configured proxy and certificates.
+func (s *Store) checkTLS(ctx context.Context, dns Check) Check {
+	check := Check{Name: "tls"}
+	if dns.Status == CheckFail {
+		check.Status = CheckSkip
+		check.Message = "skipped because the vault's name could not be resolved"
+		return check
+	}
+
+	client, err := newTransport(s.config.Transport)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		check.Hint = "check the transport settings in the plugin configuration"
+		return check
+	}
+	client.Timeout = doctorTimeout
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.vaultUrl, nil)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		return check
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("could not connect to %s: %s", s.vaultUrl, err)
+		check.Hint = tlsHint(err)
+		return check
+	}
+	resp.Body.Close()
+
+	check.Status = CheckPass
+	check.Message = fmt.Sprintf("connected to %s", s.vaultUrl)
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		cert := resp.TLS.PeerCertificates[0]
+		check.Message = fmt.Sprintf("connected to %s with %s, the certificate was issued to %s by %s",
+			s.vaultUrl, tls.VersionName(resp.TLS.Version), cert.Subject.CommonName, cert.Issuer.CommonName)
+	}
+	return check
+}
+
+// tlsHint explains how to fix a connection that failed.
+func tlsHint(err error) string {
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return "a proxy or firewall may be intercepting TLS, add its CA certificate to ca-files in the transport settings"
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return "the vault could not be reached in time, check the firewall and the proxy settings"
+	}
+	return "check the firewall, and the proxy settings in the transport table of the plugin configuration"
+}
+
+// checkSet writes a random value to the canary secret, which expires after an
+// hour.
+func (s *Store) checkSet(ctx context.Context, canary string, write bool) (Check, string) {
+	check := Check{Name: "set"}
+	if !write {
+		check.Status = CheckSkip
+		check.Message = "skipped so that the vault isn't changed, enable writes to write the canary secret"
+		return check, ""
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		return check, ""
+	}
+	value := hex.EncodeToString(b)
+	expires := time.Now().Add(time.Hour)
+	doctorTag := "true"
+
+	start := time.Now()
+	_, err := s.client.SetSecret(ctx, canary, azsecrets.SetSecretParameters{
+		Value:            &value,
+		Tags:             map[string]*string{"porter-doctor": &doctorTag},
+		SecretAttributes: &azsecrets.SecretAttributes{Expires: &expires},
+	}, nil)
+	s.metrics.recordRequest(ctx, operationCanary, s.vaultUrl, false, start, err)
+	if err != nil {
+		return failedCheck(check, fmt.Sprintf("could not write the canary secret %s", canary), classifyError(err, operationSet, s.vaultUrl)), ""
+	}
+
+	check.Status = CheckPass
+	check.Message = fmt.Sprintf("wrote the canary secret %s", canary)
+	return check, value
+}
+
+// checkGet reads the canary secret. When it wasn't written, a not found error
+// shows that the principal may read secrets.
+func (s *Store) checkGet(ctx context.Context, canary string, want string) Check {
+	check := Check{Name: "get"}
+
+	start := time.Now()
+	result, err := s.client.GetSecret(ctx, canary, "", nil)
+	s.metrics.recordRequest(ctx, operationCanary, s.vaultUrl, false, start, err)
+	err = classifyError(err, operationGet, s.vaultUrl)
+	switch {
+	case err == nil && want != "" && (result.Value == nil || *result.Value != want):
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("read the canary secret %s, but it did not have the value that was written", canary)
+		return check
+	case err == nil:
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("read the canary secret %s", canary)
+		return check
+	case want == "" && errors.Is(err, ErrSecretNotFound):
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("allowed to read secrets, the canary secret %s does not exist", canary)
+		return check
+	default:
+		return failedCheck(check, fmt.Sprintf("could not read the canary secret %s", canary), err)
+	}
+}
+
+// checkList lists the first page of secrets.
+func (s *Store) checkList(ctx context.Context) Check {
+	check := Check{Name: "list"}
+
+	pager := s.client.NewListSecretPropertiesPager(nil)
+	start := time.Now()
+	_, err := pager.NextPage(ctx)
+	s.metrics.recordRequest(ctx, operationList, s.vaultUrl, false, start, err)
+	if err != nil {
+		return failedCheck(check, "could not list secrets", classifyError(err, operationList, s.vaultUrl))
+	}
+
+	check.Status = CheckPass
+	check.Message = "listed secrets"
+	return check
+}
+
+// failedCheck reports the error, with the hint from a SecretError.
+func failedCheck(check Check, message string, err error) Check {
+	check.Status = CheckFail
+	check.Message = fmt.Sprintf("%s: %s", message, err)
+
+	var secretErr *SecretError
+	if errors.As(err, &secretErr) {
+		check.Message = fmt.Sprintf("%s: %s", message, secretErr.Kind)
+		check.Hint = secretErr.Hint
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && respErr.ErrorCode != "" {
+		check.Message = fmt.Sprintf("%s (%s)", check.Message, respErr.ErrorCode)
+	}
+	return check
+}
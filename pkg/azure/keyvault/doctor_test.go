@@ -0,0 +1,143 @@
+package keyvault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestToken(claims string) string {
+	return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2ln"
+}
+
+// newDoctorStore connects a store to a TLS server that stands in for the
+// vault, with an in-memory client and credential.
+func newDoctorStore(t *testing.T, cred *testCredential) (*Store, *testClient) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	cfg := azureconfig.Config{
+		VaultUrl:  server.URL,
+		Transport: azureconfig.TransportConfig{CAFiles: []string{caFile}},
+	}
+	store := NewStore(cfg, hclog.New(&loggerOpts))
+	store.creds[""] = cred
+	client := newTestClient()
+	withTestClients(store, map[string]*testClient{server.URL: client})
+	return store, client
+}
+
+func checksByName(checks []Check) map[string]Check {
+	byName := make(map[string]Check, len(checks))
+	for _, check := range checks {
+		byName[check.Name] = check
+	}
+	return byName
+}
+
+func TestStore_Doctor(t *testing.T) {
+	ctx := context.Background()
+	token := newTestToken(`{"oid": "myobject", "tid": "mytenant", "appid": "myapp"}`)
+
+	t.Run("healthy", func(t *testing.T) {
+		store, client := newDoctorStore(t, &testCredential{token: azcore.AccessToken{Token: token}})
+		checks := checksByName(store.Doctor(ctx, DoctorOptions{Write: true}))
+
+		for _, name := range []string{"credential", "dns", "tls", "set", "get", "list"} {
+			assert.Equal(t, CheckPass, checks[name].Status, "%s: %s", name, checks[name].Message)
+		}
+		assert.Contains(t, checks["credential"].Message, "object ID myobject (tenant mytenant, application myapp)")
+		assert.Contains(t, checks["tls"].Message, "TLS 1.3")
+		assert.Contains(t, client.secrets, DefaultCanarySecret)
+		assert.NotNil(t, client.attrs[DefaultCanarySecret].Expires, "the canary should expire")
+	})
+
+	t.Run("no writes by default", func(t *testing.T) {
+		store, client := newDoctorStore(t, &testCredential{token: azcore.AccessToken{Token: token}})
+		checks := checksByName(store.Doctor(ctx, DoctorOptions{}))
+
+		assert.Equal(t, CheckSkip, checks["set"].Status)
+		assert.Equal(t, CheckPass, checks["get"].Status, "a missing canary shows that secrets can be read")
+		assert.Empty(t, client.sets)
+	})
+
+	t.Run("canary metrics", func(t *testing.T) {
+		store, _ := newDoctorStore(t, &testCredential{token: azcore.AccessToken{Token: token}})
+		var reader *sdkmetric.ManualReader
+		store.metrics, reader = newTestMetrics(t)
+		store.Doctor(ctx, DoctorOptions{Write: true})
+
+		requests := collectMetric(t, reader, "keyvault.requests").(metricdata.Sum[int64])
+		counts := make(map[string]int64)
+		for _, point := range requests.DataPoints {
+			operation, _ := point.Attributes.Value("operation")
+			counts[operation.AsString()] += point.Value
+		}
+		assert.Equal(t, map[string]int64{operationCanary: 2, operationList: 1}, counts,
+			"the canary should not be counted as a secret that was created or resolved")
+	})
+
+	t.Run("missing permissions", func(t *testing.T) {
+		store, client := newDoctorStore(t, &testCredential{token: azcore.AccessToken{Token: token}})
+		client.errors[DefaultCanarySecret] = newResponseError(http.StatusForbidden, "Forbidden")
+		client.listErr = newResponseError(http.StatusForbidden, "Forbidden")
+		checks := checksByName(store.Doctor(ctx, DoctorOptions{Write: true}))
+
+		assert.Equal(t, CheckFail, checks["set"].Status)
+		assert.Contains(t, checks["set"].Hint, "Set secret permission")
+		assert.Equal(t, CheckFail, checks["get"].Status)
+		assert.Equal(t, CheckFail, checks["list"].Status)
+		assert.Contains(t, checks["list"].Hint, "List secret permission")
+	})
+
+	t.Run("authentication fails", func(t *testing.T) {
+		store, _ := newDoctorStore(t, &testCredential{err: errors.New("not logged in")})
+		checks := checksByName(store.Doctor(ctx, DoctorOptions{}))
+
+		assert.Equal(t, CheckFail, checks["credential"].Status)
+		assert.Contains(t, checks["credential"].Message, "not logged in")
+		assert.Equal(t, CheckSkip, checks["get"].Status)
+	})
+
+	t.Run("no vault", func(t *testing.T) {
+		store := NewStore(azureconfig.Config{}, hclog.New(&loggerOpts))
+		checks := store.Doctor(ctx, DoctorOptions{})
+		require.Len(t, checks, 1)
+		assert.Equal(t, CheckFail, checks[0].Status)
+	})
+}
+
+func TestVaultScope(t *testing.T) {
+	assert.Equal(t, "https://vault.azure.net/.default", vaultScope("https://myvault.vault.azure.net"))
+	assert.Equal(t, "https://vault.usgovcloudapi.net/.default", vaultScope("https://myvault.vault.usgovcloudapi.net/"))
+}
+
+func TestDescribePrincipal(t *testing.T) {
+	assert.Equal(t, "object ID me (tenant t, user me@example.com)",
+		describePrincipal(newTestToken(`{"oid": "me", "tid": "t", "upn": "me@example.com"}`)))
+	assert.Equal(t, "an unknown principal", describePrincipal("not a jwt"))
+}
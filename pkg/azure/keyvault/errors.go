@@ -0,0 +1,182 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// Errors returned by the store when a secret can't be read or written. Use
+// errors.Is to check which kind of error occurred.
+var (
+	// ErrSecretNotFound is returned when the secret doesn't exist in the vault.
+	ErrSecretNotFound = errors.New("secret not found")
+
+	// ErrForbidden is returned when the principal isn't allowed to access
+	// the secret, or the vault's firewall rejected the request.
+	ErrForbidden = errors.New("access to the secret is forbidden")
+
+	// ErrThrottled is returned when Key Vault throttled the request and it
+	// could not be retried.
+	ErrThrottled = errors.New("the request was throttled")
+
+	// ErrNetwork is returned when the vault could not be reached.
+	ErrNetwork = errors.New("the vault could not be reached")
+
+	// ErrAuthFailed is returned when the plugin could not authenticate with
+	// Azure.
+	ErrAuthFailed = errors.New("authentication failed")
+
+	// ErrSecretExpired is returned when the secret's expiration date has passed.
+	ErrSecretExpired = errors.New("the secret has expired")
+
+	// ErrSecretNotActive is returned when the secret's activation date is in
+	// the future.
+	ErrSecretNotActive = errors.New("the secret is not active yet")
+)
+
+const (
+	operationGet    = "get"
+	operationSet    = "set"
+	operationList   = "list"
+	operationDelete = "delete"
+	operationPurge  = "purge"
+)
+
+// SecretError describes why a request for a secret failed, and how to fix it.
+type SecretError struct {
+	// Kind is one of the Err* errors, such as ErrSecretNotFound.
+	Kind error
+
+	// Hint describes how to fix the problem.
+	Hint string
+
+	// Err is the error returned by the Azure SDK.
+	Err error
+}
+
+func (e *SecretError) Error() string {
+	if e.Hint == "" {
+		return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %s", e.Kind, e.Hint, e.Err)
+}
+
+// Unwrap allows errors.Is to match both the kind of error and the original
+// error from the Azure SDK.
+func (e *SecretError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classifyError converts an error from the Azure SDK into a SecretError.
+// Errors that don't match a known kind are returned unchanged.
+func classifyError(err error, operation string, vaultURL string) error {
+	if err == nil {
+		return nil
+	}
+
+	var secretErr *SecretError
+	if errors.As(err, &secretErr) {
+		return err
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.StatusCode {
+		case http.StatusNotFound:
+			return &SecretError{
+				Kind: ErrSecretNotFound,
+				Hint: fmt.Sprintf("check that the secret exists in %s", vaultURL),
+				Err:  err,
+			}
+		case http.StatusForbidden:
+			return &SecretError{Kind: ErrForbidden, Hint: forbiddenHint(respErr, operation, vaultURL), Err: err}
+		case http.StatusUnauthorized:
+			return &SecretError{Kind: ErrAuthFailed, Hint: authFailedHint, Err: err}
+		case http.StatusTooManyRequests:
+			return &SecretError{
+				Kind: ErrThrottled,
+				Hint: "reduce the number of concurrent Porter commands, or increase the retry settings in the plugin configuration",
+				Err:  err,
+			}
+		}
+		return err
+	}
+
+	var credErr *credentialError
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &credErr) || errors.As(err, &authErr) {
+		return &SecretError{Kind: ErrAuthFailed, Hint: authFailedHint, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &SecretError{
+			Kind: ErrNetwork,
+			Hint: fmt.Sprintf("check that %s is the correct vault, and that it can be reached from this machine or through the configured proxy", vaultURL),
+			Err:  err,
+		}
+	}
+
+	return err
+}
+
+const authFailedHint = "check the credential, tenant-id and client-id settings in the plugin configuration, and the AZURE_* environment variables"
+
+// forbiddenHint explains which permission is missing for the operation.
+func forbiddenHint(respErr *azcore.ResponseError, operation string, vaultURL string) string {
+	if respErr.ErrorCode == "ForbiddenByFirewall" || respErr.ErrorCode == "ForbiddenByConnection" {
+		return fmt.Sprintf("allow this machine through the firewall of %s, or connect through a private endpoint", vaultURL)
+	}
+
+	switch operation {
+	case operationSet:
+		return fmt.Sprintf("the principal needs the Key Vault Secrets Officer role, or an access policy with the Set secret permission, on %s", vaultURL)
+	case operationList:
+		return fmt.Sprintf("the principal needs the Key Vault Secrets User role, or an access policy with the List secret permission, on %s", vaultURL)
+	case operationDelete:
+		return fmt.Sprintf("the principal needs the Key Vault Secrets Officer role, or an access policy with the Delete secret permission, on %s", vaultURL)
+	case operationPurge:
+		return fmt.Sprintf("the principal needs the Key Vault Secrets Officer role, or an access policy with the Purge secret permission, on %s", vaultURL)
+	default:
+		return fmt.Sprintf("the principal needs the Key Vault Secrets User role, or an access policy with the Get secret permission, on %s", vaultURL)
+	}
+}
+
+// credentialError marks errors returned by the credential, so that they can be
+// classified as authentication failures.
+type credentialError struct {
+	err error
+}
+
+func (e *credentialError) Error() string {
+	return e.err.Error()
+}
+
+func (e *credentialError) Unwrap() error {
+	return e.err
+}
+
+// markCredentialErrors wraps the credential so that its errors can be
+// recognized after they pass through the Azure SDK.
+func markCredentialErrors(cred azcore.TokenCredential) azcore.TokenCredential {
+	return &markedCredential{cred: cred}
+}
+
+type markedCredential struct {
+	cred azcore.TokenCredential
+}
+
+func (c *markedCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := c.cred.GetToken(ctx, opts)
+	if err != nil {
+		return token, &credentialError{err: err}
+	}
+	return token, nil
+}
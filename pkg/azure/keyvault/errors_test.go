@@ -0,0 +1,99 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	const vaultURL = "https://myvault.vault.azure.net"
+
+	testcases := []struct {
+		name      string
+		err       error
+		operation string
+		wantKind  error
+		wantHint  string
+	}{
+		{name: "not found", err: newResponseError(http.StatusNotFound, "SecretNotFound"), operation: operationGet,
+			wantKind: ErrSecretNotFound, wantHint: "check that the secret exists"},
+		{name: "forbidden get", err: newResponseError(http.StatusForbidden, "Forbidden"), operation: operationGet,
+			wantKind: ErrForbidden, wantHint: "Key Vault Secrets User role"},
+		{name: "forbidden set", err: newResponseError(http.StatusForbidden, "Forbidden"), operation: operationSet,
+			wantKind: ErrForbidden, wantHint: "Set secret permission"},
+		{name: "firewall", err: newResponseError(http.StatusForbidden, "ForbiddenByFirewall"), operation: operationGet,
+			wantKind: ErrForbidden, wantHint: "firewall"},
+		{name: "unauthorized", err: newResponseError(http.StatusUnauthorized, "Unauthorized"), operation: operationGet,
+			wantKind: ErrAuthFailed, wantHint: "credential"},
+		{name: "throttled", err: newResponseError(http.StatusTooManyRequests, "Throttled"), operation: operationGet,
+			wantKind: ErrThrottled, wantHint: "retry settings"},
+		{name: "credential", err: &credentialError{err: errors.New("no credentials")}, operation: operationGet,
+			wantKind: ErrAuthFailed, wantHint: "credential"},
+		{name: "network", err: &net.DNSError{Err: "no such host", Name: "myvault.vault.azure.net"}, operation: operationGet,
+			wantKind: ErrNetwork, wantHint: vaultURL},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := classifyError(tc.err, tc.operation, vaultURL)
+			require.ErrorIs(t, err, tc.wantKind)
+			require.ErrorIs(t, err, tc.err, "the original error should be preserved")
+
+			var secretErr *SecretError
+			require.True(t, errors.As(err, &secretErr))
+			assert.Contains(t, secretErr.Hint, tc.wantHint)
+			assert.Contains(t, err.Error(), tc.wantHint)
+		})
+	}
+
+	t.Run("unknown errors are unchanged", func(t *testing.T) {
+		origErr := newResponseError(http.StatusInternalServerError, "InternalError")
+		assert.Same(t, origErr, classifyError(origErr, operationGet, vaultURL))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		assert.NoError(t, classifyError(nil, operationGet, vaultURL))
+	})
+}
+
+func TestMarkCredentialErrors(t *testing.T) {
+	cred := markCredentialErrors(&testCredential{err: errors.New("no credentials")})
+	_, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{})
+	require.EqualError(t, err, "no credentials")
+	require.ErrorIs(t, classifyError(err, operationGet, "https://myvault.vault.azure.net"), ErrAuthFailed)
+
+	cred = markCredentialErrors(&testCredential{token: azcore.AccessToken{Token: "abc123"}})
+	token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", token.Token)
+}
+
+func TestResolve_TypedErrors(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(azureconfig.Config{Vault: "myvault"}, hclog.New(&loggerOpts))
+	client := newTestClient()
+	client.errors["forbidden-secret"] = newResponseError(http.StatusForbidden, "Forbidden")
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+	_, err := store.Resolve(ctx, SecretKeyName, "missing-secret")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+	assert.Contains(t, err.Error(), "could not get secret missing-secret")
+
+	_, err = store.Resolve(ctx, SecretKeyName, "forbidden_secret")
+	require.ErrorIs(t, err, ErrForbidden)
+	assert.Contains(t, err.Error(), "original name was forbidden_secret")
+
+	err = store.Create(ctx, SecretKeyName, "forbidden-secret", "value")
+	require.ErrorIs(t, err, ErrForbidden)
+	assert.Contains(t, err.Error(), "Set secret permission")
+}
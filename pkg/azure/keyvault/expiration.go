@@ -0,0 +1,53 @@
+package keyvault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// secretAttributes returns the expiration and activation dates of a secret
+// created now for the key, or nil when neither is configured.
+func (s *Store) secretAttributes(keyValue string, now time.Time) *azsecrets.SecretAttributes {
+	ttl, activationDelay := s.config.Expiration.For(keyValue)
+	if ttl == 0 && activationDelay == 0 {
+		return nil
+	}
+
+	attrs := &azsecrets.SecretAttributes{}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		attrs.Expires = &expires
+	}
+	if activationDelay > 0 {
+		notBefore := now.Add(activationDelay)
+		attrs.NotBefore = &notBefore
+	}
+	return attrs
+}
+
+// checkActive returns an error when the secret has expired, or isn't active
+// yet. The dates are checked by the plugin so that prefetched secrets are
+// checked too, and the error explains why the secret can't be used.
+func (s *Store) checkActive(name string, attrs *azsecrets.SecretAttributes, now time.Time) error {
+	if attrs == nil {
+		return nil
+	}
+
+	if attrs.Expires != nil && !now.Before(*attrs.Expires) {
+		return &SecretError{
+			Kind: ErrSecretExpired,
+			Hint: "save the secret again, for example by re-running the bundle, or extend its expiration date",
+			Err:  fmt.Errorf("secret %s expired at %s", s.names.redact(name), attrs.Expires.UTC().Format(time.RFC3339)),
+		}
+	}
+	if attrs.NotBefore != nil && now.Before(*attrs.NotBefore) {
+		return &SecretError{
+			Kind: ErrSecretNotActive,
+			Hint: "wait until the activation date, or change the activation-delay in the plugin configuration",
+			Err:  fmt.Errorf("secret %s is not active until %s", s.names.redact(name), attrs.NotBefore.UTC().Format(time.RFC3339)),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package keyvault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExpirationStore(expiration azureconfig.ExpirationConfig) (*Store, *testClient) {
+	store := NewStore(azureconfig.Config{Vault: "myvault", Expiration: expiration}, hclog.New(&loggerOpts))
+	client := newTestClient()
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+	return store, client
+}
+
+func TestStore_Create_Expiration(t *testing.T) {
+	ctx := context.Background()
+	store, client := newExpirationStore(azureconfig.ExpirationConfig{
+		TTL: azureconfig.Duration(24 * time.Hour),
+		Rules: []azureconfig.ExpirationRule{
+			{Pattern: "*-output-*", TTL: azureconfig.Duration(time.Hour), ActivationDelay: azureconfig.Duration(time.Minute)},
+			{Pattern: "shared-*"},
+		},
+	})
+
+	before := time.Now()
+	require.NoError(t, store.Create(ctx, SecretKeyName, "run-password", "secret"))
+	require.NoError(t, store.Create(ctx, SecretKeyName, "run-output-token", "token"))
+	require.NoError(t, store.Create(ctx, SecretKeyName, "shared-password", "shared"))
+
+	attrs := client.attrs["run-password"]
+	require.NotNil(t, attrs)
+	assert.WithinDuration(t, before.Add(24*time.Hour), *attrs.Expires, time.Minute)
+	assert.Nil(t, attrs.NotBefore)
+
+	attrs = client.attrs["run-output-token"]
+	require.NotNil(t, attrs)
+	assert.WithinDuration(t, before.Add(time.Hour), *attrs.Expires, time.Minute)
+	assert.WithinDuration(t, before.Add(time.Minute), *attrs.NotBefore, time.Minute)
+
+	assert.Nil(t, client.attrs["shared-password"], "secrets matching a rule without a ttl should not expire")
+}
+
+func TestStore_Resolve_Expiration(t *testing.T) {
+	ctx := context.Background()
+	store, client := newExpirationStore(azureconfig.ExpirationConfig{})
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	client.secrets["expired"] = "expired"
+	client.attrs["expired"] = &azsecrets.SecretAttributes{Expires: &past}
+	client.secrets["inactive"] = "inactive"
+	client.attrs["inactive"] = &azsecrets.SecretAttributes{NotBefore: &future}
+	client.secrets["active"] = "active"
+	client.attrs["active"] = &azsecrets.SecretAttributes{NotBefore: &past, Expires: &future}
+
+	_, err := store.Resolve(ctx, SecretKeyName, "expired")
+	require.ErrorIs(t, err, ErrSecretExpired)
+	assert.Contains(t, err.Error(), "secret expired expired at")
+
+	_, err = store.Resolve(ctx, SecretKeyName, "inactive")
+	require.ErrorIs(t, err, ErrSecretNotActive)
+
+	resolved, err := store.Resolve(ctx, SecretKeyName, "active")
+	require.NoError(t, err)
+	assert.Equal(t, "active", resolved)
+}
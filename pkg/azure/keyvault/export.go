@@ -0,0 +1,309 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Export is a copy of secrets from a vault, that can be restored with Import.
+type Export struct {
+	// Vault is the url of the vault that the secrets were exported from.
+	Vault string `json:"vault"`
+
+	// Exported is when the secrets were exported.
+	Exported time.Time `json:"exported"`
+
+	// Secrets are the exported secrets, sorted by name.
+	Secrets []ExportedSecret `json:"secrets"`
+}
+
+// ExportedSecret is the current version of an exported secret.
+type ExportedSecret struct {
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// Key is the Porter key that the secret was created for, when it is known.
+	Key string `json:"key,omitempty"`
+
+	// Namespace is the Porter namespace that the secret was created for, when
+	// it is known.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Value of the secret.
+	Value string `json:"value"`
+
+	// ContentType of the secret.
+	ContentType string `json:"contentType,omitempty"`
+
+	// Tags on the secret.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// ExportOptions select the secrets that Export copies.
+type ExportOptions struct {
+	// Prefix selects the secrets whose names start with the prefix.
+	Prefix string
+
+	// Tags selects the secrets that have every tag.
+	Tags map[string]string
+
+	// All selects secrets that weren't created by the plugin too.
+	All bool
+}
+
+// ImportStatus is what happened to a secret during an import.
+type ImportStatus string
+
+const (
+	// ImportWouldImport is reported for secrets that would be written during
+	// a dry run.
+	ImportWouldImport ImportStatus = "would-import"
+	// ImportImported is reported when the secret was written.
+	ImportImported ImportStatus = "imported"
+	// ImportUnchanged is reported when the vault already has the value.
+	ImportUnchanged ImportStatus = "unchanged"
+	// ImportExists is reported when the vault has a different value, and the
+	// secret wasn't overwritten.
+	ImportExists ImportStatus = "exists"
+	// ImportFailed is reported when the secret could not be written.
+	ImportFailed ImportStatus = "failed"
+)
+
+// ImportOptions control how Import writes the secrets.
+type ImportOptions struct {
+	// DryRun reports the secrets that would be written, without writing them.
+	DryRun bool
+
+	// Overwrite writes secrets that already exist with a different value.
+	Overwrite bool
+}
+
+// ImportedSecret describes what happened to a secret during an import.
+type ImportedSecret struct {
+	// Key is the Porter key of the secret.
+	Key string `json:"key"`
+
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// Status is what happened to the secret.
+	Status ImportStatus `json:"status"`
+
+	// Error is why the secret could not be written.
+	Error string `json:"error,omitempty"`
+}
+
+// ImportReport describes the secrets written by Import.
+type ImportReport struct {
+	Vault   string           `json:"vault"`
+	DryRun  bool             `json:"dryRun"`
+	Secrets []ImportedSecret `json:"secrets"`
+}
+
+// Failed returns the number of secrets that could not be written, or already
+// exist with a different value.
+func (r ImportReport) Failed() int {
+	var failed int
+	for _, secret := range r.Secrets {
+		if secret.Status == ImportFailed || secret.Status == ImportExists {
+			failed++
+		}
+	}
+	return failed
+}
+
+// pluginTags are the tags that the plugin sets on the secrets that it creates.
+var pluginTags = []string{keyTag, namespaceTag, contentHashTag}
+
+// Export returns the current version of the secrets in the configured vault
+// that match the filter. Unless All is set, only secrets created by the
+// plugin are exported. An error is returned when any of the secrets can't be
+// read, so that the export is never missing secrets.
+func (s *Store) Export(ctx context.Context, opts ExportOptions) (Export, error) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	if err := s.Connect(ctx); err != nil {
+		return Export{}, err
+	}
+
+	export := Export{Vault: s.vaultUrl, Exported: time.Now().UTC(), Secrets: []ExportedSecret{}}
+	pager := s.client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		start := time.Now()
+		page, err := pager.NextPage(ctx)
+		s.metrics.recordRequest(ctx, operationList, s.vaultUrl, false, start, err)
+		if err != nil {
+			return Export{}, log.Errorf("could not list the secrets in %s: %w", s.vaultUrl, classifyError(err, operationList, s.vaultUrl))
+		}
+
+		for _, props := range page.Value {
+			if props == nil || props.ID == nil || (props.Managed != nil && *props.Managed) {
+				continue
+			}
+			if !matchesSecret(props, opts.Prefix, opts.Tags) || (!opts.All && !hasPluginTag(props.Tags)) {
+				continue
+			}
+
+			name := props.ID.Name()
+			start := time.Now()
+			result, err := s.client.GetSecret(ctx, name, "", nil)
+			s.metrics.recordRequest(ctx, operationGet, s.vaultUrl, false, start, err)
+			if err != nil {
+				return Export{}, log.Errorf("could not export secret %s: %w", s.names.redact(name), s.names.redactError(classifyError(err, operationGet, s.vaultUrl)))
+			}
+			export.Secrets = append(export.Secrets, newExportedSecret(name, result))
+		}
+	}
+
+	sort.Slice(export.Secrets, func(i, j int) bool {
+		return export.Secrets[i].Name < export.Secrets[j].Name
+	})
+	log.SetAttributes(attribute.Int("export.secrets", len(export.Secrets)))
+	return export, nil
+}
+
+func hasPluginTag(tags map[string]*string) bool {
+	for _, tag := range pluginTags {
+		if _, ok := tags[tag]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func newExportedSecret(name string, result azsecrets.GetSecretResponse) ExportedSecret {
+	secret := ExportedSecret{Name: name}
+	if result.Value != nil {
+		secret.Value = *result.Value
+	}
+	if result.ContentType != nil {
+		secret.ContentType = *result.ContentType
+	}
+	if len(result.Tags) > 0 {
+		secret.Tags = make(map[string]string, len(result.Tags))
+		for k, v := range result.Tags {
+			if v != nil {
+				secret.Tags[k] = *v
+			}
+		}
+	}
+	secret.Key = secret.Tags[keyTag]
+	secret.Namespace = secret.Tags[namespaceTag]
+	return secret
+}
+
+// Import writes the exported secrets to the configured vault, named the same
+// way as Create names them. The secret's key and namespace are used when the
+// export has them, and otherwise its name is used as the key in the namespace
+// of the request. Secrets that could not be written are reported, and an
+// error is only returned when the vault could not be reached.
+func (s *Store) Import(ctx context.Context, export Export, opts ImportOptions) (ImportReport, error) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	if err := s.Connect(ctx); err != nil {
+		return ImportReport{}, err
+	}
+
+	report := ImportReport{Vault: s.vaultUrl, DryRun: opts.DryRun, Secrets: make([]ImportedSecret, 0, len(export.Secrets))}
+	for _, secret := range export.Secrets {
+		imported := s.importSecret(ctx, secret, opts)
+		if imported.Error != "" {
+			log.Warn(fmt.Sprintf("could not import secret %s: %s", s.names.redact(imported.Name), imported.Error))
+		}
+		report.Secrets = append(report.Secrets, imported)
+	}
+
+	log.SetAttributes(
+		attribute.Int("import.secrets", len(report.Secrets)),
+		attribute.Int("import.failed", report.Failed()),
+	)
+	return report, nil
+}
+
+func (s *Store) importSecret(ctx context.Context, secret ExportedSecret, opts ImportOptions) ImportedSecret {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	namespace := secret.Namespace
+	if namespace == "" {
+		namespace = s.namespace()
+	}
+	key := secret.Key
+	if key == "" {
+		key = s.keyFromName(secret.Name, namespace)
+	}
+	name := s.secretName(key, namespace)
+	imported := ImportedSecret{Key: key, Name: name}
+	log.SetAttributes(s.names.attrs("cleaned-secret", name)...)
+
+	start := time.Now()
+	current, err := s.client.GetSecret(ctx, name, "", nil)
+	s.metrics.recordRequest(ctx, operationCompare, s.vaultUrl, false, start, err)
+	err = classifyError(err, operationGet, s.vaultUrl)
+	switch {
+	case err == nil && current.Value != nil && *current.Value == secret.Value:
+		imported.Status = ImportUnchanged
+		return imported
+	case err == nil && !opts.Overwrite:
+		imported.Status = ImportExists
+		imported.Error = "the secret already exists with a different value"
+		return imported
+	case err != nil && !errors.Is(err, ErrSecretNotFound):
+		imported.Status = ImportFailed
+		imported.Error = err.Error()
+		return imported
+	case opts.DryRun:
+		imported.Status = ImportWouldImport
+		return imported
+	}
+
+	// The tags set by the plugin are recreated by writeSecret, because they
+	// depend on the name and the configuration of the vault
+	tags := make(map[string]*string, len(secret.Tags))
+	for k, v := range secret.Tags {
+		tags[k] = &v
+	}
+	skipped, err := s.writeSecret(ctx, log, secretWrite{
+		name:        name,
+		key:         key,
+		namespace:   namespace,
+		value:       secret.Value,
+		contentType: secret.ContentType,
+		tags:        tags,
+	})
+	switch {
+	case err != nil:
+		imported.Status = ImportFailed
+		imported.Error = err.Error()
+	case skipped:
+		imported.Status = ImportUnchanged
+	default:
+		imported.Status = ImportImported
+	}
+	return imported
+}
+
+// keyFromName returns the key of an exported secret that doesn't have the
+// porter-key tag. The secret's name is the key, without the namespace prefix
+// when it has one, so that the prefix isn't added twice.
+func (s *Store) keyFromName(name string, namespace string) string {
+	if namespace == "" || s.config.GetNamingStrategy() != azureconfig.NamingStrategyNamespacePrefix {
+		return name
+	}
+	prefix, _ := cleanSecretName(namespace + namespaceSeparator)
+	if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+		return name[len(prefix):]
+	}
+	return name
+}
@@ -0,0 +1,141 @@
+package keyvault
+
+import (
+	"context"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Export(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	setNamespace(t, "dev")
+	ctx := context.Background()
+	store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix})
+	require.NoError(t, store.Create(ctx, SecretKeyName, "MY_PARAM.value", "secret"))
+	_, err := client.SetSecret(ctx, "cert", azsecrets.SetSecretParameters{Value: to.Ptr("pem"), ContentType: to.Ptr("application/x-pem-file")}, nil)
+	require.NoError(t, err)
+
+	t.Run("plugin secrets", func(t *testing.T) {
+		export, err := store.Export(ctx, ExportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://myvault.vault.azure.net", export.Vault)
+		require.Len(t, export.Secrets, 1)
+
+		secret := export.Secrets[0]
+		assert.Equal(t, "dev--MY-PARAM-value", secret.Name)
+		assert.Equal(t, "MY_PARAM.value", secret.Key)
+		assert.Equal(t, "dev", secret.Namespace)
+		assert.Equal(t, "secret", secret.Value)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		export, err := store.Export(ctx, ExportOptions{All: true, Prefix: "cert"})
+		require.NoError(t, err)
+		require.Len(t, export.Secrets, 1)
+		assert.Equal(t, "application/x-pem-file", export.Secrets[0].ContentType)
+		assert.Empty(t, export.Secrets[0].Key)
+	})
+}
+
+func TestStore_Import(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	ctx := context.Background()
+	export := Export{Secrets: []ExportedSecret{
+		{
+			Name: "dev--MY-PARAM-value", Key: "MY_PARAM.value", Namespace: "dev", Value: "secret",
+			Tags: map[string]string{keyTag: "MY_PARAM.value", namespaceTag: "dev", contentHashTag: "sha256:old", "team": "a"},
+		},
+		{Name: "cert", Value: "pem", ContentType: "application/x-pem-file"},
+	}}
+
+	t.Run("key naming", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{SkipUnchanged: azureconfig.SkipUnchangedContentHash, ContentHashKey: testContentHashKey})
+
+		report, err := store.Import(ctx, export, ImportOptions{})
+		require.NoError(t, err)
+		require.Len(t, report.Secrets, 2)
+		assert.Equal(t, ImportedSecret{Key: "MY_PARAM.value", Name: "MY-PARAM-value", Status: ImportImported}, report.Secrets[0])
+
+		tags := client.tags["MY-PARAM-value"]
+		assert.Equal(t, "secret", client.secrets["MY-PARAM-value"])
+		assert.Equal(t, "MY_PARAM.value", *tags[keyTag])
+		assert.Equal(t, "a", *tags["team"])
+		assert.NotContains(t, tags, namespaceTag)
+		assert.Equal(t, contentHash(testContentHashKey, "MY-PARAM-value", "secret"), *tags[contentHashTag])
+		assert.Equal(t, "application/x-pem-file", *client.versions["cert"][0].ContentType)
+	})
+
+	t.Run("namespace prefix naming", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix, Namespace: "prod"})
+
+		report, err := store.Import(ctx, export, ImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dev--MY-PARAM-value", report.Secrets[0].Name)
+		assert.Equal(t, "prod--cert", report.Secrets[1].Name, "secrets without a namespace should use the configured namespace")
+		assert.Equal(t, "dev", *client.tags["dev--MY-PARAM-value"][namespaceTag])
+	})
+
+	t.Run("namespace prefix without keys", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix, Namespace: "prod"})
+		untagged := Export{Secrets: []ExportedSecret{
+			{Name: "dev--password", Namespace: "dev", Value: "secret"},
+			{Name: "PROD--token", Value: "token"},
+			{Name: "shared", Value: "shared"},
+		}}
+
+		report, err := store.Import(ctx, untagged, ImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ImportedSecret{Key: "password", Name: "dev--password", Status: ImportImported}, report.Secrets[0],
+			"the namespace prefix should not be added twice")
+		assert.Equal(t, ImportedSecret{Key: "token", Name: "prod--token", Status: ImportImported}, report.Secrets[1])
+		assert.Equal(t, ImportedSecret{Key: "shared", Name: "prod--shared", Status: ImportImported}, report.Secrets[2])
+		assert.Equal(t, "password", *client.tags["dev--password"][keyTag])
+	})
+
+	t.Run("same as create", func(t *testing.T) {
+		cfg := azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix, Namespace: "dev", SkipUnchanged: azureconfig.SkipUnchangedContentHash, ContentHashKey: testContentHashKey}
+		created, createdClient := newNamespaceStore(cfg)
+		require.NoError(t, created.Create(ctx, SecretKeyName, "MY_PARAM.value", "secret"))
+		imported, importedClient := newNamespaceStore(cfg)
+		_, err := imported.Import(ctx, export, ImportOptions{})
+		require.NoError(t, err)
+
+		want := createdClient.tags["dev--MY-PARAM-value"]
+		got := importedClient.tags["dev--MY-PARAM-value"]
+		for _, tag := range pluginTags {
+			assert.Equal(t, *want[tag], *got[tag], tag)
+		}
+	})
+
+	t.Run("existing secrets", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{})
+		client.secrets["MY-PARAM-value"] = "secret"
+		client.secrets["cert"] = "other"
+
+		report, err := store.Import(ctx, export, ImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ImportUnchanged, report.Secrets[0].Status)
+		assert.Equal(t, ImportExists, report.Secrets[1].Status)
+		assert.Equal(t, 1, report.Failed())
+		assert.Empty(t, client.sets)
+
+		report, err = store.Import(ctx, export, ImportOptions{Overwrite: true})
+		require.NoError(t, err)
+		assert.Equal(t, ImportImported, report.Secrets[1].Status)
+		assert.Equal(t, "pem", client.secrets["cert"])
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{})
+
+		report, err := store.Import(ctx, export, ImportOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, ImportWouldImport, report.Secrets[0].Status)
+		assert.Empty(t, client.sets)
+	})
+}
@@ -0,0 +1,265 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"get.porter.sh/porter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Porter stores sensitive parameters and outputs of a run with the key
+// <runid>-<name>, where the run ID is a ULID. The namespace-prefix naming
+// strategy adds the namespace in front of the key in the secret name, but not
+// in the porter-key tag.
+var (
+	runKeyPattern    = regexp.MustCompile(`(?i)^([0-7][0-9A-HJKMNP-TV-Z]{25})-.`)
+	runSecretPattern = regexp.MustCompile(`(?i)(?:^|-)([0-7][0-9A-HJKMNP-TV-Z]{25})-.`)
+	runIDPattern     = regexp.MustCompile(`(?i)\b[0-7][0-9A-HJKMNP-TV-Z]{25}\b`)
+)
+
+// purgePollInterval is how long GC waits between checks that a deleted secret
+// can be purged.
+var purgePollInterval = 2 * time.Second
+
+// purgePollAttempts is how many times GC checks that a deleted secret can be
+// purged before giving up.
+const purgePollAttempts = 30
+
+// GCStatus is what happened to an orphaned secret.
+type GCStatus string
+
+const (
+	// GCWouldDelete is reported for orphaned secrets during a dry run.
+	GCWouldDelete GCStatus = "would-delete"
+	// GCDeleted is reported when the secret was deleted.
+	GCDeleted GCStatus = "deleted"
+	// GCPurged is reported when the secret was deleted and purged.
+	GCPurged GCStatus = "purged"
+	// GCFailed is reported when the secret could not be deleted or purged.
+	GCFailed GCStatus = "failed"
+)
+
+// GCOptions select the run-scoped secrets that GC deletes.
+type GCOptions struct {
+	// LiveRuns are the IDs of the runs that still exist. Their secrets are
+	// kept.
+	LiveRuns []string
+
+	// MinAge keeps secrets that were updated more recently, so that the
+	// secrets of runs that are in progress are not deleted.
+	MinAge time.Duration
+
+	// DryRun reports the orphaned secrets without deleting them.
+	DryRun bool
+
+	// Purge permanently deletes the secrets, instead of leaving them in the
+	// vault's soft-deleted state until the retention period ends.
+	Purge bool
+
+	// IncludeUntagged also deletes secrets without the porter-key tag, whose
+	// name looks like the key of a run-scoped secret. By default only the
+	// secrets that the plugin tagged are deleted, so that secrets that
+	// weren't saved by Porter are never deleted because of their name.
+	IncludeUntagged bool
+}
+
+// GCSecret is an orphaned run-scoped secret.
+type GCSecret struct {
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// RunID is the ID of the run that created the secret.
+	RunID string `json:"runId"`
+
+	// Updated is when the latest version of the secret was created.
+	Updated *time.Time `json:"updated,omitempty"`
+
+	// Status is what happened to the secret.
+	Status GCStatus `json:"status"`
+
+	// Error is why the secret could not be deleted or purged.
+	Error string `json:"error,omitempty"`
+}
+
+// GCReport describes the run-scoped secrets found by GC.
+type GCReport struct {
+	// Vault is the URL of the vault.
+	Vault string `json:"vault"`
+
+	// DryRun is true when no secrets were deleted.
+	DryRun bool `json:"dryRun"`
+
+	// Scanned is the number of secrets in the vault.
+	Scanned int `json:"scanned"`
+
+	// Live is the number of secrets kept because their run still exists.
+	Live int `json:"live"`
+
+	// Recent is the number of orphaned secrets kept because they are newer
+	// than the minimum age.
+	Recent int `json:"recent"`
+
+	// Untagged is the number of secrets kept because they are named like
+	// run-scoped secrets, but don't have the porter-key tag.
+	Untagged int `json:"untagged"`
+
+	// Orphaned are the secrets whose run no longer exists.
+	Orphaned []GCSecret `json:"orphaned"`
+}
+
+// Failed returns the number of orphaned secrets that could not be deleted.
+func (r GCReport) Failed() int {
+	var failed int
+	for _, secret := range r.Orphaned {
+		if secret.Status == GCFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// FindRunIDs returns the Porter run IDs in the text, such as a list of IDs or
+// an export of Porter's storage. Every ULID in the text is returned, so that
+// a run ID is never missed.
+func FindRunIDs(text string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range runIDPattern.FindAllString(text, -1) {
+		id = strings.ToUpper(id)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// runIDOf returns the run ID of a run-scoped secret. It is read from the
+// Porter key in the porter-key tag, or for secrets without the tag, from the
+// secret name, and tagged is false.
+func runIDOf(secret SecretInfo) (runID string, ok bool, tagged bool) {
+	pattern, text := runSecretPattern, secret.Name
+	if key, found := secret.Tags[keyTag]; found {
+		pattern, text, tagged = runKeyPattern, key, true
+	}
+
+	match := pattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", false, tagged
+	}
+	return strings.ToUpper(match[1]), true, tagged
+}
+
+// GC deletes the run-scoped secrets in the configured vault whose run no
+// longer exists and that are older than the minimum age. Secrets that could
+// not be deleted are reported as failed, and an error is only returned when
+// the vault could not be listed.
+func (s *Store) GC(ctx context.Context, opts GCOptions) (GCReport, error) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	if len(opts.LiveRuns) == 0 {
+		return GCReport{}, log.Error(errors.New("no live run IDs were specified, refusing to delete the secrets of every run"))
+	}
+	if opts.MinAge < 0 {
+		return GCReport{}, log.Errorf("the minimum age %s must not be negative", opts.MinAge)
+	}
+
+	secrets, err := s.List(ctx, "")
+	if err != nil {
+		return GCReport{}, err
+	}
+
+	live := make(map[string]bool, len(opts.LiveRuns))
+	for _, id := range opts.LiveRuns {
+		live[strings.ToUpper(id)] = true
+	}
+
+	report := GCReport{Vault: s.vaultUrl, DryRun: opts.DryRun, Scanned: len(secrets), Orphaned: []GCSecret{}}
+	cutoff := time.Now().Add(-opts.MinAge)
+	for _, secret := range secrets {
+		runID, ok, tagged := runIDOf(secret)
+		if !ok {
+			continue
+		}
+		if !tagged && !opts.IncludeUntagged {
+			report.Untagged++
+			continue
+		}
+		if live[runID] {
+			report.Live++
+			continue
+		}
+		// Keep secrets without an update date, we can't tell how old they are
+		if secret.Updated == nil || secret.Updated.After(cutoff) {
+			report.Recent++
+			continue
+		}
+
+		orphan := GCSecret{Name: secret.Name, RunID: runID, Updated: secret.Updated, Status: GCWouldDelete}
+		if !opts.DryRun {
+			orphan.Status, err = s.deleteSecret(ctx, secret.Name, opts.Purge)
+			if err != nil {
+				orphan.Error = err.Error()
+				log.Warn(fmt.Sprintf("could not delete secret %s: %s", s.names.redact(secret.Name), err))
+			}
+		}
+		report.Orphaned = append(report.Orphaned, orphan)
+	}
+
+	sort.Slice(report.Orphaned, func(i, j int) bool {
+		return report.Orphaned[i].Name < report.Orphaned[j].Name
+	})
+	log.SetAttributes(
+		attribute.Int("gc.orphaned", len(report.Orphaned)),
+		attribute.Int("gc.untagged", report.Untagged),
+		attribute.Int("gc.failed", report.Failed()),
+	)
+	return report, nil
+}
+
+// deleteSecret deletes the secret from the configured vault, and when purge is
+// set, waits for the deletion to finish and purges it.
+func (s *Store) deleteSecret(ctx context.Context, name string, purge bool) (GCStatus, error) {
+	start := time.Now()
+	_, err := s.client.DeleteSecret(ctx, name, nil)
+	s.metrics.recordRequest(ctx, operationDelete, s.vaultUrl, false, start, err)
+	if err != nil {
+		return GCFailed, classifyError(err, operationDelete, s.vaultUrl)
+	}
+	if !purge {
+		return GCDeleted, nil
+	}
+
+	// Deleting the secret finishes in the background, and it can't be
+	// purged until it is listed as a deleted secret
+	for attempt := 0; ; attempt++ {
+		_, err = s.client.GetDeletedSecret(ctx, name, nil)
+		if err == nil {
+			break
+		}
+		if err = classifyError(err, operationDelete, s.vaultUrl); !errors.Is(err, ErrSecretNotFound) || attempt >= purgePollAttempts {
+			return GCFailed, fmt.Errorf("the secret was deleted but could not be purged: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return GCFailed, fmt.Errorf("the secret was deleted but could not be purged: %w", ctx.Err())
+		case <-time.After(purgePollInterval):
+		}
+	}
+
+	start = time.Now()
+	_, err = s.client.PurgeDeletedSecret(ctx, name, nil)
+	s.metrics.recordRequest(ctx, operationPurge, s.vaultUrl, false, start, err)
+	if err != nil {
+		return GCFailed, fmt.Errorf("the secret was deleted but could not be purged: %w", classifyError(err, operationPurge, s.vaultUrl))
+	}
+	return GCPurged, nil
+}
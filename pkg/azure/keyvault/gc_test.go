@@ -0,0 +1,145 @@
+package keyvault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	liveRun       = "01HQ3Z8J6V6Y0W9N4GZ5D2T7KA"
+	orphanRun     = "01HQ3Z8J6V6Y0W9N4GZ5D2T7KB"
+	recentRun     = "01HQ3Z8J6V6Y0W9N4GZ5D2T7KC"
+	namespacedRun = "01HQ3Z8J6V6Y0W9N4GZ5D2T7KD"
+	untaggedRun   = "01HQ3Z8J6V6Y0W9N4GZ5D2T7KF"
+)
+
+func newGCStore(t *testing.T) (*Store, *testClient) {
+	store := NewStore(azureconfig.Config{Vault: "myvault"}, hclog.New(&loggerOpts))
+	client := newTestClient()
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	add := func(name string, key string, updated *time.Time) {
+		client.secrets[name] = "value"
+		client.attrs[name] = &azsecrets.SecretAttributes{Updated: updated}
+		if key != "" {
+			client.tags[name] = map[string]*string{keyTag: &key}
+		}
+	}
+	add(liveRun+"-password", liveRun+"-password", &old)
+	add(orphanRun+"-password", orphanRun+"-password", &old)
+	add(orphanRun+"-kubeconfig", orphanRun+"-kubeconfig", &old)
+	add(recentRun+"-password", recentRun+"-password", &recent)
+	add("dev--"+namespacedRun+"-password", namespacedRun+"-password", &old)
+	add("shared-password", "shared-password", &old)
+	add("01HQ3Z8J6V6Y0W9N4GZ5D2T7KE-undated", "01HQ3Z8J6V6Y0W9N4GZ5D2T7KE-undated", nil)
+	add(untaggedRun+"-password", "", &old)
+	return store, client
+}
+
+func TestStore_GC(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dry run", func(t *testing.T) {
+		store, client := newGCStore(t)
+
+		report, err := store.GC(ctx, GCOptions{LiveRuns: []string{liveRun}, MinAge: time.Hour, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, 8, report.Scanned)
+		assert.Equal(t, 1, report.Live)
+		assert.Equal(t, 2, report.Recent)
+		assert.Equal(t, 1, report.Untagged)
+		require.Len(t, report.Orphaned, 3)
+		assert.Equal(t, orphanRun+"-kubeconfig", report.Orphaned[0].Name)
+		assert.Equal(t, orphanRun, report.Orphaned[0].RunID)
+		assert.Equal(t, GCWouldDelete, report.Orphaned[0].Status)
+		assert.Equal(t, "dev--"+namespacedRun+"-password", report.Orphaned[2].Name)
+		assert.Len(t, client.secrets, 8, "a dry run should not delete secrets")
+	})
+
+	t.Run("include untagged", func(t *testing.T) {
+		store, client := newGCStore(t)
+
+		report, err := store.GC(ctx, GCOptions{LiveRuns: []string{liveRun}, MinAge: time.Hour, IncludeUntagged: true})
+		require.NoError(t, err)
+		assert.Zero(t, report.Untagged)
+		require.Len(t, report.Orphaned, 4)
+		assert.NotContains(t, client.secrets, untaggedRun+"-password")
+	})
+
+	t.Run("run ID from the tag", func(t *testing.T) {
+		store, client := newGCStore(t)
+		// A secret that isn't run-scoped, even though its name looks like it
+		key := "mytool-" + orphanRun + "-token"
+		client.secrets["mytool-"+orphanRun+"-token"] = "value"
+		client.tags["mytool-"+orphanRun+"-token"] = map[string]*string{keyTag: &key}
+
+		report, err := store.GC(ctx, GCOptions{LiveRuns: []string{liveRun}, MinAge: time.Hour, DryRun: true})
+		require.NoError(t, err)
+		require.Len(t, report.Orphaned, 3)
+		for _, orphan := range report.Orphaned {
+			assert.NotEqual(t, "mytool-"+orphanRun+"-token", orphan.Name)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store, client := newGCStore(t)
+
+		report, err := store.GC(ctx, GCOptions{LiveRuns: []string{liveRun}, MinAge: time.Hour})
+		require.NoError(t, err)
+		require.Len(t, report.Orphaned, 3)
+		assert.Equal(t, GCDeleted, report.Orphaned[0].Status)
+		assert.Zero(t, report.Failed())
+		assert.NotContains(t, client.secrets, orphanRun+"-password")
+		assert.Contains(t, client.deleted, orphanRun+"-password")
+		assert.Contains(t, client.secrets, liveRun+"-password")
+		assert.Contains(t, client.secrets, "shared-password")
+		assert.Empty(t, client.purges)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		store, client := newGCStore(t)
+
+		report, err := store.GC(ctx, GCOptions{LiveRuns: []string{liveRun}, MinAge: time.Hour, Purge: true})
+		require.NoError(t, err)
+		require.Len(t, report.Orphaned, 3)
+		assert.Equal(t, GCPurged, report.Orphaned[0].Status)
+		assert.Len(t, client.purges, 3)
+		assert.Empty(t, client.deleted)
+	})
+
+	t.Run("delete fails", func(t *testing.T) {
+		store, client := newGCStore(t)
+		client.errors[orphanRun+"-password"] = newResponseError(http.StatusForbidden, "Forbidden")
+
+		report, err := store.GC(ctx, GCOptions{LiveRuns: []string{liveRun}, MinAge: time.Hour})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed())
+		assert.Equal(t, GCFailed, report.Orphaned[1].Status)
+		assert.Contains(t, report.Orphaned[1].Error, "Delete secret permission")
+	})
+
+	t.Run("no live runs", func(t *testing.T) {
+		store, client := newGCStore(t)
+
+		_, err := store.GC(ctx, GCOptions{})
+		require.ErrorContains(t, err, "no live run IDs")
+		assert.Len(t, client.secrets, 8)
+	})
+}
+
+func TestFindRunIDs(t *testing.T) {
+	export := `[{"id": "` + liveRun + `", "installation": "mysql"}, {"id": "` + "01hq3z8j6v6y0w9n4gz5d2t7kb" + `"}]
+` + liveRun + `
+not-a-run-id`
+	assert.Equal(t, []string{liveRun, orphanRun}, FindRunIDs(export))
+}
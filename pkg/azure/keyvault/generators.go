@@ -0,0 +1,249 @@
+package keyvault
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The names of the built-in generators.
+const (
+	GeneratorPassword = "password"
+	GeneratorRSA      = "rsa"
+	GeneratorUUID     = "uuid"
+	GeneratorCommand  = "command"
+)
+
+// Generators are the names of the built-in generators.
+var Generators = []string{GeneratorPassword, GeneratorRSA, GeneratorUUID, GeneratorCommand}
+
+const (
+	// DefaultPasswordLength is the length of generated passwords.
+	DefaultPasswordLength = 32
+
+	// minPasswordLength is the shortest password that can be generated.
+	minPasswordLength = 12
+
+	// DefaultRSABits is the size of generated RSA keys.
+	DefaultRSABits = 3072
+
+	// pemContentType is the content type of secrets with PEM encoded keys.
+	pemContentType = "application/x-pem-file"
+)
+
+// The characters used in generated passwords. Symbols that need quoting in
+// shells and connection strings are left out.
+const (
+	passwordLower   = "abcdefghijklmnopqrstuvwxyz"
+	passwordUpper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigits  = "0123456789"
+	passwordSymbols = "!#%+-.:=?@^_~"
+)
+
+// Generator creates the value of a secret when it is rotated.
+type Generator interface {
+	// Name identifies the generator in the rotation tags.
+	Name() string
+
+	// Generate returns a new value.
+	Generate(ctx context.Context) (GeneratedValue, error)
+}
+
+// GeneratedValue is a secret value created by a Generator.
+type GeneratedValue struct {
+	// Value of the secret.
+	Value string
+
+	// ContentType of the secret, when the generator knows it.
+	ContentType string
+}
+
+// GeneratorOptions configure the built-in generators.
+type GeneratorOptions struct {
+	// Length of generated passwords.
+	Length int
+
+	// Symbols adds symbols to the characters of generated passwords.
+	Symbols bool
+
+	// Bits is the size of generated RSA keys.
+	Bits int
+
+	// Command is run with the system shell by the command generator, and
+	// prints the value to stdout.
+	Command string
+}
+
+// NewGenerator returns the built-in generator with the name.
+func NewGenerator(name string, opts GeneratorOptions) (Generator, error) {
+	switch name {
+	case GeneratorPassword:
+		length := opts.Length
+		if length == 0 {
+			length = DefaultPasswordLength
+		}
+		if length < minPasswordLength {
+			return nil, fmt.Errorf("the password length %d is too short, it must be at least %d", length, minPasswordLength)
+		}
+		return PasswordGenerator{Length: length, Symbols: opts.Symbols}, nil
+	case GeneratorRSA:
+		bits := opts.Bits
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits != 2048 && bits != 3072 && bits != 4096 {
+			return nil, fmt.Errorf("unsupported RSA key size %d, use 2048, 3072 or 4096", bits)
+		}
+		return RSAGenerator{Bits: bits}, nil
+	case GeneratorUUID:
+		return UUIDGenerator{}, nil
+	case GeneratorCommand:
+		if strings.TrimSpace(opts.Command) == "" {
+			return nil, errors.New("the command generator needs a command")
+		}
+		return CommandGenerator{Command: opts.Command}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator %q, use one of %s", name, strings.Join(Generators, ", "))
+	}
+}
+
+// PasswordGenerator generates random passwords, with at least one lowercase
+// letter, uppercase letter and digit, and a symbol when Symbols is set.
+type PasswordGenerator struct {
+	Length  int
+	Symbols bool
+}
+
+func (g PasswordGenerator) Name() string {
+	return GeneratorPassword
+}
+
+func (g PasswordGenerator) Generate(ctx context.Context) (GeneratedValue, error) {
+	classes := []string{passwordLower, passwordUpper, passwordDigits}
+	if g.Symbols {
+		classes = append(classes, passwordSymbols)
+	}
+	if g.Length < len(classes) {
+		return GeneratedValue{}, fmt.Errorf("the password length %d is too short", g.Length)
+	}
+	alphabet := strings.Join(classes, "")
+
+	// Pick from every class once, fill the rest from all of them, and then
+	// shuffle, so that every password has a character of each class
+	password := make([]byte, 0, g.Length)
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return GeneratedValue{}, err
+		}
+		password = append(password, c)
+	}
+	for len(password) < g.Length {
+		c, err := randomChar(alphabet)
+		if err != nil {
+			return GeneratedValue{}, err
+		}
+		password = append(password, c)
+	}
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return GeneratedValue{}, err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return GeneratedValue{Value: string(password)}, nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("could not generate a random number: %w", err)
+	}
+	return int(i.Int64()), nil
+}
+
+// RSAGenerator generates RSA key pairs. The value is the PKCS #8 private key
+// followed by the PKIX public key, both PEM encoded.
+type RSAGenerator struct {
+	Bits int
+}
+
+func (g RSAGenerator) Name() string {
+	return GeneratorRSA
+}
+
+func (g RSAGenerator) Generate(ctx context.Context) (GeneratedValue, error) {
+	key, err := rsa.GenerateKey(rand.Reader, g.Bits)
+	if err != nil {
+		return GeneratedValue{}, fmt.Errorf("could not generate an RSA key: %w", err)
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return GeneratedValue{}, fmt.Errorf("could not encode the RSA private key: %w", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return GeneratedValue{}, fmt.Errorf("could not encode the RSA public key: %w", err)
+	}
+
+	var value bytes.Buffer
+	_ = pem.Encode(&value, &pem.Block{Type: "PRIVATE KEY", Bytes: private})
+	_ = pem.Encode(&value, &pem.Block{Type: "PUBLIC KEY", Bytes: public})
+	return GeneratedValue{Value: value.String(), ContentType: pemContentType}, nil
+}
+
+// UUIDGenerator generates random (version 4) UUIDs.
+type UUIDGenerator struct{}
+
+func (g UUIDGenerator) Name() string {
+	return GeneratorUUID
+}
+
+func (g UUIDGenerator) Generate(ctx context.Context) (GeneratedValue, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return GeneratedValue{}, fmt.Errorf("could not generate a UUID: %w", err)
+	}
+	return GeneratedValue{Value: id.String()}, nil
+}
+
+// CommandGenerator runs a command with the system shell, and uses what it
+// prints to stdout as the value, without the trailing newline.
+type CommandGenerator struct {
+	Command string
+}
+
+func (g CommandGenerator) Name() string {
+	return GeneratorCommand
+}
+
+func (g CommandGenerator) Generate(ctx context.Context) (GeneratedValue, error) {
+	stdout, stderr, err := runShellCommand(ctx, g.Command)
+	if err != nil {
+		return GeneratedValue{}, fmt.Errorf("the generator command failed: %w: %s", err, strings.TrimSpace(stderr))
+	}
+
+	value := strings.TrimRight(stdout, "\r\n")
+	if value == "" {
+		return GeneratedValue{}, errors.New("the generator command did not print a value")
+	}
+	return GeneratedValue{Value: value}, nil
+}
@@ -0,0 +1,103 @@
+package keyvault
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGenerator(t *testing.T) {
+	g, err := NewGenerator(GeneratorPassword, GeneratorOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, PasswordGenerator{Length: DefaultPasswordLength}, g)
+
+	g, err = NewGenerator(GeneratorRSA, GeneratorOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, RSAGenerator{Bits: DefaultRSABits}, g)
+
+	_, err = NewGenerator(GeneratorPassword, GeneratorOptions{Length: 8})
+	require.ErrorContains(t, err, "it must be at least 12")
+
+	_, err = NewGenerator(GeneratorRSA, GeneratorOptions{Bits: 1024})
+	require.ErrorContains(t, err, "unsupported RSA key size 1024")
+
+	_, err = NewGenerator(GeneratorCommand, GeneratorOptions{})
+	require.EqualError(t, err, "the command generator needs a command")
+
+	_, err = NewGenerator("ecdsa", GeneratorOptions{})
+	require.EqualError(t, err, `unknown generator "ecdsa", use one of password, rsa, uuid, command`)
+}
+
+func TestPasswordGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	for _, symbols := range []bool{false, true} {
+		g := PasswordGenerator{Length: 16, Symbols: symbols}
+		generated, err := g.Generate(ctx)
+		require.NoError(t, err)
+
+		password := generated.Value
+		assert.Len(t, password, 16)
+		assert.True(t, strings.ContainsAny(password, passwordLower), "missing a lowercase letter")
+		assert.True(t, strings.ContainsAny(password, passwordUpper), "missing an uppercase letter")
+		assert.True(t, strings.ContainsAny(password, passwordDigits), "missing a digit")
+		assert.Equal(t, symbols, strings.ContainsAny(password, passwordSymbols))
+	}
+
+	a, err := PasswordGenerator{Length: 32}.Generate(ctx)
+	require.NoError(t, err)
+	b, err := PasswordGenerator{Length: 32}.Generate(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Value, b.Value)
+}
+
+func TestRSAGenerator(t *testing.T) {
+	generated, err := RSAGenerator{Bits: 2048}.Generate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, pemContentType, generated.ContentType)
+
+	private, rest := pem.Decode([]byte(generated.Value))
+	require.NotNil(t, private)
+	assert.Equal(t, "PRIVATE KEY", private.Type)
+	_, err = x509.ParsePKCS8PrivateKey(private.Bytes)
+	require.NoError(t, err)
+
+	public, _ := pem.Decode(rest)
+	require.NotNil(t, public)
+	assert.Equal(t, "PUBLIC KEY", public.Type)
+	_, err = x509.ParsePKIXPublicKey(public.Bytes)
+	require.NoError(t, err)
+}
+
+func TestUUIDGenerator(t *testing.T) {
+	generated, err := UUIDGenerator{}.Generate(context.Background())
+	require.NoError(t, err)
+	id, err := uuid.Parse(generated.Value)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(4), id.Version())
+}
+
+func TestCommandGenerator(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+	ctx := context.Background()
+
+	generated, err := CommandGenerator{Command: "printf 'from a command\\n'"}.Generate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, GeneratedValue{Value: "from a command"}, generated)
+
+	_, err = CommandGenerator{Command: "true"}.Generate(ctx)
+	require.EqualError(t, err, "the generator command did not print a value")
+
+	_, err = CommandGenerator{Command: "echo broken >&2; exit 1"}.Generate(ctx)
+	require.ErrorContains(t, err, "the generator command failed")
+	require.ErrorContains(t, err, "broken")
+}
@@ -0,0 +1,87 @@
+package keyvault
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// SecretInfo describes a secret in the vault, without its value.
+type SecretInfo struct {
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// Enabled is false when the secret has been disabled.
+	Enabled bool `json:"enabled"`
+
+	// Updated is when the latest version of the secret was created.
+	Updated *time.Time `json:"updated,omitempty"`
+
+	// Expires is when the latest version of the secret expires.
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Tags on the latest version of the secret.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// List returns the secrets in the configured vault whose names start with the
+// prefix, sorted by name. The comparison ignores case, like Key Vault secret
+// names.
+func (s *Store) List(ctx context.Context, prefix string) ([]SecretInfo, error) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	if err := s.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	var secrets []SecretInfo
+	pager := s.client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		start := time.Now()
+		page, err := pager.NextPage(ctx)
+		s.metrics.recordRequest(ctx, operationList, s.vaultUrl, false, start, err)
+		if err != nil {
+			return nil, log.Errorf("could not list the secrets in %s: %w", s.vaultUrl, classifyError(err, operationList, s.vaultUrl))
+		}
+
+		for _, props := range page.Value {
+			if props == nil || props.ID == nil {
+				continue
+			}
+			info := newSecretInfo(props)
+			if strings.HasPrefix(strings.ToLower(info.Name), strings.ToLower(prefix)) {
+				secrets = append(secrets, info)
+			}
+		}
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets, nil
+}
+
+func newSecretInfo(props *azsecrets.SecretProperties) SecretInfo {
+	info := SecretInfo{Name: props.ID.Name(), Enabled: true}
+	if props.Attributes != nil {
+		if props.Attributes.Enabled != nil {
+			info.Enabled = *props.Attributes.Enabled
+		}
+		info.Updated = props.Attributes.Updated
+		info.Expires = props.Attributes.Expires
+	}
+	if len(props.Tags) > 0 {
+		info.Tags = make(map[string]string, len(props.Tags))
+		for k, v := range props.Tags {
+			if v != nil {
+				info.Tags[k] = *v
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,49 @@
+package keyvault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_List(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(azureconfig.Config{Vault: "myvault"}, hclog.New(&loggerOpts))
+	client := newTestClient()
+	client.secrets["app-token"] = "token"
+	client.secrets["APP-password"] = "password"
+	client.secrets["other"] = "other"
+	dev := "dev"
+	client.tags["app-token"] = map[string]*string{namespaceTag: &dev}
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+	t.Run("prefix", func(t *testing.T) {
+		secrets, err := store.List(ctx, "app-")
+		require.NoError(t, err)
+		require.Len(t, secrets, 2)
+		assert.Equal(t, "APP-password", secrets[0].Name)
+		assert.True(t, secrets[0].Enabled)
+		assert.Equal(t, "app-token", secrets[1].Name)
+		assert.Equal(t, map[string]string{namespaceTag: "dev"}, secrets[1].Tags)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		secrets, err := store.List(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, secrets, 3)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		client.listErr = newResponseError(http.StatusForbidden, "Forbidden")
+		defer func() { client.listErr = nil }()
+
+		_, err := store.List(ctx, "")
+		require.ErrorIs(t, err, ErrForbidden)
+		assert.Contains(t, err.Error(), "List secret permission")
+	})
+}
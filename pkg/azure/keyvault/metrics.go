@@ -0,0 +1,142 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/go-hclog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// meterName identifies the plugin's instruments. Measurements are exported
+// through the global meter provider, which the plugin sets up when metrics are
+// enabled in its configuration.
+const meterName = "get.porter.sh/plugin/azure/keyvault"
+
+const (
+	operationResolve  = "resolve"
+	operationCreate   = "create"
+	operationPrefetch = "prefetch"
+	operationCompare  = "compare"
+	operationMigrate  = "migrate"
+	operationVersions = "versions"
+	operationRollback = "rollback"
+	operationRotate   = "rotate"
+	operationExtend   = "extend"
+	operationCanary   = "canary"
+
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+// storeMetrics holds the instruments used to record Key Vault operations.
+type storeMetrics struct {
+	requests      metric.Int64Counter
+	duration      metric.Float64Histogram
+	tokenDuration metric.Float64Histogram
+}
+
+func newStoreMetrics(provider metric.MeterProvider, l hclog.Logger) storeMetrics {
+	meter := provider.Meter(meterName)
+	noopMeter := noop.NewMeterProvider().Meter(meterName)
+
+	requests, err := meter.Int64Counter("keyvault.requests",
+		metric.WithDescription("Number of requests made to Azure Key Vault"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		l.Warn("could not create the keyvault.requests counter", "error", err)
+		requests, _ = noopMeter.Int64Counter("keyvault.requests")
+	}
+
+	duration, err := meter.Float64Histogram("keyvault.request.duration",
+		metric.WithDescription("Duration of requests made to Azure Key Vault"),
+		metric.WithUnit("s"))
+	if err != nil {
+		l.Warn("could not create the keyvault.request.duration histogram", "error", err)
+		duration, _ = noopMeter.Float64Histogram("keyvault.request.duration")
+	}
+
+	tokenDuration, err := meter.Float64Histogram("azure.credential.token.duration",
+		metric.WithDescription("Time taken to acquire an access token from the Azure credential"),
+		metric.WithUnit("s"))
+	if err != nil {
+		l.Warn("could not create the azure.credential.token.duration histogram", "error", err)
+		tokenDuration, _ = noopMeter.Float64Histogram("azure.credential.token.duration")
+	}
+
+	return storeMetrics{
+		requests:      requests,
+		duration:      duration,
+		tokenDuration: tokenDuration,
+	}
+}
+
+// recordRequest records a single request to a vault. The HTTP status is taken
+// from the Azure response error, and is left off when the vault was never
+// reached.
+func (m storeMetrics) recordRequest(ctx context.Context, operation string, vaultURL string, cacheHit bool, start time.Time, err error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("operation", operation),
+		attribute.String("vault", vaultHost(vaultURL)),
+		attribute.Bool("cache.hit", cacheHit),
+	}
+
+	if err != nil {
+		attrs = append(attrs, attribute.String("outcome", outcomeError))
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) {
+			attrs = append(attrs, attribute.Int("http.status_code", respErr.StatusCode))
+		}
+	} else {
+		attrs = append(attrs, attribute.String("outcome", outcomeSuccess))
+		if !cacheHit {
+			attrs = append(attrs, attribute.Int("http.status_code", http.StatusOK))
+		}
+	}
+
+	opt := metric.WithAttributes(attrs...)
+	m.requests.Add(ctx, 1, opt)
+	m.duration.Record(ctx, time.Since(start).Seconds(), opt)
+}
+
+// instrumentCredential wraps a credential so that the time spent acquiring
+// tokens is recorded.
+func (m storeMetrics) instrumentCredential(cred azcore.TokenCredential) azcore.TokenCredential {
+	return &instrumentedCredential{cred: cred, metrics: m}
+}
+
+type instrumentedCredential struct {
+	cred    azcore.TokenCredential
+	metrics storeMetrics
+}
+
+func (c *instrumentedCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	start := time.Now()
+	token, err := c.cred.GetToken(ctx, opts)
+
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeError
+	}
+	c.metrics.tokenDuration.Record(ctx, time.Since(start).Seconds(),
+		metric.WithAttributes(attribute.String("outcome", outcome)))
+
+	return token, err
+}
+
+// vaultHost returns the host name of the vault, which is used to split
+// measurements by vault.
+func vaultHost(vaultURL string) string {
+	u, err := url.Parse(vaultURL)
+	if err != nil || u.Host == "" {
+		return vaultURL
+	}
+	return u.Host
+}
@@ -0,0 +1,144 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type testCredential struct {
+	token azcore.AccessToken
+	err   error
+	calls int
+}
+
+func (c *testCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.calls++
+	return c.token, c.err
+}
+
+func TestVaultHost(t *testing.T) {
+	assert.Equal(t, "myvault.vault.azure.net", vaultHost("https://myvault.vault.azure.net"))
+	assert.Equal(t, "myvault.vault.azure.net", vaultHost("https://myvault.vault.azure.net/"))
+	assert.Equal(t, "not a url", vaultHost("not a url"))
+}
+
+// newTestMetrics returns store metrics that are recorded by a manual reader,
+// so that tests can collect them.
+func newTestMetrics(t *testing.T) (storeMetrics, *sdkmetric.ManualReader) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return newStoreMetrics(provider, hclog.New(&loggerOpts)), reader
+}
+
+// collectMetric returns the data of the named instrument.
+func collectMetric(t *testing.T, reader *sdkmetric.ManualReader, name string) metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	require.Failf(t, "metric not recorded", "no data was recorded for %s", name)
+	return nil
+}
+
+func TestStoreMetrics_RecordRequest(t *testing.T) {
+	m, reader := newTestMetrics(t)
+	ctx := context.Background()
+	m.recordRequest(ctx, operationResolve, "https://myvault.vault.azure.net", false, time.Now(), nil)
+	m.recordRequest(ctx, operationResolve, "https://myvault.vault.azure.net", false, time.Now(), newResponseError(http.StatusNotFound, "SecretNotFound"))
+	m.recordRequest(ctx, operationResolve, "https://myvault.vault.azure.net", true, time.Now(), nil)
+
+	requests, ok := collectMetric(t, reader, "keyvault.requests").(metricdata.Sum[int64])
+	require.True(t, ok, "keyvault.requests should be a counter")
+	counts := make(map[string]int64)
+	for _, point := range requests.DataPoints {
+		outcome, _ := point.Attributes.Value("outcome")
+		status, _ := point.Attributes.Value("http.status_code")
+		cacheHit, _ := point.Attributes.Value("cache.hit")
+		vault, _ := point.Attributes.Value("vault")
+		assert.Equal(t, "myvault.vault.azure.net", vault.AsString())
+		counts[fmt.Sprintf("%s/%d/%t", outcome.AsString(), status.AsInt64(), cacheHit.AsBool())] += point.Value
+	}
+	assert.Equal(t, map[string]int64{
+		"success/200/false": 1,
+		"error/404/false":   1,
+		"success/0/true":    1,
+	}, counts)
+
+	duration, ok := collectMetric(t, reader, "keyvault.request.duration").(metricdata.Histogram[float64])
+	require.True(t, ok, "keyvault.request.duration should be a histogram")
+	var recorded uint64
+	for _, point := range duration.DataPoints {
+		recorded += point.Count
+	}
+	assert.Equal(t, uint64(3), recorded)
+}
+
+func TestStore_RecordsMetrics(t *testing.T) {
+	store := NewStore(azureconfig.Config{Vault: "myvault"}, hclog.New(&loggerOpts))
+	var reader *sdkmetric.ManualReader
+	store.metrics, reader = newTestMetrics(t)
+	client := newTestClient()
+	client.secrets["my-secret"] = "top secret"
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+
+	_, err := store.Resolve(context.Background(), SecretKeyName, "my-secret")
+	require.NoError(t, err)
+
+	requests := collectMetric(t, reader, "keyvault.requests").(metricdata.Sum[int64])
+	require.Len(t, requests.DataPoints, 1)
+	operation, _ := requests.DataPoints[0].Attributes.Value("operation")
+	assert.Equal(t, operationResolve, operation.AsString())
+	assert.Equal(t, int64(1), requests.DataPoints[0].Value)
+}
+
+func TestInstrumentedCredential(t *testing.T) {
+	m, reader := newTestMetrics(t)
+
+	t.Run("passes the token through", func(t *testing.T) {
+		inner := &testCredential{token: azcore.AccessToken{Token: "abc123"}}
+		cred := m.instrumentCredential(inner)
+
+		token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "abc123", token.Token)
+		assert.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("passes the error through", func(t *testing.T) {
+		inner := &testCredential{err: errors.New("no credentials")}
+		cred := m.instrumentCredential(inner)
+
+		_, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{})
+		require.EqualError(t, err, "no credentials")
+	})
+
+	t.Run("records the token duration", func(t *testing.T) {
+		duration, ok := collectMetric(t, reader, "azure.credential.token.duration").(metricdata.Histogram[float64])
+		require.True(t, ok, "azure.credential.token.duration should be a histogram")
+		counts := make(map[string]uint64)
+		for _, point := range duration.DataPoints {
+			outcome, _ := point.Attributes.Value("outcome")
+			counts[outcome.AsString()] += point.Count
+		}
+		assert.Equal(t, map[string]uint64{outcomeSuccess: 1, outcomeError: 1}, counts)
+	})
+}
@@ -0,0 +1,344 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// migratedFromTag records the ID of the secret version that a version was
+// copied from, so that an interrupted migration can be resumed.
+const migratedFromTag = "porter-migrated-from"
+
+// MigrateStatus is what happened to a secret during a migration.
+type MigrateStatus string
+
+const (
+	// MigrateWouldCopy is reported for secrets with versions to copy during
+	// a dry run.
+	MigrateWouldCopy MigrateStatus = "would-copy"
+	// MigrateCopied is reported when versions of the secret were copied.
+	MigrateCopied MigrateStatus = "copied"
+	// MigrateUpToDate is reported when every version was already copied.
+	MigrateUpToDate MigrateStatus = "up-to-date"
+	// MigrateConflict is reported when the target vault has a secret with the
+	// same name that wasn't copied from the source vault.
+	MigrateConflict MigrateStatus = "conflict"
+	// MigrateFailed is reported when the secret could not be copied.
+	MigrateFailed MigrateStatus = "failed"
+)
+
+// MigrateOptions select the secrets that Migrate copies, and how.
+type MigrateOptions struct {
+	// From is the url of the vault to copy the secrets from.
+	From string
+
+	// To is the url of the vault to copy the secrets to.
+	To string
+
+	// Prefix selects the secrets whose names start with the prefix.
+	Prefix string
+
+	// Tags selects the secrets that have every tag.
+	Tags map[string]string
+
+	// All selects every secret, when Prefix and Tags aren't set.
+	All bool
+
+	// History copies every enabled version of the secrets, oldest first,
+	// instead of only the current version.
+	History bool
+
+	// DryRun reports the secrets that would be copied, without copying them.
+	DryRun bool
+
+	// Verify checks that the current value of every secret in the target
+	// vault matches the source vault.
+	Verify bool
+
+	// Overwrite copies secrets that already exist in the target vault, and
+	// weren't copied from the source vault, as new versions.
+	Overwrite bool
+}
+
+// MigratedSecret describes what happened to a secret during a migration.
+type MigratedSecret struct {
+	// Name of the secret.
+	Name string `json:"name"`
+
+	// Status is what happened to the secret.
+	Status MigrateStatus `json:"status"`
+
+	// Versions is the number of versions that were copied, or would be
+	// copied during a dry run.
+	Versions int `json:"versions"`
+
+	// Disabled is the number of disabled versions that were not copied,
+	// because their value can't be read.
+	Disabled int `json:"disabled,omitempty"`
+
+	// Verified is set when the secret was verified, and is true when the
+	// target vault has the same value.
+	Verified *bool `json:"verified,omitempty"`
+
+	// Error is why the secret could not be copied or verified.
+	Error string `json:"error,omitempty"`
+}
+
+// MigrateReport describes the secrets selected by Migrate.
+type MigrateReport struct {
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	DryRun  bool             `json:"dryRun"`
+	Secrets []MigratedSecret `json:"secrets"`
+}
+
+// Failed returns the number of secrets that could not be copied, conflict
+// with the target vault or failed verification.
+func (r MigrateReport) Failed() int {
+	var failed int
+	for _, secret := range r.Secrets {
+		if secret.Status == MigrateFailed || secret.Status == MigrateConflict || (secret.Verified != nil && !*secret.Verified) {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Migrate copies the secrets that match the filter from one vault to another,
+// with their tags, content types and dates. Every copied version is tagged
+// with the ID of the version it was copied from, so running it again resumes
+// the migration and only copies the versions that are missing. Secrets that
+// could not be copied are reported, and an error is only returned when the
+// source vault could not be listed.
+func (s *Store) Migrate(ctx context.Context, opts MigrateOptions) (MigrateReport, error) {
+	ctx, log := tracing.StartSpan(ctx, attribute.String("from", opts.From), attribute.String("to", opts.To))
+	defer log.EndSpan()
+
+	if opts.Prefix == "" && len(opts.Tags) == 0 && !opts.All {
+		return MigrateReport{}, log.Error(errors.New("select the secrets to migrate with a prefix or tags"))
+	}
+	if azureconfig.NormalizeVaultURL(opts.From) == azureconfig.NormalizeVaultURL(opts.To) {
+		return MigrateReport{}, log.Errorf("the source and target vaults are both %s", opts.From)
+	}
+
+	from, err := s.clientFor(opts.From)
+	if err != nil {
+		return MigrateReport{}, err
+	}
+	to, err := s.clientFor(opts.To)
+	if err != nil {
+		return MigrateReport{}, err
+	}
+
+	names, err := s.listMigrateSecrets(ctx, from, opts)
+	if err != nil {
+		return MigrateReport{}, log.Errorf("could not list the secrets in %s: %w", opts.From, classifyError(err, operationList, opts.From))
+	}
+
+	report := MigrateReport{From: opts.From, To: opts.To, DryRun: opts.DryRun, Secrets: make([]MigratedSecret, 0, len(names))}
+	for _, name := range names {
+		secret := s.migrateSecret(ctx, from, to, name, opts)
+		if opts.Verify && secret.Status != MigrateFailed && secret.Status != MigrateConflict && !(opts.DryRun && secret.Versions > 0) {
+			s.verifySecret(ctx, from, to, name, opts, &secret)
+		}
+		if secret.Error != "" {
+			log.Warn(fmt.Sprintf("could not migrate secret %s: %s", s.names.redact(name), secret.Error))
+		}
+		report.Secrets = append(report.Secrets, secret)
+	}
+
+	log.SetAttributes(
+		attribute.Int("migrate.matched", len(names)),
+		attribute.Int("migrate.failed", report.Failed()),
+	)
+	return report, nil
+}
+
+// listMigrateSecrets returns the names of the enabled secrets in the source
+// vault that match the filter, sorted by name. Secrets managed by Key Vault
+// certificates are skipped, because they can't be set.
+func (s *Store) listMigrateSecrets(ctx context.Context, client secretsClient, opts MigrateOptions) ([]string, error) {
+	var names []string
+	pager := client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		start := time.Now()
+		page, err := pager.NextPage(ctx)
+		s.metrics.recordRequest(ctx, operationMigrate, opts.From, false, start, err)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, props := range page.Value {
+			if props == nil || props.ID == nil || (props.Managed != nil && *props.Managed) {
+				continue
+			}
+			if matchesSecret(props, opts.Prefix, opts.Tags) {
+				names = append(names, props.ID.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// migrateSecret copies the versions of the secret that are missing from the
+// target vault.
+func (s *Store) migrateSecret(ctx context.Context, from secretsClient, to secretsClient, name string, opts MigrateOptions) MigratedSecret {
+	secret := MigratedSecret{Name: name}
+	fail := func(err error) MigratedSecret {
+		secret.Status = MigrateFailed
+		secret.Error = err.Error()
+		return secret
+	}
+
+	versions, err := s.currentVersions(ctx, from, opts.From, name, operationMigrate)
+	if err != nil {
+		return fail(fmt.Errorf("could not list the versions in %s: %w", opts.From, err))
+	}
+	var enabled []*azsecrets.SecretProperties
+	for _, version := range versions {
+		if !isEnabled(version) {
+			secret.Disabled++
+			continue
+		}
+		enabled = append(enabled, version)
+	}
+	if !opts.History {
+		// The current version is last, and is enabled because disabled
+		// secrets aren't selected
+		secret.Disabled = 0
+		if len(enabled) > 1 {
+			enabled = enabled[len(enabled)-1:]
+		}
+	}
+
+	existing, err := s.listVersions(ctx, to, opts.To, name, operationMigrate)
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		return fail(fmt.Errorf("could not list the versions in %s: %w", opts.To, err))
+	}
+	copied := make(map[string]bool, len(existing))
+	sourcePrefix := azureconfig.NormalizeVaultURL(opts.From) + "/secrets/"
+	for _, version := range existing {
+		if source := version.Tags[migratedFromTag]; source != nil && strings.HasPrefix(strings.ToLower(*source), sourcePrefix) {
+			copied[strings.ToLower(*source)] = true
+		}
+	}
+	if len(existing) > 0 && len(copied) == 0 && !opts.Overwrite {
+		secret.Status = MigrateConflict
+		secret.Error = fmt.Sprintf("the secret already exists in %s and wasn't copied from %s", opts.To, opts.From)
+		return secret
+	}
+
+	var missing []*azsecrets.SecretProperties
+	for _, version := range enabled {
+		if !copied[strings.ToLower(string(*version.ID))] {
+			missing = append(missing, version)
+		}
+	}
+	// Copying older versions that are missing makes them current, so the
+	// current version is copied again after them
+	if len(missing) > 0 && missing[len(missing)-1] != enabled[len(enabled)-1] {
+		missing = append(missing, enabled[len(enabled)-1])
+	}
+	secret.Versions = len(missing)
+	switch {
+	case len(missing) == 0:
+		secret.Status = MigrateUpToDate
+		return secret
+	case opts.DryRun:
+		secret.Status = MigrateWouldCopy
+		return secret
+	}
+
+	for i, version := range missing {
+		if err := s.copyVersion(ctx, from, to, name, version, opts); err != nil {
+			secret.Versions = i
+			return fail(err)
+		}
+	}
+	secret.Status = MigrateCopied
+	return secret
+}
+
+// copyVersion copies a version of the secret to the target vault, with its
+// tags, content type and dates.
+func (s *Store) copyVersion(ctx context.Context, from secretsClient, to secretsClient, name string, version *azsecrets.SecretProperties, opts MigrateOptions) error {
+	start := time.Now()
+	result, err := from.GetSecret(ctx, name, version.ID.Version(), nil)
+	s.metrics.recordRequest(ctx, operationMigrate, opts.From, false, start, err)
+	if err != nil {
+		return fmt.Errorf("could not get version %s from %s: %w", version.ID.Version(), opts.From, classifyError(err, operationGet, opts.From))
+	}
+
+	tags := make(map[string]*string, len(result.Tags)+1)
+	for k, v := range result.Tags {
+		tags[k] = v
+	}
+	sourceID := string(*version.ID)
+	tags[migratedFromTag] = &sourceID
+
+	params := azsecrets.SetSecretParameters{
+		Value:       result.Value,
+		ContentType: result.ContentType,
+		Tags:        tags,
+	}
+	if attrs := result.Attributes; attrs != nil {
+		params.SecretAttributes = &azsecrets.SecretAttributes{Expires: attrs.Expires, NotBefore: attrs.NotBefore}
+	}
+
+	start = time.Now()
+	_, err = to.SetSecret(ctx, name, params, nil)
+	s.metrics.recordRequest(ctx, operationMigrate, opts.To, false, start, err)
+	if err != nil {
+		return fmt.Errorf("could not set version %s in %s: %w", version.ID.Version(), opts.To, classifyError(err, operationSet, opts.To))
+	}
+	return nil
+}
+
+// verifySecret checks that the current value and content type of the secret
+// are the same in both vaults.
+func (s *Store) verifySecret(ctx context.Context, from secretsClient, to secretsClient, name string, opts MigrateOptions, secret *MigratedSecret) {
+	verified := false
+	secret.Verified = &verified
+
+	start := time.Now()
+	want, err := from.GetSecret(ctx, name, "", nil)
+	s.metrics.recordRequest(ctx, operationMigrate, opts.From, false, start, err)
+	if err != nil {
+		secret.Error = fmt.Sprintf("could not verify the secret: %s", classifyError(err, operationGet, opts.From))
+		return
+	}
+
+	start = time.Now()
+	got, err := to.GetSecret(ctx, name, "", nil)
+	s.metrics.recordRequest(ctx, operationMigrate, opts.To, false, start, err)
+	if err != nil {
+		secret.Error = fmt.Sprintf("could not verify the secret: %s", classifyError(err, operationGet, opts.To))
+		return
+	}
+
+	switch {
+	case !equalStrings(want.Value, got.Value):
+		secret.Error = fmt.Sprintf("the value in %s doesn't match %s", opts.To, opts.From)
+	case !equalStrings(want.ContentType, got.ContentType):
+		secret.Error = fmt.Sprintf("the content type in %s doesn't match %s", opts.To, opts.From)
+	default:
+		verified = true
+	}
+}
+
+func equalStrings(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,172 @@
+package keyvault
+
+import (
+	"context"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sourceVault = "https://source.vault.azure.net"
+	targetVault = "https://target.vault.azure.net"
+)
+
+func newMigrateStore(t *testing.T) (*Store, *testClient, *testClient) {
+	store := NewStore(azureconfig.Config{Vault: "source"}, hclog.New(&loggerOpts))
+	source := newTestClient()
+	target := newTestClient()
+	withTestClients(store, map[string]*testClient{sourceVault: source, targetVault: target})
+
+	set := func(name string, value string, params azsecrets.SetSecretParameters) {
+		params.Value = &value
+		_, err := source.SetSecret(context.Background(), name, params, nil)
+		require.NoError(t, err)
+	}
+	dev := map[string]*string{namespaceTag: to.Ptr("dev")}
+	set("app-password", "one", azsecrets.SetSecretParameters{Tags: dev})
+	set("app-password", "disabled", azsecrets.SetSecretParameters{Tags: dev, SecretAttributes: &azsecrets.SecretAttributes{Enabled: to.Ptr(false)}})
+	set("app-password", "two", azsecrets.SetSecretParameters{Tags: dev, ContentType: to.Ptr("text/plain")})
+	set("app-token", "token", azsecrets.SetSecretParameters{})
+	set("other", "other", azsecrets.SetSecretParameters{Tags: dev})
+	return store, source, target
+}
+
+func findMigrated(t *testing.T, report MigrateReport, name string) MigratedSecret {
+	for _, secret := range report.Secrets {
+		if secret.Name == name {
+			return secret
+		}
+	}
+	require.Failf(t, "secret not migrated", "%s is not in the report", name)
+	return MigratedSecret{}
+}
+
+func TestStore_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dry run", func(t *testing.T) {
+		store, _, target := newMigrateStore(t)
+
+		report, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-", DryRun: true})
+		require.NoError(t, err)
+		require.Len(t, report.Secrets, 2)
+		assert.Equal(t, MigratedSecret{Name: "app-password", Status: MigrateWouldCopy, Versions: 1}, report.Secrets[0])
+		assert.Empty(t, target.sets)
+	})
+
+	t.Run("current version", func(t *testing.T) {
+		store, _, target := newMigrateStore(t)
+
+		report, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-", Verify: true})
+		require.NoError(t, err)
+		assert.Zero(t, report.Failed())
+
+		secret := findMigrated(t, report, "app-password")
+		assert.Equal(t, MigrateCopied, secret.Status)
+		assert.Equal(t, 1, secret.Versions)
+		require.NotNil(t, secret.Verified)
+		assert.True(t, *secret.Verified)
+
+		require.Len(t, target.versions["app-password"], 1)
+		copied := target.versions["app-password"][0]
+		assert.Equal(t, "two", *copied.Value)
+		assert.Equal(t, "text/plain", *copied.ContentType)
+		assert.Equal(t, "dev", *copied.Tags[namespaceTag])
+		assert.Equal(t, sourceVault+"/secrets/app-password/v3", *copied.Tags[migratedFromTag])
+		assert.NotContains(t, target.secrets, "other")
+	})
+
+	t.Run("history", func(t *testing.T) {
+		store, _, target := newMigrateStore(t)
+
+		report, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Tags: map[string]string{namespaceTag: "dev"}, History: true})
+		require.NoError(t, err)
+		require.Len(t, report.Secrets, 2)
+
+		secret := findMigrated(t, report, "app-password")
+		assert.Equal(t, 2, secret.Versions)
+		assert.Equal(t, 1, secret.Disabled)
+		require.Len(t, target.versions["app-password"], 2)
+		assert.Equal(t, "one", *target.versions["app-password"][0].Value)
+		assert.Equal(t, "two", target.secrets["app-password"])
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		store, source, target := newMigrateStore(t)
+		opts := MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-"}
+		_, err := store.Migrate(ctx, opts)
+		require.NoError(t, err)
+
+		report, err := store.Migrate(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, MigrateUpToDate, findMigrated(t, report, "app-password").Status)
+		assert.Len(t, target.versions["app-password"], 1)
+
+		_, err = source.SetSecret(ctx, "app-token", azsecrets.SetSecretParameters{Value: to.Ptr("rotated")}, nil)
+		require.NoError(t, err)
+		report, err = store.Migrate(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, MigrateCopied, findMigrated(t, report, "app-token").Status)
+		assert.Equal(t, "rotated", target.secrets["app-token"])
+	})
+
+	t.Run("history after current version", func(t *testing.T) {
+		store, _, target := newMigrateStore(t)
+		_, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-password"})
+		require.NoError(t, err)
+
+		report, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-password", History: true})
+		require.NoError(t, err)
+		assert.Equal(t, 2, findMigrated(t, report, "app-password").Versions)
+		assert.Equal(t, "two", target.secrets["app-password"], "the current version should be copied again")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		store, _, target := newMigrateStore(t)
+		_, err := target.SetSecret(ctx, "app-token", azsecrets.SetSecretParameters{Value: to.Ptr("mine")}, nil)
+		require.NoError(t, err)
+
+		report, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-token"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed())
+		assert.Equal(t, MigrateConflict, report.Secrets[0].Status)
+		assert.Equal(t, "mine", target.secrets["app-token"])
+
+		report, err = store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-token", Overwrite: true})
+		require.NoError(t, err)
+		assert.Equal(t, MigrateCopied, report.Secrets[0].Status)
+		assert.Equal(t, "token", target.secrets["app-token"])
+	})
+
+	t.Run("verify finds changes", func(t *testing.T) {
+		store, _, target := newMigrateStore(t)
+		opts := MigrateOptions{From: sourceVault, To: targetVault, Prefix: "app-token"}
+		_, err := store.Migrate(ctx, opts)
+		require.NoError(t, err)
+		target.secrets["app-token"] = "changed"
+
+		opts.Verify = true
+		opts.DryRun = true
+		report, err := store.Migrate(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed())
+		assert.False(t, *report.Secrets[0].Verified)
+		assert.Contains(t, report.Secrets[0].Error, "doesn't match")
+	})
+
+	t.Run("requires a filter", func(t *testing.T) {
+		store, _, _ := newMigrateStore(t)
+
+		_, err := store.Migrate(ctx, MigrateOptions{From: sourceVault, To: targetVault})
+		require.ErrorContains(t, err, "select the secrets to migrate")
+
+		_, err = store.Migrate(ctx, MigrateOptions{From: sourceVault, To: sourceVault + "/", All: true})
+		require.ErrorContains(t, err, "the source and target vaults are both")
+	})
+}
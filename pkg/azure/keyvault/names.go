@@ -0,0 +1,138 @@
+package keyvault
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+)
+
+// SecretName describes where the store looks for a secret key, using the same
+// parsing and naming as Resolve and Create.
+type SecretName struct {
+	// Key is the secret key, as it is used by Porter.
+	Key string `json:"key"`
+
+	// Vault is the URL of the configured vault.
+	Vault string `json:"vault"`
+
+	// Namespace is the Porter namespace used to name the secret.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Prefix is added to the key by the namespace-prefix naming strategy.
+	Prefix string `json:"prefix,omitempty"`
+
+	// ID is set when the key is a secret ID, which Resolve tries first.
+	ID *SecretID `json:"id,omitempty"`
+
+	// Names are the secret names that Resolve tries in the vault, in order.
+	// It is empty when the key is a secret ID and secret-id-fallback is strict.
+	Names []string `json:"names"`
+
+	// Create is the secret name that Create writes.
+	Create string `json:"create"`
+
+	// Hashed is true when the name was too long for Key Vault and was
+	// shortened with a hash of the key.
+	Hashed bool `json:"hashed"`
+
+	// Optional is true when the key allows the secret to be missing.
+	Optional bool `json:"optional,omitempty"`
+
+	// HasDefault is true when the key has a default value.
+	HasDefault bool `json:"hasDefault,omitempty"`
+
+	// Error is why the key can't be used, such as an unknown option. The
+	// other fields aren't set when it is.
+	Error string `json:"error,omitempty"`
+}
+
+// SecretID is a secret ID parsed from a key.
+type SecretID struct {
+	Vault   string `json:"vault"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// NameCollision is a secret name that is used by more than one key.
+type NameCollision struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+}
+
+// DescribeName returns where the store looks for the secret key, without
+// connecting to the vault. The namespace is read the same way as Resolve.
+func (s *Store) DescribeName(ctx context.Context, keyValue string) SecretName {
+	namespace := s.namespace()
+	desc := SecretName{Key: keyValue, Vault: s.vaultUrl, Namespace: namespace}
+
+	key, opts, err := s.secretOptions(keyValue)
+	if err != nil {
+		desc.Error = err.Error()
+		return desc
+	}
+	desc.Names = s.secretNames(key, namespace)
+	desc.Create, desc.Hashed = s.hashedSecretName(key, namespace)
+	desc.Optional = opts.optional
+	desc.HasDefault = opts.hasDefault
+	if namespace != "" && s.config.GetNamingStrategy() == azureconfig.NamingStrategyNamespacePrefix {
+		desc.Prefix = namespace + namespaceSeparator
+	}
+
+	if id := parseID(ctx, key, s.names); id != nil {
+		desc.ID = &SecretID{Vault: id.vaultURL, Name: id.name, Version: id.version}
+		if s.config.GetSecretIDFallback() == azureconfig.SecretIDFallbackStrict {
+			desc.Names = nil
+		}
+	}
+	return desc
+}
+
+// FindNameCollisions returns the secret names that are used by more than one
+// of the keys. Key Vault names are not case-sensitive, so names that only
+// differ by case collide. Keys that could not be resolved to a name are
+// skipped.
+func FindNameCollisions(names []SecretName) []NameCollision {
+	keysByName := map[string][]string{}
+	displayName := map[string]string{}
+	for _, desc := range names {
+		if desc.Error != "" {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, name := range append([]string{desc.Create}, desc.Names...) {
+			lower := strings.ToLower(name)
+			if name == "" || seen[lower] {
+				continue
+			}
+			seen[lower] = true
+			if _, ok := displayName[lower]; !ok {
+				displayName[lower] = name
+			}
+			if !containsString(keysByName[lower], desc.Key) {
+				keysByName[lower] = append(keysByName[lower], desc.Key)
+			}
+		}
+	}
+
+	var collisions []NameCollision
+	for lower, keys := range keysByName {
+		if len(keys) > 1 {
+			collisions = append(collisions, NameCollision{Name: displayName[lower], Keys: keys})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return strings.ToLower(collisions[i].Name) < strings.ToLower(collisions[j].Name)
+	})
+	return collisions
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
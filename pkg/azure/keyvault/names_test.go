@@ -0,0 +1,127 @@
+package keyvault
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_DescribeName(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+
+	t.Run("cleaned name", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{})
+
+		desc := store.DescribeName(context.Background(), "MY_PARAM.value")
+		assert.Equal(t, "https://myvault.vault.azure.net", desc.Vault)
+		assert.Equal(t, []string{"MY-PARAM-value"}, desc.Names)
+		assert.Equal(t, "MY-PARAM-value", desc.Create)
+		assert.False(t, desc.Hashed)
+		assert.Nil(t, desc.ID)
+	})
+
+	t.Run("hashed name", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{})
+		key := strings.Repeat("a", 130)
+
+		desc := store.DescribeName(context.Background(), key)
+		assert.True(t, desc.Hashed)
+		want, hashed := cleanSecretName(key)
+		assert.True(t, hashed)
+		assert.Equal(t, want, desc.Create)
+		assert.Len(t, desc.Create, 127)
+	})
+
+	t.Run("namespace prefix", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix})
+
+		setNamespace(t, "dev")
+		desc := store.DescribeName(context.Background(), "password")
+		assert.Equal(t, "dev", desc.Namespace)
+		assert.Equal(t, "dev--", desc.Prefix)
+		assert.Equal(t, []string{"dev--password", "password"}, desc.Names)
+		assert.Equal(t, "dev--password", desc.Create)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{SecretOptions: true})
+
+		desc := store.DescribeName(context.Background(), "password?default=changeme")
+		assert.Equal(t, []string{"password"}, desc.Names)
+		assert.True(t, desc.HasDefault)
+		assert.False(t, desc.Optional)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{SecretOptions: true})
+
+		desc := store.DescribeName(context.Background(), "password?optinal")
+		assert.Contains(t, desc.Error, `unknown option "optinal"`)
+		assert.Empty(t, desc.Names)
+	})
+
+	t.Run("secret ID", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{})
+
+		desc := store.DescribeName(context.Background(), "https://othervault.vault.azure.net/secrets/password/v1")
+		require.NotNil(t, desc.ID)
+		assert.Equal(t, SecretID{Vault: "https://othervault.vault.azure.net", Name: "password", Version: "v1"}, *desc.ID)
+		assert.Len(t, desc.Names, 1)
+	})
+
+	t.Run("strict secret ID", func(t *testing.T) {
+		store, _ := newNamespaceStore(azureconfig.Config{SecretIDFallback: azureconfig.SecretIDFallbackStrict})
+
+		desc := store.DescribeName(context.Background(), "https://othervault.vault.azure.net/secrets/password")
+		require.NotNil(t, desc.ID)
+		assert.Empty(t, desc.Names)
+	})
+
+	t.Run("matches Resolve", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix, SecretOptions: true})
+		setNamespace(t, "dev")
+		ctx := context.Background()
+
+		_, err := store.Resolve(ctx, SecretKeyName, "my_param.value?optional=true")
+		require.NoError(t, err)
+
+		desc := store.DescribeName(ctx, "my_param.value?optional=true")
+		assert.Equal(t, client.gets, desc.Names)
+		assert.True(t, desc.Optional)
+	})
+}
+
+func TestFindNameCollisions(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	store, _ := newNamespaceStore(azureconfig.Config{})
+	ctx := context.Background()
+
+	var names []SecretName
+	for _, key := range []string{"my_param", "my.param", "MY-PARAM", "other", "other"} {
+		names = append(names, store.DescribeName(ctx, key))
+	}
+
+	collisions := FindNameCollisions(names)
+	require.Len(t, collisions, 1)
+	assert.Equal(t, "my-param", collisions[0].Name)
+	assert.Equal(t, []string{"my_param", "my.param", "MY-PARAM"}, collisions[0].Keys)
+}
+
+func TestFindNameCollisions_InvalidKeys(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	store, _ := newNamespaceStore(azureconfig.Config{SecretOptions: true})
+	ctx := context.Background()
+
+	names := []SecretName{
+		store.DescribeName(ctx, "first?bogus=1"),
+		store.DescribeName(ctx, "second?bogus=1"),
+	}
+	require.NotEmpty(t, names[0].Error)
+	require.NotEmpty(t, names[1].Error)
+
+	assert.Empty(t, FindNameCollisions(names))
+}
@@ -0,0 +1,114 @@
+package keyvault
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+)
+
+const (
+	// namespaceSeparator separates the namespace from the key in the secret
+	// names of the namespace-prefix strategy. Namespaces can't contain it,
+	// so that the namespace a-b with the key c, and the namespace a with the
+	// key b-c, are stored in different secrets.
+	namespaceSeparator = "--"
+
+	// namespaceTag is the tag that records the Porter namespace that a
+	// secret was created for.
+	namespaceTag = "porter-namespace"
+
+	// keyTag is the tag that records the Porter key that a secret was
+	// created for.
+	keyTag = "porter-key"
+
+	// maxTagValueLength is the longest tag value allowed by Key Vault.
+	maxTagValueLength = 256
+)
+
+// ErrNamespaceMismatch is returned when namespace isolation is enabled and the
+// secret was created for a different Porter namespace.
+var ErrNamespaceMismatch = errors.New("the secret belongs to a different namespace")
+
+// namespace returns the Porter namespace, from the plugin configuration or the
+// PORTER_NAMESPACE environment variable. Porter doesn't pass the namespace of
+// the request to plugins, so the variable must be set by the user or CI.
+func (s *Store) namespace() string {
+	return s.config.GetNamespace()
+}
+
+// checkNamespaceName returns an error when the namespace can't be used to
+// prefix secret names, because the prefix would be ambiguous.
+func (s *Store) checkNamespaceName() error {
+	namespace := s.namespace()
+	if namespace == "" || s.config.GetNamingStrategy() != azureconfig.NamingStrategyNamespacePrefix {
+		return nil
+	}
+
+	cleaned := keyVaultNameInvalidCharacters.ReplaceAllString(namespace, "-")
+	if strings.Contains(cleaned, namespaceSeparator) || strings.HasPrefix(cleaned, "-") || strings.HasSuffix(cleaned, "-") {
+		return fmt.Errorf("the namespace %q can't be used with the %s naming-strategy, because it starts or ends with a hyphen, or contains %q, after the characters that Key Vault doesn't allow are replaced with hyphens",
+			namespace, azureconfig.NamingStrategyNamespacePrefix, namespaceSeparator)
+	}
+	return nil
+}
+
+// secretNames returns the names to try, in order, when resolving the key.
+// With the namespace-prefix strategy the namespaced secret is preferred, and
+// the key is used for secrets that are shared by every namespace.
+func (s *Store) secretNames(keyValue string, namespace string) []string {
+	name, _ := cleanSecretName(keyValue)
+	if namespaced := s.secretName(keyValue, namespace); namespaced != name {
+		return []string{namespaced, name}
+	}
+	return []string{name}
+}
+
+// secretName returns the name used to store the key in the vault.
+func (s *Store) secretName(keyValue string, namespace string) string {
+	name, _ := s.hashedSecretName(keyValue, namespace)
+	return name
+}
+
+// hashedSecretName returns the name of the secret that Create writes, and
+// whether it was shortened with a hash because it was too long.
+func (s *Store) hashedSecretName(keyValue string, namespace string) (string, bool) {
+	if namespace != "" && s.config.GetNamingStrategy() == azureconfig.NamingStrategyNamespacePrefix {
+		return cleanSecretName(namespace + namespaceSeparator + keyValue)
+	}
+	return cleanSecretName(keyValue)
+}
+
+// secretTags returns the tags set on secrets created by the plugin. The key is
+// recorded because it can't always be recovered from the secret name, unless
+// it is too long for a tag.
+func (s *Store) secretTags(keyValue string, namespace string) map[string]*string {
+	tags := make(map[string]*string, 2)
+	if len(keyValue) <= maxTagValueLength {
+		tags[keyTag] = &keyValue
+	}
+	if namespace != "" && s.config.GetNamingStrategy() != azureconfig.NamingStrategyKey {
+		tags[namespaceTag] = &namespace
+	}
+	return tags
+}
+
+// checkNamespace rejects a secret that was created for another namespace,
+// when namespace isolation is enabled. Secrets without a namespace tag were
+// not created by the plugin and may be used by any namespace.
+func (s *Store) checkNamespace(name string, tags map[string]*string, namespace string) error {
+	if !s.config.NamespaceIsolation {
+		return nil
+	}
+
+	owner, ok := tags[namespaceTag]
+	if !ok || owner == nil || *owner == namespace {
+		return nil
+	}
+	return &SecretError{
+		Kind: ErrNamespaceMismatch,
+		Hint: fmt.Sprintf("it was created for the %q namespace and the current namespace is %q", *owner, namespace),
+		Err:  fmt.Errorf("secret %s is tagged with %s=%s", s.names.redact(name), namespaceTag, *owner),
+	}
+}
@@ -0,0 +1,163 @@
+package keyvault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setNamespace sets the namespace in the same way that Porter does for plugins.
+func setNamespace(t *testing.T, namespace string) {
+	t.Setenv("PORTER_NAMESPACE", namespace)
+}
+
+func newNamespaceStore(cfg azureconfig.Config) (*Store, *testClient) {
+	cfg.Vault = "myvault"
+	store := NewStore(cfg, hclog.New(&loggerOpts))
+	client := newTestClient()
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+	return store, client
+}
+
+func TestStore_NamingStrategy(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+
+	t.Run("key ignores the namespace", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{})
+		setNamespace(t, "dev")
+		ctx := context.Background()
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Equal(t, "secret", client.secrets["password"])
+		assert.NotContains(t, client.tags["password"], namespaceTag)
+		assert.Equal(t, "password", *client.tags["password"][keyTag])
+	})
+
+	t.Run("namespace prefix", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix})
+		setNamespace(t, "dev")
+		ctx := context.Background()
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Equal(t, "secret", client.secrets["dev--password"])
+		assert.Equal(t, "dev", *client.tags["dev--password"][namespaceTag])
+
+		resolved, err := store.Resolve(ctx, SecretKeyName, "password")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", resolved)
+	})
+
+	t.Run("namespace prefix falls back to the shared secret", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix})
+		client.secrets["shared"] = "everyone"
+
+		setNamespace(t, "dev")
+		resolved, err := store.Resolve(context.Background(), SecretKeyName, "shared")
+		require.NoError(t, err)
+		assert.Equal(t, "everyone", resolved)
+		assert.Equal(t, []string{"dev--shared", "shared"}, client.gets)
+	})
+
+	t.Run("namespace tag", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespaceTag})
+
+		setNamespace(t, "dev")
+		require.NoError(t, store.Create(context.Background(), SecretKeyName, "password", "secret"))
+		assert.Equal(t, "secret", client.secrets["password"])
+		assert.Equal(t, "dev", *client.tags["password"][namespaceTag])
+	})
+
+	t.Run("namespace from the configuration", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{
+			NamingStrategy: azureconfig.NamingStrategyNamespacePrefix,
+			Namespace:      "test",
+		})
+
+		require.NoError(t, store.Create(context.Background(), SecretKeyName, "password", "secret"))
+		assert.Equal(t, "secret", client.secrets["test--password"])
+	})
+
+	t.Run("namespace prefix is not ambiguous", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix})
+
+		setNamespace(t, "a-b")
+		require.NoError(t, store.Create(context.Background(), SecretKeyName, "c", "first"))
+		setNamespace(t, "a")
+		require.NoError(t, store.Create(context.Background(), SecretKeyName, "b-c", "second"))
+
+		assert.Equal(t, map[string]string{"a-b--c": "first", "a--b-c": "second"}, client.secrets)
+	})
+
+	t.Run("namespace that can't be a prefix", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix})
+
+		for _, namespace := range []string{"dev--team", "dev_", "-dev"} {
+			setNamespace(t, namespace)
+			err := store.Create(context.Background(), SecretKeyName, "password", "secret")
+			require.ErrorContains(t, err, fmt.Sprintf("the namespace %q can't be used with the namespace-prefix naming-strategy", namespace))
+		}
+		assert.Empty(t, client.secrets)
+	})
+}
+
+func TestStore_NamespaceIsolation(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+
+	store, client := newNamespaceStore(azureconfig.Config{
+		NamingStrategy:     azureconfig.NamingStrategyNamespaceTag,
+		NamespaceIsolation: true,
+	})
+	client.secrets["shared"] = "everyone"
+	setNamespace(t, "prod")
+	require.NoError(t, store.Create(context.Background(), SecretKeyName, "password", "secret"))
+
+	t.Run("same namespace", func(t *testing.T) {
+		setNamespace(t, "prod")
+		resolved, err := store.Resolve(context.Background(), SecretKeyName, "password")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", resolved)
+	})
+
+	t.Run("other namespace", func(t *testing.T) {
+		setNamespace(t, "dev")
+		_, err := store.Resolve(context.Background(), SecretKeyName, "password")
+		require.ErrorIs(t, err, ErrNamespaceMismatch)
+		assert.Contains(t, err.Error(), `created for the "prod" namespace`)
+	})
+
+	t.Run("untagged secret", func(t *testing.T) {
+		setNamespace(t, "dev")
+		resolved, err := store.Resolve(context.Background(), SecretKeyName, "shared")
+		require.NoError(t, err)
+		assert.Equal(t, "everyone", resolved)
+	})
+
+	t.Run("secret ID", func(t *testing.T) {
+		store.config.SecretIDFallback = azureconfig.SecretIDFallbackStrict
+		defer func() { store.config.SecretIDFallback = "" }()
+
+		setNamespace(t, "dev")
+		_, err := store.Resolve(context.Background(), SecretKeyName, "https://myvault.vault.azure.net/secrets/password")
+		require.ErrorIs(t, err, ErrNamespaceMismatch)
+	})
+}
+
+func TestStore_KeyTag(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	store, client := newNamespaceStore(azureconfig.Config{})
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, SecretKeyName, "MY_PARAM.value", "secret"))
+	assert.Equal(t, "MY_PARAM.value", *client.tags["MY-PARAM-value"][keyTag])
+
+	long := strings.Repeat("a", maxTagValueLength+1)
+	require.NoError(t, store.Create(ctx, SecretKeyName, long, "secret"))
+	name, _ := cleanSecretName(long)
+	assert.NotContains(t, client.tags[name], keyTag, "keys longer than a tag value should not be tagged")
+}
@@ -0,0 +1,108 @@
+package keyvault
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Headers that the Azure SDK reads to decide how long to wait before retrying
+// a throttled request.
+var retryAfterHeaders = []string{"Retry-After", "retry-after-ms", "x-ms-retry-after-ms"}
+
+// newClientOptions builds the options used by every Azure client that the
+// plugin creates, so that Key Vault and token requests behave the same way.
+func newClientOptions(cfg azureconfig.Config) (azcore.ClientOptions, error) {
+	opts := azcore.ClientOptions{
+		Retry: policy.RetryOptions{
+			MaxRetries:    cfg.Retry.MaxRetries,
+			RetryDelay:    cfg.Retry.RetryDelay.Duration(),
+			MaxRetryDelay: cfg.Retry.MaxRetryDelay.Duration(),
+			TryTimeout:    cfg.Retry.TryTimeout.Duration(),
+		},
+	}
+
+	if !cfg.Retry.ShouldRespectRetryAfter() {
+		opts.PerRetryPolicies = append(opts.PerRetryPolicies, ignoreRetryAfterPolicy{})
+	}
+
+	if cfg.Transport.IsCustomized() {
+		transport, err := newTransport(cfg.Transport)
+		if err != nil {
+			return azcore.ClientOptions{}, err
+		}
+		opts.Transport = transport
+	}
+
+	return opts, nil
+}
+
+// newTransport creates an HTTP client that uses the configured proxy and
+// certificates. Anything that isn't configured keeps the defaults from
+// http.DefaultTransport.
+func newTransport(cfg azureconfig.TransportConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != "" {
+		proxy := httpproxy.Config{
+			HTTPProxy:  cfg.ProxyURL,
+			HTTPSProxy: cfg.ProxyURL,
+			NoProxy:    cfg.NoProxy,
+		}
+		proxyFunc := proxy.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(cfg.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, caFile := range cfg.CAFiles {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not read CA file %s", caFile)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.Errorf("no PEM encoded certificates were found in CA file %s", caFile)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertificate != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertificate, cfg.ClientKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load client certificate %s", cfg.ClientCertificate)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// ignoreRetryAfterPolicy removes the Retry-After headers from responses before
+// the retry policy sees them, so that the configured backoff is used instead.
+type ignoreRetryAfterPolicy struct{}
+
+func (ignoreRetryAfterPolicy) Do(req *policy.Request) (*http.Response, error) {
+	resp, err := req.Next()
+	if resp != nil {
+		for _, h := range retryAfterHeaders {
+			resp.Header.Del(h)
+		}
+	}
+	return resp, err
+}
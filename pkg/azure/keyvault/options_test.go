@@ -0,0 +1,158 @@
+package keyvault
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// throttlingTransport responds with a 429 and a long Retry-After until the
+// specified number of requests has been made.
+type throttlingTransport struct {
+	throttled int
+	requests  int
+}
+
+func (t *throttlingTransport) Do(req *http.Request) (*http.Response, error) {
+	t.requests++
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+	if t.requests <= t.throttled {
+		resp.StatusCode = http.StatusTooManyRequests
+		resp.Header.Set("Retry-After", "3600")
+	}
+	return resp, nil
+}
+
+func TestNewClientOptions_Retry(t *testing.T) {
+	respect := false
+	testcases := []struct {
+		name         string
+		retry        azureconfig.RetryConfig
+		wantStatus   int
+		wantRequests int
+	}{
+		{
+			name: "Retry-After longer than the max delay is not retried",
+			retry: azureconfig.RetryConfig{
+				RetryDelay:    azureconfig.Duration(time.Millisecond),
+				MaxRetryDelay: azureconfig.Duration(time.Second),
+			},
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: 1,
+		},
+		{
+			name: "Retry-After ignored",
+			retry: azureconfig.RetryConfig{
+				RetryDelay:        azureconfig.Duration(time.Millisecond),
+				MaxRetryDelay:     azureconfig.Duration(time.Second),
+				RespectRetryAfter: &respect,
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 3,
+		},
+		{
+			name: "retries disabled",
+			retry: azureconfig.RetryConfig{
+				MaxRetries:        -1,
+				RespectRetryAfter: &respect,
+			},
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &throttlingTransport{throttled: 2}
+			opts, err := newClientOptions(azureconfig.Config{Retry: tc.retry})
+			require.NoError(t, err)
+			opts.Transport = transport
+			pl := runtime.NewPipeline("test", "v1.0.0", runtime.PipelineOptions{}, &opts)
+
+			req, err := runtime.NewRequest(context.Background(), http.MethodGet, "https://myvault.vault.azure.net/secrets/test")
+			require.NoError(t, err)
+			resp, err := pl.Do(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, resp.StatusCode)
+			assert.Equal(t, tc.wantRequests, transport.requests)
+		})
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		client, err := newTransport(azureconfig.TransportConfig{
+			ProxyURL: "http://proxy.example.com:8080",
+			NoProxy:  "internal.example.com,10.0.0.0/8",
+		})
+		require.NoError(t, err)
+		transport := client.Transport.(*http.Transport)
+
+		testcases := map[string]string{
+			"https://myvault.vault.azure.net/secrets/test": "http://proxy.example.com:8080",
+			"https://login.microsoftonline.com/token":      "http://proxy.example.com:8080",
+			"https://vault.internal.example.com/secrets":   "",
+			"https://10.1.2.3/secrets":                     "",
+		}
+		for target, wantProxy := range testcases {
+			req, err := http.NewRequest(http.MethodGet, target, nil)
+			require.NoError(t, err)
+			proxy, err := transport.Proxy(req)
+			require.NoError(t, err)
+			if wantProxy == "" {
+				assert.Nil(t, proxy, "expected %s to bypass the proxy", target)
+			} else {
+				require.NotNil(t, proxy, "expected %s to use the proxy", target)
+				assert.Equal(t, wantProxy, proxy.String())
+			}
+		}
+	})
+
+	t.Run("custom CA", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Config.ErrorLog = log.New(io.Discard, "", 0)
+		server.StartTLS()
+		defer server.Close()
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+		client, err := newTransport(azureconfig.TransportConfig{})
+		require.NoError(t, err)
+		_, err = client.Get(server.URL)
+		require.Error(t, err, "the test server should not be trusted without the CA file")
+
+		client, err = newTransport(azureconfig.TransportConfig{CAFiles: []string{caFile}})
+		require.NoError(t, err)
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+
+	t.Run("invalid CA file", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600))
+
+		_, err := newTransport(azureconfig.TransportConfig{CAFiles: []string{caFile}})
+		require.ErrorContains(t, err, "no PEM encoded certificates were found")
+	})
+}
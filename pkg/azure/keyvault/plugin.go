@@ -8,7 +8,6 @@ import (
 	"get.porter.sh/porter/pkg/secrets"
 	"get.porter.sh/porter/pkg/secrets/plugins"
 	"get.porter.sh/porter/pkg/secrets/pluginstore"
-	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 )
 
@@ -22,12 +21,7 @@ type Plugin struct {
 }
 
 func NewPlugin(c *portercontext.Context, cfg azureconfig.Config) plugin.Plugin {
-	logger := hclog.New(&hclog.LoggerOptions{
-		Name:       PluginInterface,
-		Output:     os.Stderr,
-		Level:      hclog.Debug,
-		JSONFormat: true,
-	})
+	logger := cfg.Logging.NewLogger(PluginInterface, os.Stderr)
 
 	return pluginstore.NewPlugin(c, NewStore(cfg, logger))
 }
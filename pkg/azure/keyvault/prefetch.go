@@ -0,0 +1,134 @@
+package keyvault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// prefetch fetches the secrets that match the prefetch filter from the
+// configured vault, so that Resolve can return them without another request.
+// Prefetching is an optimization, so failures are logged and the secrets that
+// could not be fetched are requested when they are resolved.
+func (s *Store) prefetch(ctx context.Context) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+
+	names, err := s.listPrefetchSecrets(ctx)
+	if err != nil {
+		log.Warn(fmt.Sprintf("could not list the secrets to prefetch from %s, secrets will be fetched when they are resolved: %s",
+			s.vaultUrl, classifyError(err, operationList, s.vaultUrl)))
+		return
+	}
+
+	var wg sync.WaitGroup
+	var failed int
+	var failedMu sync.Mutex
+	sem := make(chan struct{}, s.config.Prefetch.GetConcurrency())
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			result, err := s.client.GetSecret(ctx, name, "", nil)
+			s.metrics.recordRequest(ctx, operationPrefetch, s.vaultUrl, false, start, err)
+			if err != nil {
+				log.Debug(fmt.Sprintf("could not prefetch secret %s: %s", s.names.redact(name), err))
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+				return
+			}
+			s.storePrefetched(name, result)
+		}(name)
+	}
+	wg.Wait()
+
+	log.SetAttributes(
+		attribute.Int("prefetch.matched", len(names)),
+		attribute.Int("prefetch.failed", failed),
+	)
+	if failed > 0 {
+		log.Warn(fmt.Sprintf("could not prefetch %d of %d secrets from %s, they will be fetched when they are resolved", failed, len(names), s.vaultUrl))
+	}
+}
+
+// listPrefetchSecrets returns the names of the enabled secrets that match the
+// prefetch filter.
+func (s *Store) listPrefetchSecrets(ctx context.Context) ([]string, error) {
+	var names []string
+	pager := s.client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		start := time.Now()
+		page, err := pager.NextPage(ctx)
+		s.metrics.recordRequest(ctx, operationPrefetch, s.vaultUrl, false, start, err)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, props := range page.Value {
+			if props == nil || props.ID == nil || !s.matchesPrefetch(props) {
+				continue
+			}
+			names = append(names, props.ID.Name())
+		}
+	}
+	return names, nil
+}
+
+// matchesPrefetch returns true when the secret is enabled and matches the
+// prefix and every tag of the prefetch filter.
+func (s *Store) matchesPrefetch(props *azsecrets.SecretProperties) bool {
+	return matchesSecret(props, s.config.Prefetch.Prefix, s.config.Prefetch.Tags)
+}
+
+// matchesSecret returns true when the secret is enabled and matches the prefix
+// and every tag. The prefix comparison ignores case, like Key Vault secret
+// names.
+func matchesSecret(props *azsecrets.SecretProperties, prefix string, tags map[string]string) bool {
+	if props.Attributes != nil && props.Attributes.Enabled != nil && !*props.Attributes.Enabled {
+		return false
+	}
+
+	if !strings.HasPrefix(strings.ToLower(props.ID.Name()), strings.ToLower(prefix)) {
+		return false
+	}
+	for tag, want := range tags {
+		got, ok := props.Tags[tag]
+		if !ok || got == nil || *got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// prefetched returns the secret from the prefetched snapshot.
+func (s *Store) prefetched(name string) (azsecrets.GetSecretResponse, bool) {
+	s.snapshotMu.RLock()
+	defer s.snapshotMu.RUnlock()
+	result, ok := s.snapshot[strings.ToLower(name)]
+	return result, ok
+}
+
+func (s *Store) storePrefetched(name string, result azsecrets.GetSecretResponse) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	s.snapshot[strings.ToLower(name)] = result
+}
+
+// forgetPrefetched removes a secret from the snapshot after it is changed, so
+// that the old value isn't resolved.
+func (s *Store) forgetPrefetched(name string) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	delete(s.snapshot, strings.ToLower(name))
+}
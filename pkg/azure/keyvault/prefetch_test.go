@@ -0,0 +1,73 @@
+package keyvault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPrefetchStore(prefetch azureconfig.PrefetchConfig) (*Store, *testClient) {
+	store := NewStore(azureconfig.Config{Vault: "myvault", Prefetch: prefetch}, hclog.New(&loggerOpts))
+	client := newTestClient()
+	client.secrets["app-password"] = "password"
+	client.secrets["APP-token"] = "token"
+	client.secrets["other"] = "other"
+	web := "web"
+	client.tags["other"] = map[string]*string{"app": &web}
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+	return store, client
+}
+
+func TestStore_Prefetch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("prefix", func(t *testing.T) {
+		store, client := newPrefetchStore(azureconfig.PrefetchConfig{Enabled: true, Prefix: "app-", Concurrency: 1})
+		require.NoError(t, store.Connect(ctx))
+		assert.ElementsMatch(t, []string{"APP-token", "app-password"}, client.gets)
+
+		resolved, err := store.Resolve(ctx, SecretKeyName, "app-password")
+		require.NoError(t, err)
+		assert.Equal(t, "password", resolved)
+		assert.Len(t, client.gets, 2, "prefetched secrets should not be requested again")
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		store, client := newPrefetchStore(azureconfig.PrefetchConfig{Enabled: true, Tags: map[string]string{"app": "web"}})
+		require.NoError(t, store.Connect(ctx))
+		assert.Equal(t, []string{"other"}, client.gets)
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		store, client := newPrefetchStore(azureconfig.PrefetchConfig{Enabled: true, Prefix: "app-"})
+		resolved, err := store.Resolve(ctx, SecretKeyName, "other")
+		require.NoError(t, err)
+		assert.Equal(t, "other", resolved)
+		assert.Contains(t, client.gets, "other")
+	})
+
+	t.Run("list fails", func(t *testing.T) {
+		store, client := newPrefetchStore(azureconfig.PrefetchConfig{Enabled: true, Prefix: "app-"})
+		client.listErr = newResponseError(http.StatusForbidden, "Forbidden")
+
+		resolved, err := store.Resolve(ctx, SecretKeyName, "app-password")
+		require.NoError(t, err)
+		assert.Equal(t, "password", resolved)
+		assert.Equal(t, []string{"app-password"}, client.gets)
+	})
+
+	t.Run("create replaces the prefetched value", func(t *testing.T) {
+		store, _ := newPrefetchStore(azureconfig.PrefetchConfig{Enabled: true, Prefix: "app-"})
+		require.NoError(t, store.Connect(ctx))
+		require.NoError(t, store.Create(ctx, SecretKeyName, "app-password", "changed"))
+
+		resolved, err := store.Resolve(ctx, SecretKeyName, "app-password")
+		require.NoError(t, err)
+		assert.Equal(t, "changed", resolved)
+	})
+}
@@ -0,0 +1,106 @@
+package keyvault
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// redactedSecretName is logged in place of a secret name when names are omitted.
+const redactedSecretName = "[redacted]"
+
+// nameRedactor decides how secret names are recorded in span attributes and
+// log messages. The zero value records names as-is.
+type nameRedactor struct {
+	mode string
+	salt []byte
+}
+
+func newNameRedactor(cfg azureconfig.Config) nameRedactor {
+	return nameRedactor{
+		mode: cfg.SecretNameTracing,
+		salt: []byte(cfg.SecretNameSalt),
+	}
+}
+
+// enabled returns true when secret names are hashed or omitted.
+func (r nameRedactor) enabled() bool {
+	return r.mode == azureconfig.SecretNameTracingHash || r.mode == azureconfig.SecretNameTracingOmit
+}
+
+// redact returns the representation of a secret name that is safe to include
+// in a log message.
+func (r nameRedactor) redact(name string) string {
+	switch r.mode {
+	case azureconfig.SecretNameTracingHash:
+		mac := hmac.New(sha256.New, r.salt)
+		mac.Write([]byte(name))
+		return fmt.Sprintf("sha256:%x", mac.Sum(nil)[:16])
+	case azureconfig.SecretNameTracingOmit:
+		return redactedSecretName
+	default:
+		return name
+	}
+}
+
+// attrs returns the span attributes that describe a secret name. When names
+// are omitted no attributes are returned at all.
+func (r nameRedactor) attrs(key string, name string) []attribute.KeyValue {
+	if r.mode == azureconfig.SecretNameTracingOmit {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String(key, r.redact(name))}
+}
+
+// secretURLPath matches the secret name in the path of a Key Vault url.
+var secretURLPath = regexp.MustCompile(`/(secrets|deletedsecrets)/([^/?\s"]+)`)
+
+// redactError hides the secret names in an error from the Azure SDK, whose
+// message includes the request url and the response body. The error still
+// matches the original error with errors.Is and errors.As.
+func (r nameRedactor) redactError(err error) error {
+	if err == nil || !r.enabled() {
+		return err
+	}
+
+	if secretErr, ok := err.(*SecretError); ok {
+		redacted := *secretErr
+		redacted.Err = r.redactError(secretErr.Err)
+		return &redacted
+	}
+
+	msg := err.Error()
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		// Drop the response body, which repeats the secret name
+		msg = fmt.Sprintf("RESPONSE %d: %s", respErr.StatusCode, respErr.ErrorCode)
+		if resp := respErr.RawResponse; resp != nil && resp.Request != nil {
+			msg = fmt.Sprintf("%s %s: %s", resp.Request.Method, resp.Request.URL, msg)
+		}
+	}
+	msg = secretURLPath.ReplaceAllStringFunc(msg, func(path string) string {
+		match := secretURLPath.FindStringSubmatch(path)
+		return "/" + match[1] + "/" + r.redact(match[2])
+	})
+	return &redactedError{msg: msg, err: err}
+}
+
+// redactedError replaces the message of an error that includes secret names.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
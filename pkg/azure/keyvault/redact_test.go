@@ -0,0 +1,80 @@
+package keyvault
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameRedactor(t *testing.T) {
+	t.Run("plain by default", func(t *testing.T) {
+		r := newNameRedactor(azureconfig.Config{})
+		assert.Equal(t, "my-secret", r.redact("my-secret"))
+		attrs := r.attrs("requested-secret", "my-secret")
+		require.Len(t, attrs, 1)
+		assert.Equal(t, "my-secret", attrs[0].Value.AsString())
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		r := newNameRedactor(azureconfig.Config{SecretNameTracing: azureconfig.SecretNameTracingHash, SecretNameSalt: "salt"})
+		got := r.redact("my-secret")
+		assert.NotContains(t, got, "my-secret")
+		assert.Regexp(t, `^sha256:[0-9a-f]{32}$`, got)
+		assert.Equal(t, got, r.redact("my-secret"), "the hash should be stable so that spans can be correlated")
+
+		other := newNameRedactor(azureconfig.Config{SecretNameTracing: azureconfig.SecretNameTracingHash, SecretNameSalt: "pepper"})
+		assert.NotEqual(t, got, other.redact("my-secret"), "the salt should change the hash")
+
+		attrs := r.attrs("requested-secret", "my-secret")
+		require.Len(t, attrs, 1)
+		assert.Equal(t, got, attrs[0].Value.AsString())
+	})
+
+	t.Run("omit", func(t *testing.T) {
+		r := newNameRedactor(azureconfig.Config{SecretNameTracing: azureconfig.SecretNameTracingOmit})
+		assert.Equal(t, redactedSecretName, r.redact("my-secret"))
+		assert.Empty(t, r.attrs("requested-secret", "my-secret"))
+	})
+}
+
+func TestNameRedactor_RedactError(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://myvault.vault.azure.net/secrets/my-secret/abc123?api-version=7.4", nil)
+	require.NoError(t, err)
+	respErr := &azcore.ResponseError{
+		StatusCode:  http.StatusNotFound,
+		ErrorCode:   "SecretNotFound",
+		RawResponse: &http.Response{StatusCode: http.StatusNotFound, Request: req},
+	}
+	urlErr := &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New("dial tcp: connection refused")}
+
+	t.Run("plain", func(t *testing.T) {
+		r := newNameRedactor(azureconfig.Config{})
+		err := classifyError(respErr, operationGet, "https://myvault.vault.azure.net")
+		assert.Same(t, err, r.redactError(err))
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		r := newNameRedactor(azureconfig.Config{SecretNameTracing: azureconfig.SecretNameTracingHash, SecretNameSalt: "salt"})
+		err := r.redactError(classifyError(respErr, operationGet, "https://myvault.vault.azure.net"))
+		assert.NotContains(t, err.Error(), "my-secret")
+		assert.Contains(t, err.Error(), "GET https://myvault.vault.azure.net/secrets/"+r.redact("my-secret")+"/abc123")
+		assert.Contains(t, err.Error(), "RESPONSE 404: SecretNotFound")
+		assert.ErrorIs(t, err, ErrSecretNotFound)
+		var got *azcore.ResponseError
+		require.ErrorAs(t, err, &got)
+		assert.Same(t, respErr, got)
+	})
+
+	t.Run("omit", func(t *testing.T) {
+		r := newNameRedactor(azureconfig.Config{SecretNameTracing: azureconfig.SecretNameTracingOmit})
+		err := r.redactError(urlErr)
+		assert.Equal(t, `Get "https://myvault.vault.azure.net/secrets/[redacted]/abc123?api-version=7.4": dial tcp: connection refused`, err.Error())
+		assert.ErrorIs(t, err, urlErr)
+	})
+}
@@ -0,0 +1,214 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The tags that record how the current version of a secret was rotated.
+const (
+	rotatedAtTag         = "porter-rotated-at"
+	rotatedFromTag       = "porter-rotated-from"
+	rotationGeneratorTag = "porter-rotation-generator"
+)
+
+// rotationTags are the tags that Rotate sets on the new version.
+var rotationTags = []string{rotatedAtTag, rotatedFromTag, rotationGeneratorTag}
+
+// RotateOptions control how Rotate creates the new version of a secret.
+type RotateOptions struct {
+	// Generator creates the new value.
+	Generator Generator
+
+	// DisableOlder disables the older versions of the secret that were
+	// replaced more than GracePeriod ago, and sets the expiration date of the
+	// others to the end of their grace period.
+	DisableOlder bool
+
+	// GracePeriod is how long a replaced version stays enabled, so that
+	// anything still using it keeps working until it picks up the new value.
+	GracePeriod time.Duration
+}
+
+// RetainedVersion is an older version that stays enabled during its grace
+// period.
+type RetainedVersion struct {
+	// Version is the ID of the version.
+	Version string `json:"version"`
+
+	// DisableAfter is when its grace period ends.
+	DisableAfter time.Time `json:"disableAfter"`
+}
+
+// Rotation describes a secret that was rotated.
+type Rotation struct {
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// Generator is the name of the generator that created the value.
+	Generator string `json:"generator"`
+
+	// Version is the new current version.
+	Version string `json:"version"`
+
+	// Previous is the version that was current before the rotation.
+	Previous string `json:"previous,omitempty"`
+
+	// Disabled are the older versions that were disabled.
+	Disabled []string `json:"disabled,omitempty"`
+
+	// Retained are the older versions that are still in their grace period.
+	Retained []RetainedVersion `json:"retained,omitempty"`
+
+	// Errors are why older versions could not be disabled or expired.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Rotate creates a new version of the secret that Resolve finds for the key,
+// with a value from the generator, and records the rotation in its tags. The
+// secret is created with the same name as Create uses when it doesn't exist.
+// When DisableOlder is set, the older versions that were replaced longer than
+// the grace period ago are disabled, and the others expire when their grace
+// period ends. Versions that could not be disabled or expired are reported in
+// the rotation, instead of returning an error, because the new value has
+// already been saved.
+func (s *Store) Rotate(ctx context.Context, keyValue string, opts RotateOptions) (Rotation, error) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+	log.SetAttributes(s.names.attrs("requested-secret", keyValue)...)
+
+	if opts.Generator == nil {
+		return Rotation{}, log.Errorf("a generator is required to rotate secret %s", s.names.redact(keyValue))
+	}
+	if opts.GracePeriod < 0 {
+		return Rotation{}, log.Errorf("the grace period %s must not be negative", opts.GracePeriod)
+	}
+	log.SetAttributes(attribute.String("rotate.generator", opts.Generator.Name()))
+
+	if err := s.Connect(ctx); err != nil {
+		return Rotation{}, err
+	}
+
+	namespace := s.namespace()
+	name, versions, err := s.findVersions(ctx, keyValue, operationRotate)
+	if errors.Is(err, ErrSecretNotFound) {
+		name, versions, err = s.secretName(keyValue, namespace), nil, nil
+	}
+	if err != nil {
+		return Rotation{}, log.Errorf("could not list the versions of secret %s: %w", s.names.redact(name), err)
+	}
+
+	generated, err := opts.Generator.Generate(ctx)
+	if err != nil {
+		return Rotation{}, log.Errorf("could not generate a new value for secret %s: %w", s.names.redact(name), err)
+	}
+
+	now := time.Now()
+	rotation := Rotation{Name: name, Generator: opts.Generator.Name()}
+	params := azsecrets.SetSecretParameters{
+		Value:            &generated.Value,
+		SecretAttributes: s.secretAttributes(keyValue, now),
+	}
+	if generated.ContentType != "" {
+		params.ContentType = &generated.ContentType
+	}
+
+	// Keep the tags of the current version, and replace the ones that are
+	// set by the plugin
+	tags := make(map[string]*string)
+	if len(versions) > 0 {
+		current := versions[len(versions)-1]
+		rotation.Previous = current.ID.Version()
+		for k, v := range current.Tags {
+			tags[k] = v
+		}
+		// A content type describes the values of one generator, so it is only
+		// kept when the same generator created the current version
+		if generator := current.Tags[rotationGeneratorTag]; params.ContentType == nil && generator != nil && *generator == rotation.Generator {
+			params.ContentType = current.ContentType
+		}
+	}
+	for _, tag := range pluginTags {
+		delete(tags, tag)
+	}
+	for _, tag := range rotationTags {
+		delete(tags, tag)
+	}
+	for k, v := range s.secretTags(keyValue, namespace) {
+		tags[k] = v
+	}
+	rotatedAt := now.UTC().Format(time.RFC3339)
+	tags[rotatedAtTag] = &rotatedAt
+	tags[rotationGeneratorTag] = &rotation.Generator
+	if rotation.Previous != "" {
+		tags[rotatedFromTag] = &rotation.Previous
+	}
+	params.Tags = s.withContentHash(tags, name, generated.Value)
+
+	start := time.Now()
+	created, err := s.client.SetSecret(ctx, name, params, nil)
+	s.metrics.recordRequest(ctx, operationRotate, s.vaultUrl, false, start, err)
+	s.forgetPrefetched(name)
+	if err != nil {
+		return Rotation{}, log.Errorf("could not rotate secret %s: %w", s.names.redact(name), s.names.redactError(classifyError(err, operationSet, s.vaultUrl)))
+	}
+	if created.ID != nil {
+		rotation.Version = created.ID.Version()
+	}
+
+	// Versions are disabled once they were replaced by a newer version for
+	// longer than the grace period, and the last one was replaced just now
+	for i, version := range versions {
+		if !opts.DisableOlder || !isEnabled(version) {
+			continue
+		}
+		replaced := now
+		if i < len(versions)-1 {
+			replaced = createdAt(versions[i+1])
+		}
+
+		id := version.ID.Version()
+		if disableAfter := replaced.Add(opts.GracePeriod); now.Before(disableAfter) {
+			rotation.Retained = append(rotation.Retained, RetainedVersion{Version: id, DisableAfter: disableAfter.UTC()})
+			// The version expires at the end of its grace period, so that it
+			// stops being used even when nothing rotates the secret again
+			if attrs := version.Attributes; attrs != nil && attrs.Expires != nil && !attrs.Expires.After(disableAfter) {
+				continue
+			}
+			if err := s.updateVersion(ctx, name, id, &azsecrets.SecretAttributes{Expires: &disableAfter}); err != nil {
+				log.Warn(fmt.Sprintf("could not set the expiration date of version %s of secret %s: %s", id, s.names.redact(name), err))
+				rotation.Errors = append(rotation.Errors, fmt.Sprintf("could not set the expiration date of version %s: %s", id, err))
+			}
+			continue
+		}
+		if err := s.updateVersion(ctx, name, id, &azsecrets.SecretAttributes{Enabled: new(bool)}); err != nil {
+			log.Warn(fmt.Sprintf("could not disable version %s of secret %s: %s", id, s.names.redact(name), err))
+			rotation.Errors = append(rotation.Errors, fmt.Sprintf("could not disable version %s: %s", id, err))
+			continue
+		}
+		rotation.Disabled = append(rotation.Disabled, id)
+	}
+
+	log.SetAttributes(
+		attribute.Int("rotate.disabled", len(rotation.Disabled)),
+		attribute.Int("rotate.retained", len(rotation.Retained)),
+	)
+	return rotation, nil
+}
+
+func (s *Store) updateVersion(ctx context.Context, name string, version string, attrs *azsecrets.SecretAttributes) error {
+	params := azsecrets.UpdateSecretPropertiesParameters{SecretAttributes: attrs}
+	start := time.Now()
+	_, err := s.client.UpdateSecretProperties(ctx, name, version, params, nil)
+	s.metrics.recordRequest(ctx, operationRotate, s.vaultUrl, false, start, err)
+	if err != nil {
+		return s.names.redactError(classifyError(err, operationSet, s.vaultUrl))
+	}
+	return nil
+}
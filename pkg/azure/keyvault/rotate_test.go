@@ -0,0 +1,168 @@
+package keyvault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticGenerator returns the same value every time.
+type staticGenerator struct {
+	value       string
+	contentType string
+}
+
+func (g staticGenerator) Name() string {
+	return "static"
+}
+
+func (g staticGenerator) Generate(ctx context.Context) (GeneratedValue, error) {
+	return GeneratedValue{Value: g.value, ContentType: g.contentType}, nil
+}
+
+func TestStore_Rotate(t *testing.T) {
+	ctx := context.Background()
+	generator := staticGenerator{value: "rotated"}
+
+	t.Run("new version", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		team := "a"
+		client.versions["MY-PARAM-value"][2].Tags["team"] = &team
+
+		rotation, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator})
+		require.NoError(t, err)
+		assert.Equal(t, Rotation{Name: "MY-PARAM-value", Generator: "static", Version: "v4", Previous: "v3"}, rotation)
+		assert.Equal(t, "rotated", client.secrets["MY-PARAM-value"])
+
+		tags := client.tags["MY-PARAM-value"]
+		assert.Equal(t, "static", *tags[rotationGeneratorTag])
+		assert.Equal(t, "v3", *tags[rotatedFromTag])
+		assert.Contains(t, tags, rotatedAtTag)
+		assert.Equal(t, "MY_PARAM.value", *tags[keyTag])
+		assert.Equal(t, "a", *tags["team"], "the tags of the current version should be kept")
+		assert.Empty(t, client.updates)
+
+		value, err := store.Resolve(ctx, SecretKeyName, "MY_PARAM.value")
+		require.NoError(t, err)
+		assert.Equal(t, "rotated", value)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		t.Setenv("PORTER_NAMESPACE", "")
+		store, client := newNamespaceStore(azureconfig.Config{NamingStrategy: azureconfig.NamingStrategyNamespacePrefix, Namespace: "dev"})
+
+		rotation, err := store.Rotate(ctx, "password", RotateOptions{Generator: staticGenerator{value: "pem", contentType: pemContentType}})
+		require.NoError(t, err)
+		assert.Equal(t, "dev--password", rotation.Name)
+		assert.Empty(t, rotation.Previous)
+		assert.Equal(t, pemContentType, *client.versions["dev--password"][0].ContentType)
+		assert.NotContains(t, client.tags["dev--password"], rotatedFromTag)
+	})
+
+	t.Run("content type of another generator", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+
+		_, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: RSAGenerator{Bits: 2048}})
+		require.NoError(t, err)
+		_, err = store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: PasswordGenerator{Length: DefaultPasswordLength}})
+		require.NoError(t, err)
+
+		versions := client.versions["MY-PARAM-value"]
+		assert.Equal(t, pemContentType, *versions[3].ContentType)
+		assert.Nil(t, versions[4].ContentType, "the content type of the rsa generator should not be kept")
+	})
+
+	t.Run("content type of the same generator", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+
+		_, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: staticGenerator{value: "pem", contentType: pemContentType}})
+		require.NoError(t, err)
+		_, err = store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator})
+		require.NoError(t, err)
+
+		assert.Equal(t, pemContentType, *client.versions["MY-PARAM-value"][4].ContentType)
+	})
+
+	t.Run("disable older versions", func(t *testing.T) {
+		store, _ := newVersionsStore(t)
+
+		rotation, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator, DisableOlder: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1", "v2", "v3"}, rotation.Disabled)
+		assert.Empty(t, rotation.Retained)
+
+		versions, err := store.Versions(ctx, "MY_PARAM.value")
+		require.NoError(t, err)
+		for _, version := range versions.Versions {
+			assert.Equal(t, version.Current, version.Enabled, "only the current version should be enabled")
+		}
+	})
+
+	t.Run("grace period", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		// The versions were created a second apart, so v1 was replaced a
+		// second before v2
+		gracePeriod := time.Since(testEpoch) - 2500*time.Millisecond
+
+		rotation, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator, DisableOlder: true, GracePeriod: gracePeriod})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1"}, rotation.Disabled)
+		require.Len(t, rotation.Retained, 2)
+		assert.Equal(t, "v2", rotation.Retained[0].Version)
+		assert.Equal(t, testEpoch.Add(3*time.Second).Add(gracePeriod), rotation.Retained[0].DisableAfter)
+		assert.Equal(t, "v3", rotation.Retained[1].Version)
+		assert.Equal(t, []string{"MY-PARAM-value/v1", "MY-PARAM-value/v2", "MY-PARAM-value/v3"}, client.updates)
+
+		versions := client.versions["MY-PARAM-value"]
+		assert.False(t, *versions[0].Attributes.Enabled)
+		assert.Equal(t, rotation.Retained[0].DisableAfter, versions[1].Attributes.Expires.UTC())
+		assert.Equal(t, rotation.Retained[1].DisableAfter, versions[2].Attributes.Expires.UTC())
+	})
+
+	t.Run("grace period keeps an earlier expiration", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		expires := testEpoch.Add(time.Minute)
+		client.versions["MY-PARAM-value"][2].Attributes.Expires = &expires
+
+		rotation, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator, DisableOlder: true, GracePeriod: time.Hour})
+		require.NoError(t, err)
+		require.Len(t, rotation.Retained, 1)
+		assert.Equal(t, "v3", rotation.Retained[0].Version)
+		assert.Equal(t, expires, *client.versions["MY-PARAM-value"][2].Attributes.Expires)
+	})
+
+	t.Run("expiration failed", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		client.errors["MY-PARAM-value/v3"] = newResponseError(http.StatusForbidden, "Forbidden")
+
+		rotation, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator, DisableOlder: true, GracePeriod: time.Hour})
+		require.NoError(t, err)
+		require.Len(t, rotation.Retained, 1)
+		require.Len(t, rotation.Errors, 1)
+		assert.Contains(t, rotation.Errors[0], "could not set the expiration date of version v3")
+	})
+
+	t.Run("disable failed", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		client.errors["MY-PARAM-value/v2"] = newResponseError(http.StatusForbidden, "Forbidden")
+
+		rotation, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{Generator: generator, DisableOlder: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1", "v3"}, rotation.Disabled)
+		require.Len(t, rotation.Errors, 1)
+		assert.Contains(t, rotation.Errors[0], "could not disable version v2")
+		assert.Equal(t, "rotated", client.secrets["MY-PARAM-value"])
+	})
+
+	t.Run("no generator", func(t *testing.T) {
+		store, _ := newVersionsStore(t)
+
+		_, err := store.Rotate(ctx, "MY_PARAM.value", RotateOptions{})
+		require.ErrorContains(t, err, "a generator is required")
+	})
+}
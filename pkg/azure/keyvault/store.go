@@ -3,17 +3,23 @@ package keyvault
 import (
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
 	"get.porter.sh/porter/pkg/secrets/plugins"
 	"get.porter.sh/porter/pkg/secrets/plugins/host"
 	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
 	"github.com/hashicorp/go-hclog"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -29,45 +35,123 @@ type secret struct {
 	version  string
 }
 
+// secretsClient is the subset of azsecrets.Client used by the store, so that
+// it can be replaced in tests.
+type secretsClient interface {
+	GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
+	SetSecret(ctx context.Context, name string, parameters azsecrets.SetSecretParameters, options *azsecrets.SetSecretOptions) (azsecrets.SetSecretResponse, error)
+	NewListSecretPropertiesPager(options *azsecrets.ListSecretPropertiesOptions) *runtime.Pager[azsecrets.ListSecretPropertiesResponse]
+	NewListSecretPropertiesVersionsPager(name string, options *azsecrets.ListSecretPropertiesVersionsOptions) *runtime.Pager[azsecrets.ListSecretPropertiesVersionsResponse]
+	DeleteSecret(ctx context.Context, name string, options *azsecrets.DeleteSecretOptions) (azsecrets.DeleteSecretResponse, error)
+	GetDeletedSecret(ctx context.Context, name string, options *azsecrets.GetDeletedSecretOptions) (azsecrets.GetDeletedSecretResponse, error)
+	PurgeDeletedSecret(ctx context.Context, name string, options *azsecrets.PurgeDeletedSecretOptions) (azsecrets.PurgeDeletedSecretResponse, error)
+	UpdateSecretProperties(ctx context.Context, name string, version string, parameters azsecrets.UpdateSecretPropertiesParameters, options *azsecrets.UpdateSecretPropertiesOptions) (azsecrets.UpdateSecretPropertiesResponse, error)
+}
+
 // Store implements the backing store for secrets in azure key vault.
 type Store struct {
 	logger    hclog.Logger
 	config    azureconfig.Config
 	vaultUrl  string
-	client    *azsecrets.Client
+	client    secretsClient
 	hostStore host.Store
+	names     nameRedactor
+	metrics   storeMetrics
+
+	// clients has a client for every vault that the store has connected to,
+	// keyed by the normalized vault url.
+	clients map[string]secretsClient
+	// creds has the credential for each vault override, keyed by the
+	// normalized vault url. The plugin's own credential has an empty key.
+	creds     map[string]azcore.TokenCredential
+	clientsMu sync.Mutex
+
+	// snapshot has the prefetched secrets from the configured vault, keyed
+	// by the lowercase secret name.
+	snapshot     map[string]azsecrets.GetSecretResponse
+	snapshotMu   sync.RWMutex
+	prefetchOnce sync.Once
 }
 
 func NewStore(cfg azureconfig.Config, l hclog.Logger) *Store {
-	vaultFullLink := cfg.VaultUrl
-	if vaultFullLink == "" {
-		vaultFullLink = fmt.Sprintf("https://%s.vault.azure.net", cfg.Vault)
-	}
-
 	return &Store{
 		config:    cfg,
 		logger:    l,
-		vaultUrl:  vaultFullLink,
+		vaultUrl:  cfg.GetVaultURL(),
 		hostStore: host.NewStore(),
+		names:     newNameRedactor(cfg),
+		metrics:   newStoreMetrics(otel.GetMeterProvider(), l),
+		clients:   make(map[string]secretsClient),
+		creds:     make(map[string]azcore.TokenCredential),
+		snapshot:  make(map[string]azsecrets.GetSecretResponse),
 	}
 }
 
 func (s *Store) Connect(ctx context.Context) error {
-	if s.client != nil {
-		return nil
+	if err := s.checkNamespaceName(); err != nil {
+		return err
 	}
 
-	creds, err := GetCredentials(s.config, s.logger)
+	if s.client == nil {
+		client, err := s.clientFor(s.vaultUrl)
+		if err != nil {
+			return err
+		}
+		s.client = client
+	}
+
+	if s.config.Prefetch.Enabled {
+		s.prefetchOnce.Do(func() { s.prefetch(ctx) })
+	}
+	return nil
+}
+
+// credentialFor returns the configuration and credential used for the
+// specified vault. The caller must hold clientsMu.
+func (s *Store) credentialFor(vaultURL string) (azureconfig.Config, azcore.TokenCredential, error) {
+	cfg, override := s.config.ForVault(vaultURL)
+	if creds, ok := s.creds[override]; ok {
+		return cfg, creds, nil
+	}
+
+	creds, err := GetCredentials(cfg, s.logger)
 	if err != nil {
-		return err
+		return cfg, nil, err
 	}
+	creds = s.metrics.instrumentCredential(markCredentialErrors(creds))
+	s.creds[override] = creds
+	return cfg, creds, nil
+}
+
+// clientFor returns a client for the specified vault. Vaults that have
+// credential overrides in the configuration get their own credential, and
+// every other vault shares the plugin's credential.
+func (s *Store) clientFor(vaultURL string) (secretsClient, error) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
 
-	client, err := azsecrets.NewClient(s.vaultUrl, creds, nil)
+	key := azureconfig.NormalizeVaultURL(vaultURL)
+	if client, ok := s.clients[key]; ok {
+		return client, nil
+	}
+
+	cfg, creds, err := s.credentialFor(vaultURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	s.client = client
-	return nil
+
+	clientOpts, err := newClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &azsecrets.ClientOptions{ClientOptions: clientOpts}
+	client, err := azsecrets.NewClient(vaultURL, creds, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[key] = client
+	return client, nil
 }
 
 func (s *Store) Resolve(ctx context.Context, keyName string, keyValue string) (string, error) {
@@ -77,44 +161,113 @@ func (s *Store) Resolve(ctx context.Context, keyName string, keyValue string) (s
 		return s.hostStore.Resolve(ctx, keyName, keyValue)
 	}
 
-	log.SetAttributes(attribute.String("requested-secret", keyValue))
+	// Options such as a default value are set after a "?" at the end of the key
+	keyValue, opts, err := s.secretOptions(keyValue)
+	if err != nil {
+		return "", log.Error(err)
+	}
+	log.SetAttributes(s.names.attrs("requested-secret", keyValue)...)
 
 	if err := s.Connect(ctx); err != nil {
 		return "", err
 	}
+	namespace := s.namespace()
+
 	// Check if the keyValue is set to a full ID or just the secret name. The keyValue is only considered
 	// an ID if it includes at least the keyvault name and secret name. If version is not part of the ID then the version
 	// is set to "" which will fetch the latest version
-	secret := parseID(ctx, keyValue)
+	secret := parseID(ctx, keyValue, s.names)
+	var idErr error
 	if secret != nil {
-		result, err := s.client.GetSecret(ctx, secret.name, secret.version, nil)
-		if err != nil {
-			// Instead of return error in this case instead log as a debug and attempt to fetch
-			// the secret from the configured secret store. Only return error if the secret is unable
-			// to be resolved in both ways
-			log.Debug(fmt.Sprintf("could not get secret %s by ID: %s", keyValue, err.Error()))
-		} else {
+		result, err := s.getSecretByID(ctx, secret)
+		if err == nil {
+			err = s.checkSecret(secret.name, result, namespace)
+		}
+		if err == nil {
 			// If we were able to look it up based off of the parsed ID then return that immediately
 			return *result.Value, nil
 		}
+
+		idErr = fmt.Errorf("could not get secret %s by ID: %w", s.names.redact(keyValue), err)
+		switch s.config.GetSecretIDFallback() {
+		case azureconfig.SecretIDFallbackStrict:
+			return opts.orDefault(log, idErr)
+		case azureconfig.SecretIDFallbackWarn:
+			log.Warn(fmt.Sprintf("%s, trying it as a secret name in %s", idErr, s.vaultUrl))
+		default:
+			// Attempt to fetch the secret from the configured secret store. Only return error if the
+			// secret is unable to be resolved in both ways
+			log.Debug(idErr.Error())
+		}
 	}
 
-	secretName := cleanSecretName(keyValue)
-	log.SetAttributes(attribute.String("cleaned-secret", secretName))
+	var secretName string
+	var result azsecrets.GetSecretResponse
+	for _, secretName = range s.secretNames(keyValue, namespace) {
+		log.SetAttributes(s.names.attrs("cleaned-secret", secretName)...)
 
-	secretVersion := ""
-	result, err := s.client.GetSecret(ctx, secretName, secretVersion, nil)
+		result, err = s.getSecret(ctx, secretName)
+		if !errors.Is(err, ErrSecretNotFound) {
+			break
+		}
+	}
+	if err == nil {
+		err = s.checkSecret(secretName, result, namespace)
+	}
 	if err != nil {
 		if keyValue != secretName {
 			// Help everyone out by printing the original value that we used to generate the secret name
-			return "", log.Errorf("could not get secret %s (original name was %s): %w", secretName, keyValue, err)
+			err = fmt.Errorf("could not get secret %s (original name was %s): %w", s.names.redact(secretName), s.names.redact(keyValue), err)
+		} else {
+			err = fmt.Errorf("could not get secret %s: %w", s.names.redact(secretName), err)
 		}
-		return "", log.Errorf("could not get secret %s: %w", secretName, err)
+
+		// Report why the ID lookup failed too, it is usually the more useful error
+		return opts.orDefault(log, idErr, err)
 	}
 
 	return *result.Value, nil
 }
 
+// checkSecret returns an error when the secret may not be used, because it
+// belongs to another namespace or isn't active.
+func (s *Store) checkSecret(name string, result azsecrets.GetSecretResponse, namespace string) error {
+	if err := s.checkNamespace(name, result.Tags, namespace); err != nil {
+		return err
+	}
+	return s.checkActive(name, result.Attributes, time.Now())
+}
+
+// getSecret gets the latest version of the secret from the configured vault.
+// Prefetched secrets are returned from the snapshot, and any other secret is
+// requested from the vault.
+func (s *Store) getSecret(ctx context.Context, name string) (azsecrets.GetSecretResponse, error) {
+	start := time.Now()
+	if result, ok := s.prefetched(name); ok {
+		s.metrics.recordRequest(ctx, operationResolve, s.vaultUrl, true, start, nil)
+		return result, nil
+	}
+
+	secretVersion := ""
+	result, err := s.client.GetSecret(ctx, name, secretVersion, nil)
+	s.metrics.recordRequest(ctx, operationResolve, s.vaultUrl, false, start, err)
+	return result, s.names.redactError(classifyError(err, operationGet, s.vaultUrl))
+}
+
+// getSecretByID gets the secret from the vault in its ID, which may not be the
+// vault in the plugin configuration.
+func (s *Store) getSecretByID(ctx context.Context, secret *secret) (azsecrets.GetSecretResponse, error) {
+	client, err := s.clientFor(secret.vaultURL)
+	if err != nil {
+		return azsecrets.GetSecretResponse{}, err
+	}
+
+	start := time.Now()
+	result, err := client.GetSecret(ctx, secret.name, secret.version, nil)
+	s.metrics.recordRequest(ctx, operationResolve, secret.vaultURL, false, start, err)
+	return result, s.names.redactError(classifyError(err, operationGet, secret.vaultURL))
+}
+
 // Matches any invalid characters in an Azure Key Vault name so that we can replace it with something allowed
 var keyVaultNameInvalidCharacters = regexp.MustCompile(`[^a-zA-Z0-9-]`)
 
@@ -127,15 +280,16 @@ var keyVaultNameInvalidCharacters = regexp.MustCompile(`[^a-zA-Z0-9-]`)
 // than Azure Key Vault, which only allows alphanumeric characters and hyphens.
 // Example: MY_SECRET is converted to MY-SECRET when read/written to key vault
 // or INSTALLATION-ID-LONG-SECRET-NAME is converted to INSTALLATION-ID-CLEAN_SECRET_PREFIX-MD5SUM
-func cleanSecretName(name string) string {
+// The second result is true when the name was shortened with the hash.
+func cleanSecretName(name string) (string, bool) {
 	cleanName := keyVaultNameInvalidCharacters.ReplaceAllString(name, "-")
 	if len(cleanName) > 127 {
 		// If the name is too long, hash the original and append the hash to as much of the name as we can preserve
 		nameHash := fmt.Sprintf("%X", md5.Sum([]byte(name)))
-		cleanName = cleanName[:94] + "-" + nameHash
+		return cleanName[:94] + "-" + nameHash, true
 	}
 
-	return cleanName
+	return cleanName, false
 }
 
 // Create saves the secret to azure's keyvault using the keyValue as the
@@ -150,38 +304,113 @@ func (s *Store) Create(ctx context.Context, keyName string, keyValue string, val
 		return log.Errorf("unsupported secret type: %s. Only %s is supported", keyName, SecretKeyName)
 	}
 
-	secretName := cleanSecretName(keyValue)
-	log.SetAttributes(
-		attribute.String("requested-secret", keyValue),
-		attribute.String("cleaned-secret", secretName))
+	// Options only apply when resolving the secret, so save it under the
+	// same name that it's resolved from
+	keyValue, _, err := s.secretOptions(keyValue)
+	if err != nil {
+		return log.Error(err)
+	}
+
+	namespace := s.namespace()
+	secretName := s.secretName(keyValue, namespace)
+	log.SetAttributes(s.names.attrs("requested-secret", keyValue)...)
+	log.SetAttributes(s.names.attrs("cleaned-secret", secretName)...)
 
 	if err := s.Connect(ctx); err != nil {
 		return err
 	}
 
-	_, err := s.client.SetSecret(ctx, secretName, azsecrets.SetSecretParameters{Value: &value}, nil)
+	_, err = s.writeSecret(ctx, log, secretWrite{name: secretName, key: keyValue, namespace: namespace, value: value})
 	if err != nil {
 		if keyValue != secretName {
 			// Help everyone out by printing the original value that we used to generate the secret name
-			return log.Errorf("failed to set secret %s (original name was %s): %w", secretName, keyValue, err)
+			return log.Errorf("failed to set secret %s (original name was %s): %w", s.names.redact(secretName), s.names.redact(keyValue), err)
 		}
-		return log.Errorf("failed to set secret %s in azure-keyvault: %w", secretName, err)
+		return log.Errorf("failed to set secret %s in azure-keyvault: %w", s.names.redact(secretName), err)
 	}
 	return nil
 }
 
+// secretWrite is a new version of a secret, for a key in a namespace.
+type secretWrite struct {
+	name        string
+	key         string
+	namespace   string
+	value       string
+	contentType string
+
+	// tags are set along with the tags that the plugin sets, which replace
+	// them, for example the other tags of an imported secret.
+	tags map[string]*string
+}
+
+// writeSecret saves a new version of the secret, tagged the same way for
+// every secret that the plugin saves. The write is skipped when skip-unchanged
+// finds that the current version already has the value, content type and
+// tags, and true is returned.
+func (s *Store) writeSecret(ctx context.Context, log tracing.TraceLogger, w secretWrite) (bool, error) {
+	tags := make(map[string]*string, len(w.tags)+len(pluginTags))
+	for k, v := range w.tags {
+		tags[k] = v
+	}
+	for _, tag := range pluginTags {
+		delete(tags, tag)
+	}
+	for k, v := range s.secretTags(w.key, w.namespace) {
+		tags[k] = v
+	}
+	tags = s.withContentHash(tags, w.name, w.value)
+
+	skip, reason, version := s.checkUnchanged(ctx, w.name, w.value, w.contentType, tags)
+	if skip {
+		if attrs := s.secretAttributes(w.key, time.Now()); attrs != nil && attrs.Expires != nil {
+			if err := s.extendExpiration(ctx, w.name, version, *attrs.Expires); err != nil {
+				// Write the secret again instead, which sets the new expiration date
+				log.Debug(fmt.Sprintf("could not extend the expiration date of secret %s, writing it again: %s", s.names.redact(w.name), err))
+				skip, reason = false, writeReasonExtendFailed
+			} else {
+				reason = writeReasonExtended
+			}
+		}
+	}
+	log.SetAttributes(attribute.Bool("write.skipped", skip), attribute.String("write.reason", reason))
+	if skip {
+		log.Debug(fmt.Sprintf("skipped writing secret %s because its value, content type and tags are unchanged", s.names.redact(w.name)))
+		return true, nil
+	}
+
+	params := azsecrets.SetSecretParameters{
+		Value:            &w.value,
+		Tags:             tags,
+		SecretAttributes: s.secretAttributes(w.key, time.Now()),
+	}
+	if w.contentType != "" {
+		params.ContentType = &w.contentType
+	}
+	start := time.Now()
+	_, err := s.client.SetSecret(ctx, w.name, params, nil)
+	s.metrics.recordRequest(ctx, operationCreate, s.vaultUrl, false, start, err)
+	s.forgetPrefetched(w.name)
+	if err != nil {
+		return false, s.names.redactError(classifyError(err, operationSet, s.vaultUrl))
+	}
+	return false, nil
+}
+
 // parseID will attempt to create a secret from an id. If the id is not valid then
-// it will log a debug and return nil. This code was mainly copied from the azure keyvault internal library:
+// it will log a debug and return nil. The id is recorded using the redactor, like any
+// other secret name. This code was mainly copied from the azure keyvault internal library:
 // https://github.com/Azure/azure-sdk-for-go/blob/main/sdk/keyvault/internal/parse.go
-func parseID(ctx context.Context, id string) *secret {
-	_, log := tracing.StartSpan(ctx, attribute.String("parsing secret as ID", id))
+func parseID(ctx context.Context, id string, names nameRedactor) *secret {
+	_, log := tracing.StartSpan(ctx, names.attrs("parsing secret as ID", id)...)
+	defer log.EndSpan()
 	if id == "" {
 		log.Debug("unable to parse empty ID")
 		return nil
 	}
 	parsed, err := url.Parse(id)
 	if err != nil {
-		log.Debug(fmt.Sprintf("Unable to parse %s as secret ID: %s", id, err.Error()))
+		log.Debug(fmt.Sprintf("Unable to parse %s as secret ID: %s", names.redact(id), err.Error()))
 		return nil
 	}
 	url := fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host)
@@ -195,7 +424,7 @@ func parseID(ctx context.Context, id string) *secret {
 				version:  "",
 			}
 		}
-		log.Debug(fmt.Sprintf("Unexpected ID format found for %s, unable to parse as secret ID", id))
+		log.Debug(fmt.Sprintf("Unexpected ID format found for %s, unable to parse as secret ID", names.redact(id)))
 		return nil
 	}
 	return &secret{
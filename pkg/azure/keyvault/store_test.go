@@ -3,6 +3,7 @@ package keyvault
 import (
 	"context"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"testing"
 
@@ -173,7 +174,7 @@ func TestParseKeyValueAsSecretID(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			ctx := context.Background()
-			got := parseID(ctx, test.keyValue)
+			got := parseID(ctx, test.keyValue, nameRedactor{})
 			require.Equal(t, test.exp, got)
 		})
 	}
@@ -195,8 +196,82 @@ func TestCleanSecretName(t *testing.T) {
 
 	for input, wantOutput := range testcases {
 		t.Run(input, func(t *testing.T) {
-			gotOutput := cleanSecretName(input)
+			gotOutput, _ := cleanSecretName(input)
 			assert.Equal(t, wantOutput, gotOutput, "Invalid clean name %s for %s, expected %s", gotOutput, input, wantOutput)
 		})
 	}
 }
+
+func TestResolve_SecretID(t *testing.T) {
+	ctx := context.Background()
+	logger := hclog.New(&loggerOpts)
+
+	store := NewStore(azureconfig.Config{Vault: "myvault"}, logger)
+	configuredVault := newTestClient()
+	configuredVault.secrets["my-secret"] = "configured"
+	otherVault := newTestClient()
+	otherVault.secrets["my-secret"] = "other"
+	withTestClients(store, map[string]*testClient{
+		"https://myvault.vault.azure.net":    configuredVault,
+		"https://othervault.vault.azure.net": otherVault,
+	})
+
+	t.Run("secret in the vault from the ID", func(t *testing.T) {
+		resolved, err := store.Resolve(ctx, SecretKeyName, "https://othervault.vault.azure.net/secrets/my-secret")
+		require.NoError(t, err)
+		assert.Equal(t, "other", resolved)
+	})
+
+	t.Run("secret name in the configured vault", func(t *testing.T) {
+		resolved, err := store.Resolve(ctx, SecretKeyName, "my-secret")
+		require.NoError(t, err)
+		assert.Equal(t, "configured", resolved)
+	})
+}
+
+func TestResolve_SecretIDFallback(t *testing.T) {
+	ctx := context.Background()
+	const secretID = "https://othervault.vault.azure.net/secrets/my-secret"
+
+	newStore := func(policy string) *Store {
+		store := NewStore(azureconfig.Config{Vault: "myvault", SecretIDFallback: policy}, hclog.New(&loggerOpts))
+		configuredVault := newTestClient()
+		name, _ := cleanSecretName(secretID)
+		configuredVault.secrets[name] = "fallback"
+		otherVault := newTestClient()
+		otherVault.errors["my-secret"] = newResponseError(http.StatusForbidden, "Forbidden")
+		withTestClients(store, map[string]*testClient{
+			"https://myvault.vault.azure.net":    configuredVault,
+			"https://othervault.vault.azure.net": otherVault,
+		})
+		return store
+	}
+
+	for _, policy := range []string{"", azureconfig.SecretIDFallbackFallback, azureconfig.SecretIDFallbackWarn} {
+		t.Run("fallback with policy "+policy, func(t *testing.T) {
+			resolved, err := newStore(policy).Resolve(ctx, SecretKeyName, secretID)
+			require.NoError(t, err)
+			assert.Equal(t, "fallback", resolved)
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		store := newStore(azureconfig.SecretIDFallbackStrict)
+		_, err := store.Resolve(ctx, SecretKeyName, secretID)
+		require.ErrorIs(t, err, ErrForbidden)
+		assert.Contains(t, err.Error(), "could not get secret "+secretID+" by ID")
+		assert.Empty(t, store.client.(*testClient).gets, "the configured vault should not be used")
+	})
+
+	t.Run("both lookups fail", func(t *testing.T) {
+		store := newStore(azureconfig.SecretIDFallbackFallback)
+		name, _ := cleanSecretName(secretID)
+		delete(store.client.(*testClient).secrets, name)
+
+		_, err := store.Resolve(ctx, SecretKeyName, secretID)
+		require.ErrorIs(t, err, ErrForbidden, "the ID lookup error should be reported")
+		require.ErrorIs(t, err, ErrSecretNotFound, "the fallback error should be reported")
+		assert.Contains(t, err.Error(), "by ID")
+		assert.Contains(t, err.Error(), "original name was "+secretID)
+	})
+}
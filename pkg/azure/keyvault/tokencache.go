@@ -0,0 +1,315 @@
+package keyvault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// tokenCacheVersion is incremented when the format of the cache file changes.
+	tokenCacheVersion = 1
+
+	// tokenExpiryBuffer is how long before it expires that a cached token is
+	// no longer used, so that a request doesn't fail with an expired token.
+	tokenExpiryBuffer = 5 * time.Minute
+
+	// tokenCacheLockTimeout is how long a plugin process waits for another
+	// process to finish saving the cache.
+	tokenCacheLockTimeout = 5 * time.Second
+
+	// tokenCacheStaleLock is how old a lock file must be before it is
+	// considered to be left behind by a process that exited while saving.
+	tokenCacheStaleLock = 30 * time.Second
+)
+
+// Files that identify the machine, used to derive the default encryption key.
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// tokenCacheFile is the format of the cache file on disk.
+type tokenCacheFile struct {
+	Version int    `json:"version"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// cachedToken is an access token, keyed in the cache by the credential and
+// token request that it was issued for.
+type cachedToken struct {
+	Token     string    `json:"token"`
+	ExpiresOn time.Time `json:"expiresOn"`
+}
+
+// tokenCache is a credential that reuses unexpired access tokens from an
+// encrypted file, which is shared by every plugin process on the machine.
+type tokenCache struct {
+	cred      azcore.TokenCredential
+	logger    hclog.Logger
+	path      string
+	key       []byte
+	partition string
+
+	mu sync.Mutex
+}
+
+// newTokenCache wraps the credential with the configured token cache. The
+// partition identifies the credential so that tokens issued to one identity
+// are never returned for another.
+func newTokenCache(cfg azureconfig.Config, cred azcore.TokenCredential, partition string, l hclog.Logger) (*tokenCache, error) {
+	path := cfg.TokenCache.Path
+	if path == "" {
+		porterHome, err := getPorterHome()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(porterHome, "plugins", "azure", "cache", "tokens.json")
+	}
+
+	keyMaterial := cfg.TokenCache.Key
+	if keyMaterial == "" {
+		keyMaterial = machineKeyMaterial()
+	}
+	key := sha256.Sum256([]byte("porter-azure-token-cache:" + keyMaterial))
+
+	return &tokenCache{
+		cred:      cred,
+		logger:    l,
+		path:      path,
+		key:       key[:],
+		partition: partition,
+	}, nil
+}
+
+func (c *tokenCache) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	// Claims are sent when a token was rejected, so a new token is required
+	if opts.Claims != "" {
+		return c.cred.GetToken(ctx, opts)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cacheKey := c.cacheKey(opts)
+	tokens := c.load()
+	if cached, ok := tokens[cacheKey]; ok && time.Now().Add(tokenExpiryBuffer).Before(cached.ExpiresOn) {
+		c.logger.Debug("using cached access token", "expiresOn", cached.ExpiresOn)
+		return azcore.AccessToken{Token: cached.Token, ExpiresOn: cached.ExpiresOn}, nil
+	}
+
+	token, err := c.cred.GetToken(ctx, opts)
+	if err != nil {
+		return token, err
+	}
+
+	// The cache is an optimization, so don't fail when it can't be saved
+	if err := c.store(cacheKey, cachedToken{Token: token.Token, ExpiresOn: token.ExpiresOn}); err != nil {
+		c.logger.Warn("could not save the token cache", "path", c.path, "error", err)
+	}
+
+	return token, nil
+}
+
+// store adds the token to the cache file. Other plugin processes may have
+// saved tokens since the cache was loaded, so the file is read again while
+// holding the lock, instead of overwriting their tokens.
+func (c *tokenCache) store(cacheKey string, token cachedToken) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tokens := c.load()
+	// Drop expired tokens so that the file doesn't grow forever
+	for k, t := range tokens {
+		if time.Now().After(t.ExpiresOn) {
+			delete(tokens, k)
+		}
+	}
+	tokens[cacheKey] = token
+	return c.save(tokens)
+}
+
+// lock prevents other plugin processes from saving the cache, by creating a
+// lock file next to it. The returned function releases the lock.
+func (c *tokenCache) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return nil, err
+	}
+
+	lockPath := c.path + ".lock"
+	deadline := time.Now().Add(tokenCacheLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > tokenCacheStaleLock {
+			c.logger.Debug("removing a stale token cache lock", "path", lockPath)
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("timed out waiting for another process to release %s", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// cacheKey identifies a token by the credential and the request options.
+func (c *tokenCache) cacheKey(opts policy.TokenRequestOptions) string {
+	h := sha256.New()
+	h.Write([]byte(c.partition))
+	h.Write([]byte{0})
+	h.Write([]byte(opts.TenantID))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(opts.Scopes, " ")))
+	if opts.EnableCAE {
+		h.Write([]byte{0, 1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// load reads the cached tokens. A missing or unreadable cache is treated as
+// empty, and is replaced the next time that a token is saved.
+func (c *tokenCache) load() map[string]cachedToken {
+	tokens := make(map[string]cachedToken)
+
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.logger.Debug("could not read the token cache", "path", c.path, "error", err)
+		}
+		return tokens
+	}
+
+	var file tokenCacheFile
+	if err := json.Unmarshal(b, &file); err != nil || file.Version != tokenCacheVersion {
+		c.logger.Debug("ignoring a token cache with an unsupported format", "path", c.path)
+		return tokens
+	}
+
+	gcm, err := c.newGCM()
+	if err != nil {
+		return tokens
+	}
+	data, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		c.logger.Debug("could not decrypt the token cache, it was encrypted with a different key", "path", c.path)
+		return tokens
+	}
+
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		c.logger.Debug("could not parse the token cache", "path", c.path, "error", err)
+		return make(map[string]cachedToken)
+	}
+	return tokens
+}
+
+// save encrypts and writes the tokens. The file is replaced atomically so
+// that another plugin process never reads a partially written cache. The
+// caller must hold the lock.
+func (c *tokenCache) save(tokens map[string]cachedToken) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := c.newGCM()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(tokenCacheFile{
+		Version: tokenCacheVersion,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, data, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tokens-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+func (c *tokenCache) newGCM() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// getPorterHome returns the directory where Porter stores its data.
+func getPorterHome() (string, error) {
+	if home := os.Getenv("PORTER_HOME"); home != "" {
+		return home, nil
+	}
+
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "could not determine PORTER_HOME")
+	}
+	return filepath.Join(userHome, ".porter"), nil
+}
+
+// machineKeyMaterial identifies the current machine and user, and is used to
+// derive the cache encryption key when one isn't configured.
+func machineKeyMaterial() string {
+	var parts []string
+	for _, f := range machineIDFiles {
+		if id, err := os.ReadFile(f); err == nil {
+			parts = append(parts, strings.TrimSpace(string(id)))
+			break
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		parts = append(parts, hostname)
+	}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid, u.Username, u.HomeDir)
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,164 @@
+package keyvault
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenCache(t *testing.T) {
+	ctx := context.Background()
+	logger := hclog.New(&loggerOpts)
+	cachePath := filepath.Join(t.TempDir(), "tokens.json")
+	cfg := azureconfig.Config{TokenCache: azureconfig.TokenCacheConfig{Enabled: true, Path: cachePath}}
+	opts := policy.TokenRequestOptions{Scopes: []string{"https://vault.azure.net/.default"}}
+
+	// newProcess simulates a new plugin process, with nothing in memory
+	newProcess := func(t *testing.T, cfg azureconfig.Config, partition string, token azcore.AccessToken) (*tokenCache, *testCredential) {
+		inner := &testCredential{token: token}
+		cache, err := newTokenCache(cfg, inner, partition, logger)
+		require.NoError(t, err)
+		return cache, inner
+	}
+	validToken := azcore.AccessToken{Token: "token1", ExpiresOn: time.Now().Add(time.Hour).Round(time.Second)}
+
+	cache, inner := newProcess(t, cfg, "identity1", validToken)
+	token, err := cache.GetToken(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token.Token)
+	assert.Equal(t, 1, inner.calls)
+
+	b, err := os.ReadFile(cachePath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "token1", "the cache should be encrypted")
+
+	t.Run("reused by another process", func(t *testing.T) {
+		cache, inner := newProcess(t, cfg, "identity1", azcore.AccessToken{Token: "token2"})
+		token, err := cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "token1", token.Token)
+		assert.True(t, validToken.ExpiresOn.Equal(token.ExpiresOn))
+		assert.Equal(t, 0, inner.calls)
+	})
+
+	t.Run("not shared with another identity", func(t *testing.T) {
+		cache, inner := newProcess(t, cfg, "identity2", validToken)
+		_, err := cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("not shared with other scopes", func(t *testing.T) {
+		cache, inner := newProcess(t, cfg, "identity1", validToken)
+		_, err := cache.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("claims bypass the cache", func(t *testing.T) {
+		cache, inner := newProcess(t, cfg, "identity1", validToken)
+		_, err := cache.GetToken(ctx, policy.TokenRequestOptions{Scopes: opts.Scopes, Claims: "challenge"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("different key", func(t *testing.T) {
+		otherCfg := cfg
+		otherCfg.TokenCache.Key = "another key"
+		cache, inner := newProcess(t, otherCfg, "identity1", validToken)
+		_, err := cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, inner.calls, "the cache should not be readable with a different key")
+	})
+
+	t.Run("token about to expire", func(t *testing.T) {
+		expiringPath := filepath.Join(t.TempDir(), "tokens.json")
+		expiringCfg := azureconfig.Config{TokenCache: azureconfig.TokenCacheConfig{Enabled: true, Path: expiringPath}}
+		expiring := azcore.AccessToken{Token: "expiring", ExpiresOn: time.Now().Add(time.Minute)}
+
+		cache, _ := newProcess(t, expiringCfg, "identity1", expiring)
+		_, err := cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+
+		cache, inner := newProcess(t, expiringCfg, "identity1", validToken)
+		token, err := cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "token1", token.Token)
+		assert.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("corrupt cache", func(t *testing.T) {
+		corruptPath := filepath.Join(t.TempDir(), "tokens.json")
+		require.NoError(t, os.WriteFile(corruptPath, []byte("garbage"), 0600))
+		corruptCfg := azureconfig.Config{TokenCache: azureconfig.TokenCacheConfig{Enabled: true, Path: corruptPath}}
+
+		cache, inner := newProcess(t, corruptCfg, "identity1", validToken)
+		token, err := cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "token1", token.Token)
+		assert.Equal(t, 1, inner.calls)
+	})
+}
+
+func TestTokenCache_ConcurrentProcesses(t *testing.T) {
+	ctx := context.Background()
+	logger := hclog.New(&loggerOpts)
+	cachePath := filepath.Join(t.TempDir(), "tokens.json")
+	cfg := azureconfig.Config{TokenCache: azureconfig.TokenCacheConfig{Enabled: true, Path: cachePath}}
+	opts := policy.TokenRequestOptions{Scopes: []string{"https://vault.azure.net/.default"}}
+	token := azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}
+
+	t.Run("tokens of other processes are kept", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			cache, err := newTokenCache(cfg, &testCredential{token: token}, fmt.Sprintf("identity%d", i), logger)
+			require.NoError(t, err)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cache.GetToken(ctx, opts)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		cache, err := newTokenCache(cfg, &testCredential{}, "", logger)
+		require.NoError(t, err)
+		assert.Len(t, cache.load(), 10, "every process should have saved its token")
+		assert.NoFileExists(t, cachePath+".lock", "the lock should be released")
+	})
+
+	t.Run("stale lock", func(t *testing.T) {
+		lockPath := cachePath + ".lock"
+		require.NoError(t, os.WriteFile(lockPath, nil, 0600))
+		old := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(lockPath, old, old))
+
+		cache, err := newTokenCache(cfg, &testCredential{token: token}, "identity-stale", logger)
+		require.NoError(t, err)
+		_, err = cache.GetToken(ctx, opts)
+		require.NoError(t, err)
+		assert.Len(t, cache.load(), 11)
+		assert.NoFileExists(t, lockPath)
+	})
+}
+
+func TestNewTokenCache_DefaultPath(t *testing.T) {
+	porterHome := t.TempDir()
+	t.Setenv("PORTER_HOME", porterHome)
+
+	cache, err := newTokenCache(azureconfig.Config{}, &testCredential{}, "", hclog.New(&loggerOpts))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(porterHome, "plugins", "azure", "cache", "tokens.json"), cache.path)
+}
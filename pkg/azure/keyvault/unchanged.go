@@ -0,0 +1,167 @@
+package keyvault
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// contentHashTag is the tag that records the hash of a secret's value, when
+// skip-unchanged is content-hash.
+const contentHashTag = "porter-content-hash"
+
+// Reasons recorded in the trace for why a write was made or skipped.
+const (
+	writeReasonDisabled    = "disabled"
+	writeReasonUnavailable = "current-unavailable"
+	writeReasonChanged     = "changed"
+	writeReasonTagsChanged = "tags-changed"
+	writeReasonTypeChanged = "content-type-changed"
+	writeReasonInactive    = "inactive"
+	writeReasonUnchanged   = "unchanged"
+
+	// The write of an unchanged secret was skipped, and the expiration date
+	// of the current version was moved forward instead
+	writeReasonExtended = "expiration-extended"
+
+	// The expiration date couldn't be moved forward, so the secret was
+	// written again
+	writeReasonExtendFailed = "extend-failed"
+)
+
+// contentHash returns the hash of the value that is recorded in the content
+// hash tag. The tag can be read by anyone who can list the secrets, so the
+// hash is keyed with the content-hash-key, to stop them from testing guesses
+// of the value against it. The secret name is included so that secrets with
+// the same value don't have the same hash.
+func contentHash(key string, name string, value string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.ToLower(name) + "\x00" + value))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// withContentHash adds the content hash tag to the tags, when skip-unchanged
+// is content-hash.
+func (s *Store) withContentHash(tags map[string]*string, name string, value string) map[string]*string {
+	if s.config.SkipUnchanged != azureconfig.SkipUnchangedContentHash {
+		return tags
+	}
+
+	if tags == nil {
+		tags = make(map[string]*string, 1)
+	}
+	hash := contentHash(s.config.ContentHashKey, name, value)
+	tags[contentHashTag] = &hash
+	return tags
+}
+
+// checkUnchanged decides whether writing the secret can be skipped, because
+// the current version already has the value, content type and tags. The
+// reason for the decision is returned so that it can be recorded in the
+// trace, along with the current version, so that its expiration date can be
+// extended.
+func (s *Store) checkUnchanged(ctx context.Context, name string, value string, contentType string, tags map[string]*string) (bool, string, string) {
+	if s.config.SkipUnchanged == "" {
+		return false, writeReasonDisabled, ""
+	}
+
+	current, ok := s.prefetched(name)
+	if !ok {
+		var err error
+		if s.config.SkipUnchanged == azureconfig.SkipUnchangedContentHash {
+			current.Secret, err = s.currentProperties(ctx, name)
+		} else {
+			start := time.Now()
+			current, err = s.client.GetSecret(ctx, name, "", nil)
+			s.metrics.recordRequest(ctx, operationCompare, s.vaultUrl, false, start, err)
+		}
+		if err != nil {
+			// The secret doesn't exist yet, or can't be read, so write it
+			return false, writeReasonUnavailable, ""
+		}
+	}
+
+	if s.checkActive(name, current.Attributes, time.Now()) != nil {
+		return false, writeReasonInactive, ""
+	}
+
+	// With content-hash, the hash is one of the tags so comparing the tags
+	// compares the value too
+	if s.config.SkipUnchanged == azureconfig.SkipUnchangedValue {
+		if current.Value == nil || *current.Value != value {
+			return false, writeReasonChanged, ""
+		}
+	}
+
+	for tag, want := range tags {
+		got, ok := current.Tags[tag]
+		if !ok || got == nil || want == nil || *got != *want {
+			if tag == contentHashTag {
+				return false, writeReasonChanged, ""
+			}
+			return false, writeReasonTagsChanged, ""
+		}
+	}
+
+	var currentType string
+	if current.ContentType != nil {
+		currentType = *current.ContentType
+	}
+	if currentType != contentType {
+		return false, writeReasonTypeChanged, ""
+	}
+
+	var version string
+	if current.ID != nil {
+		version = current.ID.Version()
+	}
+	return true, writeReasonUnchanged, version
+}
+
+// currentProperties returns the tags, content type and attributes of the
+// current version of the secret, without reading its value, by listing its
+// versions. The current version is the newest, and when it can't be told
+// apart from another version created in the same second, or is disabled, an
+// error is returned so that the secret is written.
+func (s *Store) currentProperties(ctx context.Context, name string) (azsecrets.Secret, error) {
+	versions, err := s.listVersions(ctx, s.client, s.vaultUrl, name, operationCompare)
+	if err != nil {
+		return azsecrets.Secret{}, err
+	}
+	if len(versions) == 0 {
+		return azsecrets.Secret{}, ErrSecretNotFound
+	}
+
+	newest := versions[len(versions)-1]
+	if len(versions) > 1 && createdAt(versions[len(versions)-2]).Equal(createdAt(newest)) {
+		return azsecrets.Secret{}, errors.New("the current version was created in the same second as another version")
+	}
+	if !isEnabled(newest) {
+		return azsecrets.Secret{}, errors.New("the current version is disabled")
+	}
+	return azsecrets.Secret{ID: newest.ID, ContentType: newest.ContentType, Tags: newest.Tags, Attributes: newest.Attributes}, nil
+}
+
+// extendExpiration moves the expiration date of an unchanged secret forward,
+// as if it had been written again, so that skipping the write doesn't let
+// the secret expire while it is still being saved.
+func (s *Store) extendExpiration(ctx context.Context, name string, version string, expires time.Time) error {
+	params := azsecrets.UpdateSecretPropertiesParameters{
+		SecretAttributes: &azsecrets.SecretAttributes{Expires: &expires},
+	}
+	start := time.Now()
+	_, err := s.client.UpdateSecretProperties(ctx, name, version, params, nil)
+	s.metrics.recordRequest(ctx, operationExtend, s.vaultUrl, false, start, err)
+	s.forgetPrefetched(name)
+	if err != nil {
+		return s.names.redactError(classifyError(err, operationSet, s.vaultUrl))
+	}
+	return nil
+}
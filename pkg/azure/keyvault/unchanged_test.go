@@ -0,0 +1,151 @@
+package keyvault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testContentHashKey is the content-hash-key used by the tests.
+const testContentHashKey = "a-long-random-value"
+
+func newSkipUnchangedStore(cfg azureconfig.Config) (*Store, *testClient) {
+	cfg.Vault = "myvault"
+	cfg.ContentHashKey = testContentHashKey
+	store := NewStore(cfg, hclog.New(&loggerOpts))
+	client := newTestClient()
+	withTestClients(store, map[string]*testClient{"https://myvault.vault.azure.net": client})
+	return store, client
+}
+
+func TestStore_Create_SkipUnchanged(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("PORTER_NAMESPACE", "")
+
+	t.Run("disabled", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{})
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 2)
+		assert.Empty(t, client.gets, "the current value should not be read")
+	})
+
+	for _, mode := range []string{azureconfig.SkipUnchangedValue, azureconfig.SkipUnchangedContentHash} {
+		t.Run(mode, func(t *testing.T) {
+			store, client := newSkipUnchangedStore(azureconfig.Config{SkipUnchanged: mode})
+
+			require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+			require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+			assert.Len(t, client.sets, 1, "the unchanged value should not be written again")
+
+			require.NoError(t, store.Create(ctx, SecretKeyName, "password", "changed"))
+			assert.Len(t, client.sets, 2, "the changed value should be written")
+			assert.Equal(t, "changed", client.secrets["password"])
+		})
+	}
+
+	t.Run("content hash doesn't read the value", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{SkipUnchanged: azureconfig.SkipUnchangedContentHash})
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 1, "the unchanged value should not be written again")
+		assert.Empty(t, client.gets, "the hash should be compared from the properties of the secret")
+	})
+
+	t.Run("content hash of versions created in the same second", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{SkipUnchanged: azureconfig.SkipUnchangedContentHash})
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "other"))
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		for _, v := range client.versions["password"] {
+			v.Attributes.Created = to.Ptr(testEpoch)
+		}
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 3, "the secret should be written when the current version is unknown")
+	})
+
+	for _, mode := range []string{azureconfig.SkipUnchangedValue, azureconfig.SkipUnchangedContentHash} {
+		t.Run(mode+" content type changed", func(t *testing.T) {
+			store, client := newSkipUnchangedStore(azureconfig.Config{SkipUnchanged: mode})
+			require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+			client.versions["password"][0].ContentType = to.Ptr(pemContentType)
+
+			require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+			assert.Len(t, client.sets, 2, "a different content type should be written")
+		})
+	}
+
+	t.Run("content hash tag", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{SkipUnchanged: azureconfig.SkipUnchangedContentHash})
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Equal(t, contentHash(testContentHashKey, "password", "secret"), *client.tags["password"][contentHashTag])
+		assert.NotContains(t, *client.tags["password"][contentHashTag], "secret")
+	})
+
+	t.Run("content hash missing", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{SkipUnchanged: azureconfig.SkipUnchangedContentHash})
+		client.secrets["password"] = "secret"
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 1, "a secret without a hash should be written")
+	})
+
+	t.Run("expiration extended", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{
+			SkipUnchanged: azureconfig.SkipUnchangedValue,
+			Expiration:    azureconfig.ExpirationConfig{TTL: azureconfig.Duration(24 * time.Hour)},
+		})
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		soon := time.Now().Add(time.Minute)
+		client.versions["password"][0].Attributes.Expires = &soon
+		client.attrs["password"] = client.versions["password"][0].Attributes
+
+		before := time.Now()
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 1, "the unchanged value should not be written again")
+		assert.Equal(t, []string{"password/v1"}, client.updates)
+		assert.WithinDuration(t, before.Add(24*time.Hour), *client.attrs["password"].Expires, time.Minute,
+			"the expiration date of the current version should be moved forward")
+	})
+
+	t.Run("expiration can't be extended", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{
+			SkipUnchanged: azureconfig.SkipUnchangedValue,
+			Expiration:    azureconfig.ExpirationConfig{TTL: azureconfig.Duration(24 * time.Hour)},
+		})
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		client.errors["password/v1"] = newResponseError(http.StatusForbidden, "Forbidden")
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 2, "the secret should be written again to set the new expiration date")
+	})
+
+	t.Run("tags changed", func(t *testing.T) {
+		store, client := newSkipUnchangedStore(azureconfig.Config{
+			SkipUnchanged:  azureconfig.SkipUnchangedValue,
+			NamingStrategy: azureconfig.NamingStrategyNamespaceTag,
+			Namespace:      "dev",
+		})
+		client.secrets["password"] = "secret"
+
+		require.NoError(t, store.Create(ctx, SecretKeyName, "password", "secret"))
+		assert.Len(t, client.sets, 1, "the secret should be written to add the namespace tag")
+		assert.Equal(t, "dev", *client.tags["password"][namespaceTag])
+	})
+}
+
+func TestContentHash(t *testing.T) {
+	hash := contentHash(testContentHashKey, "password", "secret")
+	assert.Regexp(t, `^hmac-sha256:[0-9a-f]{64}$`, hash)
+	assert.Equal(t, hash, contentHash(testContentHashKey, "Password", "secret"), "secret names ignore case")
+	assert.NotEqual(t, hash, contentHash(testContentHashKey, "token", "secret"))
+	assert.NotEqual(t, hash, contentHash("another key", "password", "secret"), "the hash should not be reproducible without the key")
+}
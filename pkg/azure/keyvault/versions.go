@@ -0,0 +1,283 @@
+package keyvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"get.porter.sh/porter/pkg/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RollbackPrevious selects the enabled version before the current version,
+// when rolling back a secret.
+const RollbackPrevious = "previous"
+
+// SecretVersion describes a version of a secret, without its value.
+type SecretVersion struct {
+	// Version is the ID of the version.
+	Version string `json:"version"`
+
+	// Current is true for the version that is resolved.
+	Current bool `json:"current"`
+
+	// Enabled is false when the version has been disabled.
+	Enabled bool `json:"enabled"`
+
+	// Created is when the version was created.
+	Created *time.Time `json:"created,omitempty"`
+
+	// Expires is when the version expires.
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// NotBefore is when the version becomes active.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+
+	// Tags on the version.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// SecretVersions are the versions of the secret that the store uses for a key.
+type SecretVersions struct {
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// Versions of the secret, the current version first and then newest first.
+	Versions []SecretVersion `json:"versions"`
+}
+
+// Rollback describes a secret that was rolled back.
+type Rollback struct {
+	// Name of the secret in the vault.
+	Name string `json:"name"`
+
+	// From is the version that was current before the rollback.
+	From string `json:"from"`
+
+	// To is the version whose value was restored.
+	To string `json:"to"`
+
+	// Version is the new current version, with the restored value.
+	Version string `json:"version"`
+}
+
+// Versions returns the versions of the secret that Resolve finds for the key,
+// current version first and then newest first, without their values.
+func (s *Store) Versions(ctx context.Context, keyValue string) (SecretVersions, error) {
+	ctx, log := tracing.StartSpan(ctx)
+	defer log.EndSpan()
+	log.SetAttributes(s.names.attrs("requested-secret", keyValue)...)
+
+	if err := s.Connect(ctx); err != nil {
+		return SecretVersions{}, err
+	}
+
+	name, versions, err := s.findVersions(ctx, keyValue, operationVersions)
+	if err != nil {
+		return SecretVersions{}, log.Errorf("could not list the versions of secret %s: %w", s.names.redact(name), err)
+	}
+
+	result := SecretVersions{Name: name, Versions: make([]SecretVersion, 0, len(versions))}
+	for i := len(versions) - 1; i >= 0; i-- {
+		result.Versions = append(result.Versions, newSecretVersion(versions[i], i == len(versions)-1))
+	}
+	return result, nil
+}
+
+// Rollback restores the value of an older version of the secret that Resolve
+// finds for the key, by creating a new version with its value, content type
+// and tags. The version is either a version ID, or RollbackPrevious for the
+// enabled version before the current one.
+func (s *Store) Rollback(ctx context.Context, keyValue string, version string) (Rollback, error) {
+	ctx, log := tracing.StartSpan(ctx, attribute.String("rollback.to", version))
+	defer log.EndSpan()
+	log.SetAttributes(s.names.attrs("requested-secret", keyValue)...)
+
+	if err := s.Connect(ctx); err != nil {
+		return Rollback{}, err
+	}
+
+	name, versions, err := s.findVersions(ctx, keyValue, operationRollback)
+	if err != nil {
+		return Rollback{}, log.Errorf("could not list the versions of secret %s: %w", s.names.redact(name), err)
+	}
+	if len(versions) == 0 {
+		return Rollback{}, log.Errorf("secret %s has no versions", s.names.redact(name))
+	}
+
+	current := versions[len(versions)-1]
+	target, err := selectVersion(versions, version)
+	if err != nil {
+		return Rollback{}, log.Errorf("could not roll back secret %s: %w", s.names.redact(name), err)
+	}
+	result := Rollback{Name: name, From: current.ID.Version(), To: target.ID.Version()}
+	if target == current {
+		return Rollback{}, log.Errorf("version %s is already the current version of secret %s", result.To, s.names.redact(name))
+	}
+	// The older version is saved with its own tags, so it must belong to the
+	// current namespace too
+	if err := s.checkNamespace(name, target.Tags, s.namespace()); err != nil {
+		return Rollback{}, log.Errorf("could not roll back secret %s to version %s: %w", s.names.redact(name), result.To, err)
+	}
+
+	start := time.Now()
+	old, err := s.client.GetSecret(ctx, name, result.To, nil)
+	s.metrics.recordRequest(ctx, operationRollback, s.vaultUrl, false, start, err)
+	if err != nil {
+		return Rollback{}, log.Errorf("could not get version %s of secret %s: %w", result.To, s.names.redact(name), s.names.redactError(classifyError(err, operationGet, s.vaultUrl)))
+	}
+
+	params := azsecrets.SetSecretParameters{
+		Value:            old.Value,
+		ContentType:      old.ContentType,
+		Tags:             old.Tags,
+		SecretAttributes: s.secretAttributes(keyValue, time.Now()),
+	}
+	start = time.Now()
+	created, err := s.client.SetSecret(ctx, name, params, nil)
+	s.metrics.recordRequest(ctx, operationRollback, s.vaultUrl, false, start, err)
+	s.forgetPrefetched(name)
+	if err != nil {
+		return Rollback{}, log.Errorf("could not roll back secret %s: %w", s.names.redact(name), s.names.redactError(classifyError(err, operationSet, s.vaultUrl)))
+	}
+	if created.ID != nil {
+		result.Version = created.ID.Version()
+	}
+	return result, nil
+}
+
+// findVersions returns the name and versions of the secret that Resolve finds
+// for the key, oldest first with the current version last. Like Resolve, it
+// returns ErrNamespaceMismatch when the current version was created for
+// another namespace.
+func (s *Store) findVersions(ctx context.Context, keyValue string, operation string) (string, []*azsecrets.SecretProperties, error) {
+	var name string
+	var versions []*azsecrets.SecretProperties
+	var err error
+	namespace := s.namespace()
+	for _, name = range s.secretNames(keyValue, namespace) {
+		versions, err = s.currentVersions(ctx, s.client, s.vaultUrl, name, operation)
+		if !errors.Is(err, ErrSecretNotFound) {
+			break
+		}
+	}
+	if err == nil && len(versions) > 0 {
+		err = s.checkNamespace(name, versions[len(versions)-1].Tags, namespace)
+	}
+	return name, versions, err
+}
+
+// selectVersion returns the version to roll back to. Versions are sorted
+// oldest first.
+func selectVersion(versions []*azsecrets.SecretProperties, version string) (*azsecrets.SecretProperties, error) {
+	if version == RollbackPrevious {
+		for i := len(versions) - 2; i >= 0; i-- {
+			if isEnabled(versions[i]) {
+				return versions[i], nil
+			}
+		}
+		return nil, errors.New("there is no enabled version before the current version")
+	}
+
+	// Accept the full ID of the version, as it is shown in the portal
+	version = version[strings.LastIndex(version, "/")+1:]
+	for _, v := range versions {
+		if strings.EqualFold(v.ID.Version(), version) {
+			if !isEnabled(v) {
+				return nil, fmt.Errorf("version %s is disabled", version)
+			}
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("version %s was not found", version)
+}
+
+func isEnabled(props *azsecrets.SecretProperties) bool {
+	return props.Attributes == nil || props.Attributes.Enabled == nil || *props.Attributes.Enabled
+}
+
+func newSecretVersion(props *azsecrets.SecretProperties, current bool) SecretVersion {
+	version := SecretVersion{Version: props.ID.Version(), Current: current, Enabled: isEnabled(props)}
+	if props.Attributes != nil {
+		version.Created = props.Attributes.Created
+		version.Expires = props.Attributes.Expires
+		version.NotBefore = props.Attributes.NotBefore
+	}
+	if len(props.Tags) > 0 {
+		version.Tags = make(map[string]string, len(props.Tags))
+		for k, v := range props.Tags {
+			if v != nil {
+				version.Tags[k] = *v
+			}
+		}
+	}
+	return version
+}
+
+// listVersions returns the versions of the secret, oldest first.
+func (s *Store) listVersions(ctx context.Context, client secretsClient, vaultURL string, name string, operation string) ([]*azsecrets.SecretProperties, error) {
+	var versions []*azsecrets.SecretProperties
+	pager := client.NewListSecretPropertiesVersionsPager(name, nil)
+	for pager.More() {
+		start := time.Now()
+		page, err := pager.NextPage(ctx)
+		s.metrics.recordRequest(ctx, operation, vaultURL, false, start, err)
+		if err != nil {
+			return nil, classifyError(err, operationList, vaultURL)
+		}
+		for _, props := range page.Value {
+			if props != nil && props.ID != nil {
+				versions = append(versions, props)
+			}
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return createdAt(versions[i]).Before(createdAt(versions[j]))
+	})
+	return versions, nil
+}
+
+// currentVersions returns the versions of the secret, oldest first with the
+// current version last. Versions created in the same second can't be ordered
+// by when they were created, so the current version is found by getting the
+// secret without a version and matching its ID.
+func (s *Store) currentVersions(ctx context.Context, client secretsClient, vaultURL string, name string, operation string) ([]*azsecrets.SecretProperties, error) {
+	versions, err := s.listVersions(ctx, client, vaultURL, name, operation)
+	if err != nil || len(versions) == 0 {
+		return versions, err
+	}
+
+	start := time.Now()
+	current, err := client.GetSecret(ctx, name, "", nil)
+	s.metrics.recordRequest(ctx, operation, vaultURL, false, start, err)
+	if err != nil {
+		// A disabled current version can't be read, so keep the order that
+		// the versions were created in
+		if !isEnabled(versions[len(versions)-1]) {
+			return versions, nil
+		}
+		return nil, classifyError(err, operationGet, vaultURL)
+	}
+	if current.ID == nil {
+		return versions, nil
+	}
+	for i, v := range versions {
+		if strings.EqualFold(v.ID.Version(), current.ID.Version()) {
+			versions = append(append(versions[:i:i], versions[i+1:]...), v)
+			break
+		}
+	}
+	return versions, nil
+}
+
+func createdAt(props *azsecrets.SecretProperties) time.Time {
+	if props.Attributes == nil || props.Attributes.Created == nil {
+		return time.Time{}
+	}
+	return *props.Attributes.Created
+}
@@ -0,0 +1,153 @@
+package keyvault
+
+import (
+	"context"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newVersionsStore(t *testing.T) (*Store, *testClient) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	store, client := newNamespaceStore(azureconfig.Config{})
+	ctx := context.Background()
+	for _, value := range []string{"one", "two", "bad"} {
+		require.NoError(t, store.Create(ctx, SecretKeyName, "MY_PARAM.value", value))
+	}
+	return store, client
+}
+
+func TestStore_Versions(t *testing.T) {
+	store, _ := newVersionsStore(t)
+
+	versions, err := store.Versions(context.Background(), "MY_PARAM.value")
+	require.NoError(t, err)
+	assert.Equal(t, "MY-PARAM-value", versions.Name)
+	require.Len(t, versions.Versions, 3)
+	assert.Equal(t, "v3", versions.Versions[0].Version)
+	assert.True(t, versions.Versions[0].Current)
+	assert.False(t, versions.Versions[1].Current)
+	assert.Equal(t, "MY_PARAM.value", versions.Versions[2].Tags[keyTag])
+
+	_, err = store.Versions(context.Background(), "missing")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+
+	t.Run("created in the same second", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		for _, v := range client.versions["MY-PARAM-value"] {
+			v.Attributes.Created = to.Ptr(testEpoch)
+		}
+
+		versions, err := store.Versions(context.Background(), "MY_PARAM.value")
+		require.NoError(t, err)
+		require.Len(t, versions.Versions, 3)
+		assert.Equal(t, "v3", versions.Versions[0].Version)
+		assert.True(t, versions.Versions[0].Current)
+		assert.False(t, versions.Versions[1].Current)
+		assert.False(t, versions.Versions[2].Current)
+
+		rollback, err := store.Rollback(context.Background(), "MY_PARAM.value", "v1")
+		require.NoError(t, err)
+		assert.Equal(t, "v3", rollback.From)
+	})
+}
+
+func TestStore_Rollback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("previous", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+
+		rollback, err := store.Rollback(ctx, "MY_PARAM.value", RollbackPrevious)
+		require.NoError(t, err)
+		assert.Equal(t, Rollback{Name: "MY-PARAM-value", From: "v3", To: "v2", Version: "v4"}, rollback)
+		assert.Equal(t, "two", client.secrets["MY-PARAM-value"])
+
+		value, err := store.Resolve(ctx, SecretKeyName, "MY_PARAM.value")
+		require.NoError(t, err)
+		assert.Equal(t, "two", value)
+	})
+
+	t.Run("version ID", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+
+		rollback, err := store.Rollback(ctx, "MY_PARAM.value", "https://myvault.vault.azure.net/secrets/MY-PARAM-value/v1")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", rollback.To)
+		assert.Equal(t, "one", client.secrets["MY-PARAM-value"])
+	})
+
+	t.Run("skips disabled versions", func(t *testing.T) {
+		store, client := newVersionsStore(t)
+		client.versions["MY-PARAM-value"][1].Attributes.Enabled = to.Ptr(false)
+
+		rollback, err := store.Rollback(ctx, "MY_PARAM.value", RollbackPrevious)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", rollback.To)
+
+		_, err = store.Rollback(ctx, "MY_PARAM.value", "v2")
+		require.ErrorContains(t, err, "version v2 is disabled")
+	})
+
+	t.Run("current version", func(t *testing.T) {
+		store, _ := newVersionsStore(t)
+
+		_, err := store.Rollback(ctx, "MY_PARAM.value", "v3")
+		require.ErrorContains(t, err, "version v3 is already the current version")
+	})
+
+	t.Run("no previous version", func(t *testing.T) {
+		store, client := newNamespaceStore(azureconfig.Config{})
+		_, err := client.SetSecret(ctx, "single", azsecrets.SetSecretParameters{Value: to.Ptr("one")}, nil)
+		require.NoError(t, err)
+
+		_, err = store.Rollback(ctx, "single", RollbackPrevious)
+		require.ErrorContains(t, err, "there is no enabled version before the current version")
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		store, _ := newVersionsStore(t)
+
+		_, err := store.Rollback(ctx, "MY_PARAM.value", "v9")
+		require.ErrorContains(t, err, "version v9 was not found")
+	})
+}
+
+func TestStore_Versions_NamespaceIsolation(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("PORTER_NAMESPACE", "")
+	store, _ := newNamespaceStore(azureconfig.Config{
+		NamingStrategy:     azureconfig.NamingStrategyNamespaceTag,
+		NamespaceIsolation: true,
+	})
+	setNamespace(t, "prod")
+	require.NoError(t, store.Create(ctx, SecretKeyName, "password", "prod-secret"))
+	setNamespace(t, "dev")
+	require.NoError(t, store.Create(ctx, SecretKeyName, "password", "dev-secret"))
+
+	t.Run("current version of another namespace", func(t *testing.T) {
+		setNamespace(t, "prod")
+
+		_, err := store.Versions(ctx, "password")
+		require.ErrorIs(t, err, ErrNamespaceMismatch)
+
+		_, err = store.Rollback(ctx, "password", RollbackPrevious)
+		require.ErrorIs(t, err, ErrNamespaceMismatch)
+	})
+
+	t.Run("older version of another namespace", func(t *testing.T) {
+		setNamespace(t, "dev")
+
+		versions, err := store.Versions(ctx, "password")
+		require.NoError(t, err)
+		assert.Len(t, versions.Versions, 2)
+
+		_, err = store.Rollback(ctx, "password", RollbackPrevious)
+		require.ErrorIs(t, err, ErrNamespaceMismatch)
+		assert.Contains(t, err.Error(), "could not roll back secret password to version v1")
+	})
+}
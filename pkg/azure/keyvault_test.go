@@ -0,0 +1,44 @@
+package azure
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_KeyVaultName(t *testing.T) {
+	t.Setenv("PORTER_NAMESPACE", "")
+	p := NewTestPlugin(t)
+	p.Config.SecretOptions = true
+	opts := KeyVaultNameOptions{ConfigOptions: ConfigOptions{Vault: "myvault", Namespace: "dev"}}
+	opts.RawFormat = string(printer.FormatPlaintext)
+	require.NoError(t, opts.Validate([]string{"MY_PARAM.value", "my.param-value", "other?optional=true", "typo?optinal"}))
+
+	require.NoError(t, p.KeyVaultName(context.Background(), opts))
+
+	output := p.TestContext.GetOutput()
+	assert.Contains(t, output, "MY-PARAM-value")
+	assert.Contains(t, output, "https://myvault.vault.azure.net")
+	assert.Contains(t, output, "optional")
+	assert.Contains(t, output, `invalid secret key typo?optinal: unknown option "optinal"`)
+	assert.Contains(t, output, "warning: the keys MY_PARAM.value, my.param-value all use the secret MY-PARAM-value")
+}
+
+func TestKeyVaultNameOptions_Validate(t *testing.T) {
+	opts := KeyVaultNameOptions{}
+	require.EqualError(t, opts.Validate(nil), "at least one positional argument, KEY, is expected")
+}
+
+func TestPlugin_KeyVaultSet_Empty(t *testing.T) {
+	p := NewTestPlugin(t)
+	p.In = strings.NewReader("\n")
+	opts := KeyVaultSetOptions{ConfigOptions: ConfigOptions{Vault: "myvault"}}
+	require.NoError(t, opts.Validate([]string{"mysecret"}))
+
+	err := p.KeyVaultSet(context.Background(), opts)
+	require.EqualError(t, err, "the secret value is empty, use --allow-empty to save an empty value")
+}
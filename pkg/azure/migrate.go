@@ -0,0 +1,168 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/pkg/errors"
+)
+
+// KeyVaultMigrateOptions are the arguments of the keyvault migrate command.
+type KeyVaultMigrateOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// From is the name or url of the vault to copy the secrets from. Defaults
+	// to the configured vault.
+	From string
+
+	// To is the name or url of the vault to copy the secrets to.
+	To string
+
+	// Prefix selects the secrets whose names start with the prefix.
+	Prefix string
+
+	// Tags select the secrets that have every tag, formatted as NAME=VALUE.
+	Tags []string
+
+	// All selects every secret.
+	All bool
+
+	// History copies every enabled version of the secrets.
+	History bool
+
+	// DryRun reports the secrets that would be copied, without copying them.
+	DryRun bool
+
+	// Verify checks that the secrets in both vaults have the same value.
+	Verify bool
+
+	// Overwrite copies secrets that already exist in the target vault.
+	Overwrite bool
+}
+
+func (o *KeyVaultMigrateOptions) Validate() error {
+	if o.To == "" {
+		return errors.New("the vault to copy the secrets to must be specified with --to")
+	}
+	if o.Prefix == "" && len(o.Tags) == 0 && !o.All {
+		return errors.New("select the secrets to migrate with --prefix or --tag, or use --all")
+	}
+	if _, err := parseTags(o.Tags); err != nil {
+		return err
+	}
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultMigrate copies secrets between vaults and prints a report. An error
+// is returned when a secret could not be copied or verified.
+func (p *Plugin) KeyVaultMigrate(ctx context.Context, opts KeyVaultMigrateOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	tags, err := parseTags(opts.Tags)
+	if err != nil {
+		return err
+	}
+	from := p.Config.GetVaultURL()
+	if opts.From != "" {
+		from = vaultURLOf(opts.From)
+	}
+
+	report, err := store.Migrate(ctx, keyvault.MigrateOptions{
+		From:      from,
+		To:        vaultURLOf(opts.To),
+		Prefix:    opts.Prefix,
+		Tags:      tags,
+		All:       opts.All,
+		History:   opts.History,
+		DryRun:    opts.DryRun,
+		Verify:    opts.Verify,
+		Overwrite: opts.Overwrite,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := p.printMigrateReport(report, opts.Format); err != nil {
+		return err
+	}
+	if failed := report.Failed(); failed > 0 {
+		return errors.Errorf("%d of %d secrets could not be migrated", failed, len(report.Secrets))
+	}
+	return nil
+}
+
+// vaultURLOf returns the url of a vault that is specified by its name or url.
+func vaultURLOf(vault string) string {
+	if strings.Contains(vault, "://") {
+		return vault
+	}
+	return azureconfig.VaultConfig{Vault: vault}.GetVaultURL()
+}
+
+// parseTags parses tags formatted as NAME=VALUE.
+func parseTags(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(values))
+	for _, value := range values {
+		name, tag, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, errors.Errorf("invalid tag %q, it must be formatted as NAME=VALUE", value)
+		}
+		tags[name] = tag
+	}
+	return tags, nil
+}
+
+func (p *Plugin) printMigrateReport(report keyvault.MigrateReport, format printer.Format) error {
+	if format == printer.FormatJson {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the report as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	if len(report.Secrets) > 0 {
+		w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTATUS\tVERSIONS\tVERIFIED")
+		for _, secret := range report.Secrets {
+			verified := ""
+			if secret.Verified != nil {
+				verified = fmt.Sprint(*secret.Verified)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", secret.Name, secret.Status, secret.Versions, verified)
+			if secret.Error != "" {
+				fmt.Fprintf(w, "\t\t\terror: %s\n", secret.Error)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var versions int
+	for _, secret := range report.Secrets {
+		if secret.Status == keyvault.MigrateCopied || secret.Status == keyvault.MigrateWouldCopy {
+			versions += secret.Versions
+		}
+	}
+	verb := "Copied"
+	if report.DryRun {
+		verb = "Would copy"
+	}
+	fmt.Fprintf(p.Out, "%s %d versions of the %d selected secrets from %s to %s.\n", verb, versions, len(report.Secrets), report.From, report.To)
+	return nil
+}
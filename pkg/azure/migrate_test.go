@@ -0,0 +1,43 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyVaultMigrateOptions_Validate(t *testing.T) {
+	testcases := []struct {
+		name    string
+		opts    KeyVaultMigrateOptions
+		wantErr string
+	}{
+		{name: "prefix", opts: KeyVaultMigrateOptions{To: "newvault", Prefix: "app-"}},
+		{name: "all", opts: KeyVaultMigrateOptions{To: "newvault", All: true}},
+		{name: "no target", opts: KeyVaultMigrateOptions{Prefix: "app-"}, wantErr: "the vault to copy the secrets to must be specified with --to"},
+		{name: "no filter", opts: KeyVaultMigrateOptions{To: "newvault"}, wantErr: "select the secrets to migrate with --prefix or --tag, or use --all"},
+		{name: "invalid tag", opts: KeyVaultMigrateOptions{To: "newvault", Tags: []string{"porter-namespace"}}, wantErr: `invalid tag "porter-namespace", it must be formatted as NAME=VALUE`},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := parseTags([]string{"porter-namespace=dev", "team=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"porter-namespace": "dev", "team": "a=b", "empty": ""}, tags)
+}
+
+func TestVaultURLOf(t *testing.T) {
+	assert.Equal(t, "https://myvault.vault.azure.net", vaultURLOf("myvault"))
+	assert.Equal(t, "https://myvault.vault.usgovcloudapi.net", vaultURLOf("https://myvault.vault.usgovcloudapi.net"))
+}
@@ -2,7 +2,6 @@ package azure
 
 import (
 	"bufio"
-	"encoding/json"
 	"io/ioutil"
 
 	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
@@ -39,10 +38,8 @@ func (p *Plugin) LoadConfig() error {
 		return nil
 	}
 
-	err = json.Unmarshal(b, &p.Config)
-	if err != nil {
-		return errors.Wrapf(err, "error unmarshaling stdin %q as azure.Config", string(b))
-	}
-
-	return nil
+	// Parse rejects unknown settings, so that a misspelled setting isn't
+	// silently replaced by its default
+	p.Config, err = azureconfig.Parse(b)
+	return errors.Wrap(err, "invalid azure plugin configuration")
 }
@@ -1,9 +1,13 @@
 package azure
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -11,3 +15,91 @@ func TestNew(t *testing.T) {
 	assert.True(t, p.IsInternalPlugin, "expected tracing to be configured as a plugin")
 	assert.Equal(t, "porter.plugins.azure", p.InternalPluginKey, "expected the plugin to have its on tracing service name")
 }
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("logging", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"vault": "myvault", "logging": {"level": "warn", "format": "text", "azure-sdk": true}}`)
+
+		require.NoError(t, p.LoadConfig())
+		assert.Equal(t, "myvault", p.Config.Vault)
+		assert.Equal(t, "warn", p.Config.Logging.Level)
+		assert.Equal(t, "text", p.Config.Logging.Format)
+		assert.True(t, p.Config.Logging.AzureSDK)
+	})
+
+	t.Run("invalid log level", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"logging": {"level": "loud"}}`)
+
+		err := p.LoadConfig()
+		require.ErrorContains(t, err, `logging.level: invalid value "loud"`)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"retry": {"max-retries": 5, "retry-delay": "2s", "max-retry-delay": "1m", "respect-retry-after": false}}`)
+
+		require.NoError(t, p.LoadConfig())
+		assert.Equal(t, int32(5), p.Config.Retry.MaxRetries)
+		assert.Equal(t, 2*time.Second, p.Config.Retry.RetryDelay.Duration())
+		assert.Equal(t, time.Minute, p.Config.Retry.MaxRetryDelay.Duration())
+		assert.False(t, p.Config.Retry.ShouldRespectRetryAfter())
+	})
+
+	t.Run("invalid retry delay", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"retry": {"retry-delay": "soon"}}`)
+
+		err := p.LoadConfig()
+		require.ErrorContains(t, err, `retry.retry-delay: invalid value "soon", expected a duration`)
+	})
+
+	t.Run("client assertion", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"credential": "client-assertion", "tenant-id": "mytenant", "client-id": "myclient", "federated-token-command": "get-token"}`)
+
+		require.NoError(t, p.LoadConfig())
+		assert.Equal(t, "client-assertion", p.Config.GetCredential())
+		assert.Equal(t, "mytenant", p.Config.TenantID)
+		assert.Equal(t, "myclient", p.Config.ClientID)
+		assert.Equal(t, "get-token", p.Config.FederatedTokenCommand)
+	})
+
+	t.Run("client assertion without a token", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"credential": "client-assertion", "tenant-id": "mytenant", "client-id": "myclient"}`)
+
+		err := p.LoadConfig()
+		require.ErrorContains(t, err, "federated-token-file or federated-token-command is required when credential is client-assertion")
+	})
+
+	t.Run("invalid credential", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"credential": "magic"}`)
+
+		err := p.LoadConfig()
+		require.ErrorContains(t, err, `credential: invalid value "magic"`)
+	})
+
+	t.Run("unknown setting", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader(`{"valut": "myvault", "vaults": [{"vault_url": "https://othervault.vault.azure.net"}]}`)
+
+		err := p.LoadConfig()
+		var fieldErrs azureconfig.FieldErrors
+		require.ErrorAs(t, err, &fieldErrs)
+		assert.Equal(t, azureconfig.FieldErrors{
+			{Field: "valut", Message: "unknown setting, did you mean vault?"},
+			{Field: "vaults[0].vault_url", Message: "unknown setting, did you mean vault-url?"},
+			{Field: "vaults[0]", Message: "vault or vault-url is required"},
+		}, fieldErrs)
+	})
+
+	t.Run("empty config", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		p.In = strings.NewReader("")
+
+		require.NoError(t, p.LoadConfig())
+	})
+}
@@ -0,0 +1,130 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/pkg/errors"
+)
+
+// DefaultRotateGracePeriod is how long replaced versions stay enabled when
+// rotate disables older versions.
+const DefaultRotateGracePeriod = 24 * time.Hour
+
+// KeyVaultRotateOptions are the arguments of the keyvault rotate command.
+type KeyVaultRotateOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// Key is the secret key, as it is used by Porter.
+	Key string
+
+	// Generator is the name of the built-in generator that creates the value.
+	Generator string
+
+	// Length of generated passwords.
+	Length int
+
+	// Symbols adds symbols to generated passwords.
+	Symbols bool
+
+	// Bits is the size of generated RSA keys.
+	Bits int
+
+	// Command prints the value, for the command generator.
+	Command string
+
+	// DisableOlder disables the versions that were replaced longer than the
+	// grace period ago.
+	DisableOlder bool
+
+	// GracePeriod is how long replaced versions stay enabled.
+	GracePeriod time.Duration
+
+	generator keyvault.Generator
+}
+
+func (o *KeyVaultRotateOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one positional argument, KEY, is expected")
+	}
+	o.Key = args[0]
+
+	if o.Generator == "" && o.Command != "" {
+		o.Generator = keyvault.GeneratorCommand
+	}
+	var err error
+	o.generator, err = keyvault.NewGenerator(o.Generator, keyvault.GeneratorOptions{
+		Length:  o.Length,
+		Symbols: o.Symbols,
+		Bits:    o.Bits,
+		Command: o.Command,
+	})
+	if err != nil {
+		return err
+	}
+	if o.GracePeriod < 0 {
+		return errors.Errorf("the grace period %s must not be negative", o.GracePeriod)
+	}
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultRotate saves a generated value as the new version of the secret
+// that the plugin resolves for the secret key, and prints the rotation. The
+// value isn't printed. An error is returned when an older version could not be
+// disabled.
+func (p *Plugin) KeyVaultRotate(ctx context.Context, opts KeyVaultRotateOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	rotation, err := store.Rotate(ctx, opts.Key, keyvault.RotateOptions{
+		Generator:    opts.generator,
+		DisableOlder: opts.DisableOlder,
+		GracePeriod:  opts.GracePeriod,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := p.printRotation(rotation, opts.Format); err != nil {
+		return err
+	}
+	if len(rotation.Errors) > 0 {
+		return errors.Errorf("secret %s was rotated, but %d older versions could not be disabled", rotation.Name, len(rotation.Errors))
+	}
+	return nil
+}
+
+func (p *Plugin) printRotation(rotation keyvault.Rotation, format printer.Format) error {
+	if format == printer.FormatJson {
+		b, err := json.MarshalIndent(rotation, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the rotation as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	if rotation.Previous != "" {
+		fmt.Fprintf(p.Out, "Rotated %s from version %s to %s with the %s generator.\n", rotation.Name, rotation.Previous, rotation.Version, rotation.Generator)
+	} else {
+		fmt.Fprintf(p.Out, "Created %s version %s with the %s generator.\n", rotation.Name, rotation.Version, rotation.Generator)
+	}
+	if len(rotation.Disabled) > 0 {
+		fmt.Fprintf(p.Out, "Disabled versions %s.\n", strings.Join(rotation.Disabled, ", "))
+	}
+	for _, retained := range rotation.Retained {
+		fmt.Fprintf(p.Out, "Version %s stays enabled until %s, rotate again after that to disable it.\n", retained.Version, formatTime(&retained.DisableAfter))
+	}
+	for _, msg := range rotation.Errors {
+		fmt.Fprintf(p.Err, "error: %s\n", msg)
+	}
+	return nil
+}
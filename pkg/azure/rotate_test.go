@@ -0,0 +1,55 @@
+package azure
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyVaultRotateOptions_Validate(t *testing.T) {
+	t.Run("password", func(t *testing.T) {
+		opts := KeyVaultRotateOptions{Generator: "password", Length: 20, Symbols: true}
+		require.NoError(t, opts.Validate([]string{"db-password"}))
+		assert.Equal(t, "db-password", opts.Key)
+		assert.Equal(t, keyvault.PasswordGenerator{Length: 20, Symbols: true}, opts.generator)
+	})
+
+	t.Run("command implies the command generator", func(t *testing.T) {
+		opts := KeyVaultRotateOptions{Command: "openssl rand -base64 32"}
+		require.NoError(t, opts.Validate([]string{"token"}))
+		assert.Equal(t, keyvault.CommandGenerator{Command: "openssl rand -base64 32"}, opts.generator)
+	})
+
+	t.Run("generator required", func(t *testing.T) {
+		opts := KeyVaultRotateOptions{}
+		require.ErrorContains(t, opts.Validate([]string{"token"}), `unknown generator ""`)
+	})
+
+	t.Run("negative grace period", func(t *testing.T) {
+		opts := KeyVaultRotateOptions{Generator: "uuid", GracePeriod: -time.Hour}
+		require.EqualError(t, opts.Validate([]string{"token"}), "the grace period -1h0m0s must not be negative")
+	})
+}
+
+func TestPlugin_PrintRotation(t *testing.T) {
+	p := NewTestPlugin(t)
+	out := &bytes.Buffer{}
+	p.Out = out
+
+	rotation := keyvault.Rotation{
+		Name: "db-password", Generator: "password", Version: "v4", Previous: "v3",
+		Disabled: []string{"v1", "v2"},
+		Retained: []keyvault.RetainedVersion{{Version: "v3", DisableAfter: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+	}
+	require.NoError(t, p.printRotation(rotation, printer.FormatPlaintext))
+
+	assert.Equal(t, `Rotated db-password from version v3 to v4 with the password generator.
+Disabled versions v1, v2.
+Version v3 stays enabled until 2024-01-02T00:00:00Z, rotate again after that to disable it.
+`, out.String())
+}
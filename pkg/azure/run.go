@@ -1,18 +1,25 @@
 package azure
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
 	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
 	"get.porter.sh/porter/pkg/plugins"
 	"get.porter.sh/porter/pkg/portercontext"
 	secretsplugins "get.porter.sh/porter/pkg/secrets/plugins"
-	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
 )
 
+// metricsShutdownTimeout limits how long the plugin waits to export its
+// metrics when it exits.
+const metricsShutdownTimeout = 5 * time.Second
+
 type RunOptions struct {
 	Key               string
 	selectedPlugin    pluginInitializer
@@ -43,12 +50,7 @@ func (o *RunOptions) Validate(args []string) error {
 
 func (p *Plugin) Run(args []string) {
 	// This logger only helps log errors with loading the plugin
-	logger := hclog.New(&hclog.LoggerOptions{
-		Name:       "azure",
-		Output:     p.Err,
-		Level:      hclog.Debug,
-		JSONFormat: true,
-	})
+	logger := p.Config.Logging.NewLogger("azure", p.Err)
 
 	err := p.LoadConfig()
 	if err != nil {
@@ -56,6 +58,10 @@ func (p *Plugin) Run(args []string) {
 		return
 	}
 
+	// Now that we have the plugin configuration, use the configured logging
+	logger = p.Config.Logging.NewLogger("azure", p.Err)
+	p.Config.Logging.ConfigureAzureSDK(logger.Named("sdk"))
+
 	// We are not following the normal CLI pattern here because
 	// if we write to stdout without the hclog, it will cause the plugin framework to blow up
 	var opts RunOptions
@@ -65,6 +71,23 @@ func (p *Plugin) Run(args []string) {
 		return
 	}
 
+	provider, err := p.Config.Metrics.NewMeterProvider(context.Background())
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if provider != nil {
+		otel.SetMeterProvider(provider)
+		defer func() {
+			// Export the metrics recorded since the last interval before exiting
+			ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+			defer cancel()
+			if err := provider.Shutdown(ctx); err != nil {
+				logger.Warn(fmt.Sprintf("could not export the plugin metrics: %s", err))
+			}
+		}()
+	}
+
 	plugins.Serve(p.Context, opts.selectedInterface, opts.selectedPlugin(p.Context, p.Config), secretsplugins.PluginProtocolVersion)
 }
 
@@ -0,0 +1,73 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"get.porter.sh/plugin/azure/pkg/azure/azureconfig"
+	"github.com/pkg/errors"
+)
+
+// SchemaOptions are the arguments of the schema command.
+type SchemaOptions struct {
+	// Plugin selects the plugin, such as secrets.azure.keyvault. By default
+	// the schemas of every plugin are printed.
+	Plugin string
+}
+
+func (o *SchemaOptions) Validate(args []string) error {
+	switch len(args) {
+	case 0:
+	case 1:
+		o.Plugin = args[0]
+		if _, ok := availablePlugins[o.Plugin]; !ok {
+			return errors.Errorf("invalid plugin key specified: %q, allowed values are: %s", o.Plugin, strings.Join(pluginKeys(), ", "))
+		}
+	default:
+		return errors.New("only one positional argument, PLUGIN, is expected")
+	}
+	return nil
+}
+
+// PrintSchema prints the JSON Schema of the config section of the selected
+// plugin, or an object with the schema of every plugin, keyed by plugin.
+func (p *Plugin) PrintSchema(opts SchemaOptions) error {
+	var schema interface{}
+	if opts.Plugin != "" {
+		schema = pluginSchema(opts.Plugin)
+	} else {
+		schemas := make(map[string]*azureconfig.Schema, len(availablePlugins))
+		for _, key := range pluginKeys() {
+			schemas[key] = pluginSchema(key)
+		}
+		schema = schemas
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not format the schema as json")
+	}
+	fmt.Fprintln(p.Out, string(b))
+	return nil
+}
+
+// pluginSchema returns the schema of the config section of the plugin. Every
+// plugin in the binary is configured with azureconfig.Config.
+func pluginSchema(key string) *azureconfig.Schema {
+	schema := azureconfig.NewSchema()
+	_, plugin, _ := strings.Cut(key, ".")
+	schema.Title = fmt.Sprintf("Configuration of the %s plugin", plugin)
+	return schema
+}
+
+// pluginKeys returns the keys of the plugins in the binary, sorted.
+func pluginKeys() []string {
+	keys := make([]string, 0, len(availablePlugins))
+	for key := range availablePlugins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
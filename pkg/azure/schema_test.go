@@ -0,0 +1,51 @@
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_PrintSchema(t *testing.T) {
+	t.Run("every plugin", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		out := &bytes.Buffer{}
+		p.Out = out
+
+		opts := SchemaOptions{}
+		require.NoError(t, opts.Validate(nil))
+		require.NoError(t, p.PrintSchema(opts))
+
+		var schemas map[string]map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &schemas))
+		require.Contains(t, schemas, keyvault.PluginInterface)
+		schema := schemas[keyvault.PluginInterface]
+		assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+		assert.Equal(t, "Configuration of the azure.keyvault plugin", schema["title"])
+		assert.Equal(t, false, schema["additionalProperties"])
+		assert.Contains(t, schema["properties"], "vault-url")
+	})
+
+	t.Run("one plugin", func(t *testing.T) {
+		p := NewTestPlugin(t)
+		out := &bytes.Buffer{}
+		p.Out = out
+
+		opts := SchemaOptions{}
+		require.NoError(t, opts.Validate([]string{keyvault.PluginInterface}))
+		require.NoError(t, p.PrintSchema(opts))
+
+		var schema map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &schema))
+		assert.Equal(t, "object", schema["type"])
+	})
+
+	t.Run("unknown plugin", func(t *testing.T) {
+		opts := SchemaOptions{}
+		require.ErrorContains(t, opts.Validate([]string{"storage.azure.blob"}), `invalid plugin key specified: "storage.azure.blob"`)
+	})
+}
@@ -0,0 +1,102 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/pkg/errors"
+)
+
+// KeyVaultVersionsOptions are the arguments of the keyvault versions command.
+type KeyVaultVersionsOptions struct {
+	ConfigOptions
+	printer.PrintOptions
+
+	// Key is the secret key, as it is used by Porter.
+	Key string
+}
+
+func (o *KeyVaultVersionsOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one positional argument, KEY, is expected")
+	}
+	o.Key = args[0]
+	return o.PrintOptions.Validate(printer.FormatPlaintext, []printer.Format{printer.FormatPlaintext, printer.FormatJson})
+}
+
+// KeyVaultRollbackOptions are the arguments of the keyvault rollback command.
+type KeyVaultRollbackOptions struct {
+	ConfigOptions
+
+	// Key is the secret key, as it is used by Porter.
+	Key string
+
+	// To is the version to restore, or previous for the version before the
+	// current version.
+	To string
+}
+
+func (o *KeyVaultRollbackOptions) Validate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one positional argument, KEY, is expected")
+	}
+	o.Key = args[0]
+	if o.To == "" {
+		return errors.Errorf("the version to roll back to must be specified with --to, use --to %s for the version before the current version", keyvault.RollbackPrevious)
+	}
+	return nil
+}
+
+// KeyVaultVersions prints the versions of the secret that the plugin resolves
+// for the secret key, without their values.
+func (p *Plugin) KeyVaultVersions(ctx context.Context, opts KeyVaultVersionsOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	versions, err := store.Versions(ctx, opts.Key)
+	if err != nil {
+		return err
+	}
+	return p.printVersions(versions, opts.Format)
+}
+
+// KeyVaultRollback restores the value of an older version of the secret, as
+// a new version.
+func (p *Plugin) KeyVaultRollback(ctx context.Context, opts KeyVaultRollbackOptions) error {
+	store, err := p.newKeyVaultStore(opts.ConfigOptions)
+	if err != nil {
+		return err
+	}
+
+	rollback, err := store.Rollback(ctx, opts.Key, opts.To)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "Rolled back %s from version %s to %s, the restored value is version %s.\n", rollback.Name, rollback.From, rollback.To, rollback.Version)
+	return nil
+}
+
+func (p *Plugin) printVersions(versions keyvault.SecretVersions, format printer.Format) error {
+	if format == printer.FormatJson {
+		b, err := json.MarshalIndent(versions, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "could not format the versions as json")
+		}
+		fmt.Fprintln(p.Out, string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCURRENT\tENABLED\tCREATED\tEXPIRES\tTAGS")
+	for _, version := range versions.Versions {
+		fmt.Fprintf(w, "%s\t%t\t%t\t%s\t%s\t%s\n", version.Version, version.Current, version.Enabled,
+			formatTime(version.Created), formatTime(version.Expires), formatTags(version.Tags))
+	}
+	return w.Flush()
+}
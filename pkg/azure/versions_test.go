@@ -0,0 +1,43 @@
+package azure
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"get.porter.sh/plugin/azure/pkg/azure/keyvault"
+	"get.porter.sh/porter/pkg/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyVaultRollbackOptions_Validate(t *testing.T) {
+	opts := KeyVaultRollbackOptions{}
+	require.ErrorContains(t, opts.Validate([]string{"mysecret"}), "--to previous")
+
+	opts.To = keyvault.RollbackPrevious
+	require.NoError(t, opts.Validate([]string{"mysecret"}))
+	assert.Equal(t, "mysecret", opts.Key)
+
+	require.EqualError(t, opts.Validate(nil), "exactly one positional argument, KEY, is expected")
+}
+
+func TestPlugin_PrintVersions(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	versions := keyvault.SecretVersions{Name: "mysecret", Versions: []keyvault.SecretVersion{
+		{Version: "v2", Current: true, Enabled: true, Created: &created, Tags: map[string]string{"porter-key": "mysecret"}},
+		{Version: "v1", Enabled: false},
+	}}
+
+	p := NewTestPlugin(t)
+	out := &bytes.Buffer{}
+	p.Out = out
+	require.NoError(t, p.printVersions(versions, printer.FormatPlaintext))
+
+	lines := strings.Split(out.String(), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "VERSION  CURRENT  ENABLED  CREATED               EXPIRES  TAGS", lines[0])
+	assert.Equal(t, "v2       true     true     2024-01-02T03:04:05Z           porter-key=mysecret", lines[1])
+	assert.Equal(t, "v1       false    false", strings.TrimSpace(lines[2]))
+}